@@ -0,0 +1,274 @@
+// Package asmtest : ソースコードに埋め込んだ期待値と、ゴールデンファイルによるアセンブル結果のテスト
+//
+// 出力されるバイト列を行のコメントに16進数で記述する
+//
+//	DW 512 ; expect: 00 02
+//
+// ソースコードと同じディレクトリに拡張子を.binにしたゴールデンファイルがあれば、出力全体をそれと比較する
+package asmtest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler"
+)
+
+// ディレクトリから探すソースコードの既定の拡張子
+const SourceExt = ".asm"
+
+// ゴールデンファイルの拡張子
+const GoldenExt = ".bin"
+
+// 期待値を記述するコメント
+const expectMarker = "expect:"
+
+// ゴールデンファイルと異なる範囲を報告する最大数
+const maxDiffs = 10
+
+// テストの失敗
+type Failure struct {
+	Line    int    // 行番号 ファイル全体に関するものなら0
+	Message string // メッセージ
+}
+
+// 1つのソースコードのテスト結果
+type Result struct {
+	Name         string    // ソースコードのパス
+	Expectations int       // 確認した期待値の数
+	Golden       bool      // ゴールデンファイルと比較、または更新したかどうか
+	Updated      bool      // ゴールデンファイルを更新したかどうか
+	Failures     []Failure // 失敗
+}
+
+// 失敗が無いかどうか
+func (r *Result) OK() bool {
+	return len(r.Failures) == 0
+}
+
+// 失敗を1行に1つ、ソースコードの位置を付けて表す
+func (r *Result) String() string {
+
+	var b strings.Builder
+	for _, f := range r.Failures {
+		if f.Line > 0 {
+			fmt.Fprintf(&b, "%s:%d: %s\n", r.Name, f.Line, f.Message)
+		} else {
+			fmt.Fprintf(&b, "%s: %s\n", r.Name, f.Message)
+		}
+	}
+	return b.String()
+}
+
+func (r *Result) fail(line int, format string, args ...interface{}) {
+	r.Failures = append(r.Failures, Failure{Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// テストするソースコードを列挙する
+// ディレクトリは再帰的に辿り、拡張子がextsのいずれかのファイルを対象とする
+// ファイルを直接指定した場合は拡張子に関わらず対象とする
+//
+// @param paths --- ファイルまたはディレクトリのパス
+// @param exts  --- ディレクトリから探す拡張子 省略した場合は.asm
+//
+// @return ソースコードのパス、エラー
+func Files(paths []string, exts ...string) ([]string, error) {
+
+	if len(exts) == 0 {
+		exts = []string{SourceExt}
+	}
+	isSource := func(name string) bool {
+		for _, ext := range exts {
+			if filepath.Ext(name) == ext {
+				return true
+			}
+		}
+		return false
+	}
+
+	var result []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			result = append(result, path)
+			continue
+		}
+		err = filepath.Walk(path, func(name string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && isSource(name) {
+				result = append(result, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// ソースコードに対応するゴールデンファイルのパス
+//
+// @param name --- ソースコードのパス
+//
+// @return ゴールデンファイルのパス
+func GoldenPath(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name)) + GoldenExt
+}
+
+// ソースコードをアセンブルし、埋め込まれた期待値とゴールデンファイルを確認する
+// INCLUDE命令のファイル名はソースコードのあるディレクトリからの相対パスとする
+//
+// @param name   --- ソースコードのパス
+// @param update --- trueならゴールデンファイルを比較せずにアセンブル結果で作成、更新する
+// @param opts   --- 文字コードなど、INCLUDE命令のファイルシステム以外のアセンブラのオプション
+//
+// @return テスト結果、エラー テストの失敗はエラーではなくResult.Failuresに含まれる
+func Run(name string, update bool, opts ...assembler.Option) (*Result, error) {
+
+	src, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Name: name}
+
+	out := new(bytes.Buffer)
+	opts = append(opts, assembler.WithIncludeFS(assembler.DirFS(filepath.Dir(name))))
+	asm := assembler.New(opts...)
+	assembled, err := asm.Exec(bytes.NewReader(src), out)
+	if err != nil {
+		for _, d := range assembled.Diagnostics {
+			if d.Severity == assembler.SeverityError && d.File == "" {
+				result.fail(d.Line, "%s", d.Message)
+			} else if d.Severity == assembler.SeverityError {
+				result.fail(0, "%s:%d: %s", d.File, d.Line, d.Message)
+			}
+		}
+		return result, nil
+	}
+
+	// 各期待値を、その行が出力したバイト列と比較する
+	expectations, failures := parseExpectations(src)
+	result.Failures = append(result.Failures, failures...)
+	lines := outputByLine(out.Bytes(), assembled.Sources)
+	for _, e := range expectations {
+		result.Expectations++
+		if got := lines[e.line]; !bytes.Equal(got, e.bytes) {
+			result.fail(e.line, "got %s, want %s", hexBytes(got), hexBytes(e.bytes))
+		}
+	}
+
+	golden := GoldenPath(name)
+	if update {
+		if err := ioutil.WriteFile(golden, out.Bytes(), 0644); err != nil {
+			return nil, err
+		}
+		result.Golden, result.Updated = true, true
+		return result, nil
+	}
+
+	want, err := ioutil.ReadFile(golden)
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Golden = true
+	diffs := assembler.Diff(out.Bytes(), want, assembled.Sources)
+	if len(diffs) > 0 {
+		result.fail(0, "differs from %s (%d bytes, want %d bytes)", filepath.Base(golden), out.Len(), len(want))
+	}
+	for i, d := range diffs {
+		if i == maxDiffs {
+			result.fail(0, "... and %d more ranges", len(diffs)-maxDiffs)
+			break
+		}
+		result.fail(d.Line, "0x%06X: got %s, want %s", d.Offset, hexBytes(d.Got), hexBytes(d.Want))
+	}
+
+	return result, nil
+}
+
+// ソースコードに埋め込まれた期待値
+type expectation struct {
+	line  int    // 行番号
+	bytes []byte // 行が出力するバイト列
+}
+
+// ソースコードから期待値を取り出す
+// 行のコメントが"expect:"で始まるものを期待値とする
+//
+// @param src --- ソースコード
+//
+// @return 期待値、16進数として解釈できなかった期待値の失敗
+func parseExpectations(src []byte) ([]expectation, []Failure) {
+
+	var (
+		result   []expectation
+		failures []Failure
+	)
+	for i, line := range strings.Split(string(src), "\n") {
+
+		at := strings.LastIndex(line, expectMarker)
+		if at < 0 {
+			continue
+		}
+		comment := strings.TrimRight(line[:at], " \t")
+		if !strings.HasSuffix(comment, ";") {
+			continue
+		}
+
+		text := strings.Join(strings.Fields(line[at+len(expectMarker):]), "")
+		b, err := hex.DecodeString(text)
+		if err != nil {
+			failures = append(failures, Failure{Line: i + 1, Message: fmt.Sprintf("invalid expectation: %v", err)})
+			continue
+		}
+		result = append(result, expectation{line: i + 1, bytes: b})
+	}
+	return result, failures
+}
+
+// メインのソースコードの各行が出力したバイト列
+// マクロの本体のように複数回出力する行は、出力した順に連結する
+//
+// @param out     --- アセンブルした出力
+// @param sources --- 出力の各範囲を出力したソースコード上の位置
+//
+// @return 行番号:バイト列
+func outputByLine(out []byte, sources []assembler.SourceRange) map[int][]byte {
+
+	result := make(map[int][]byte)
+	for i, s := range sources {
+		end := int64(len(out))
+		if i+1 < len(sources) {
+			end = sources[i+1].Offset
+		}
+		if s.File == "" && s.Offset < end {
+			result[s.Line] = append(result[s.Line], out[s.Offset:end]...)
+		}
+	}
+	return result
+}
+
+// 失敗のメッセージ用にバイト列を16進数で表す
+func hexBytes(b []byte) string {
+	switch {
+	case len(b) == 0:
+		return "(none)"
+	case len(b) > 16:
+		return fmt.Sprintf("% X ... (%d bytes)", b[:16], len(b))
+	}
+	return fmt.Sprintf("% X", b)
+}
@@ -0,0 +1,166 @@
+package asmtest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/japanese"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler"
+)
+
+// アセンブラのテストデータが全てアセンブルでき、埋め込まれた期待値とゴールデンファイルが全て一致すること
+// 既存のテストデータ(.txt)はassemblerパッケージのテストで出力を確認しているので、アセンブルできることだけを確認する
+func TestRun_Corpus(t *testing.T) {
+
+	files, err := Files([]string{"../assembler/testdata"}, ".asm", ".txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ran := 0
+	for _, name := range files {
+		result, err := Run(name, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.OK() {
+			t.Fatal(result)
+		}
+		if filepath.Ext(name) == SourceExt && result.Expectations == 0 && !result.Golden {
+			t.Fatal(name, "has no expectations")
+		}
+		ran++
+	}
+	if ran < 2 {
+		t.Fatal(files)
+	}
+}
+
+func TestRun_Failure(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "asmtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	testCases := []struct {
+		src    string
+		golden string // ゴールデンファイルの内容 空なら作成しない
+		want   string
+	}{
+		{src: "DB 1 ; expect: 01\nDW 2 ; expect: 02\nDB 3 ; expect:", want: "t.asm:2: got 02 00, want 02|t.asm:3: got 03, want (none)"},
+		{src: "DB 1 ; expect: 0g", want: "t.asm:1: invalid expectation: encoding/hex: invalid byte: U+0067 'g'"},
+		{src: "DB 1\nDB 256", want: "t.asm:2: "},
+		{src: "DB 1\nDB 2 ; expect: 02", golden: "\x01\x03\x04", want: "t.asm: differs from t.bin (2 bytes, want 3 bytes)|t.asm:2: 0x000001: got 02, want 03|t.asm: 0x000002: got (none), want 04"},
+		{src: "DB 1\nDB 2 ; expect: 02", golden: "\x01\x02"},
+	}
+
+	name := filepath.Join(dir, "t.asm")
+	for i, tt := range testCases {
+
+		_ = os.Remove(GoldenPath(name))
+		if err := ioutil.WriteFile(name, []byte(tt.src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if tt.golden != "" {
+			if err := ioutil.WriteFile(GoldenPath(name), []byte(tt.golden), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		result, err := Run(name, false)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		got := strings.Replace(strings.TrimSuffix(result.String(), "\n"), "\n", "|", -1)
+		got = strings.Replace(got, dir+string(filepath.Separator), "", -1)
+		if !strings.HasPrefix(got, tt.want) || (tt.want == "") != result.OK() {
+			t.Fatalf("%d: %s", i, got)
+		}
+	}
+}
+
+func TestRun_Update(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "asmtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	name := filepath.Join(dir, "update.asm")
+	if err := ioutil.WriteFile(name, []byte("DB 1, 2 ; expect: 01 02\nDW 3"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "update.bin"), []byte{9}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := Run(name, false); err != nil || result.OK() {
+		t.Fatal(result, err)
+	}
+
+	result, err := Run(name, true)
+	if err != nil || !result.OK() || !result.Updated || result.Expectations != 1 {
+		t.Fatal(result, err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "update.bin"))
+	if err != nil || string(b) != "\x01\x02\x03\x00" {
+		t.Fatalf("% x %v", b, err)
+	}
+	if result, err := Run(name, false); err != nil || !result.OK() || !result.Golden {
+		t.Fatal(result, err)
+	}
+
+	// アセンブルに失敗した場合はゴールデンファイルを更新しない
+	if err := ioutil.WriteFile(name, []byte("DB 256"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := Run(name, true); err != nil || result.OK() || result.Updated {
+		t.Fatal(result, err)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "update.bin")); string(b) != "\x01\x02\x03\x00" {
+		t.Fatalf("% x", b)
+	}
+
+	if files, err := Files([]string{dir, name}); err != nil || len(files) != 2 || files[0] != name || files[1] != name {
+		t.Fatal(files, err)
+	}
+}
+
+// Shift_JISのソースコードをオプションで指定した文字コードでアセンブルする
+func TestRun_Encoding(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "asmtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	src, err := japanese.ShiftJIS.NewEncoder().String("DB 'あ' ; expect: 82 A0\nDW 'あ'+0 ; expect: 82 A0\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "sjis.asm")
+	if err := ioutil.WriteFile(name, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Run(name, false, assembler.WithInputEncoding(japanese.ShiftJIS), assembler.WithStringEncoding(japanese.ShiftJIS))
+	if err != nil || !result.OK() || result.Expectations != 2 {
+		t.Fatal(result, err)
+	}
+	if result, err := Run(name, false); err != nil || result.OK() {
+		t.Fatal(result, err)
+	}
+}
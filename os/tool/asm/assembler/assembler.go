@@ -2,68 +2,544 @@ package assembler
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
 
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler/expr"
 	"github.com/nanasi880/til/os/tool/asm/assembler/instruction"
 	"github.com/nanasi880/til/os/tool/asm/assembler/lexer"
 )
 
+// アセンブラ
+// 設定だけを保持し、アセンブル中の状態はExec毎に作成するので、同じインスタンスのExecを繰り返し、または並行して呼び出せる
 type Assembler struct {
-	origin           int64                  // 命令配置基準位置 ORG命令でセットされる
-	address          int64                  // originから現在の命令位置のオフセット
-	sourceLineNumber int                    // 現在解析しているソースコードの行番号
-	labels           map[string]int64       // ラベルの名前:addressの対応表
-	mnemonics        []instruction.Mnemonic // バイナリ先頭からのオペコード一覧
+	origin         int64             // 命令配置基準位置の初期値 ORG命令で上書きされる
+	bits           int               // 機械語命令のモードの初期値 BITS命令で上書きされる 0なら16
+	cpu            instruction.CPU   // 使用できる命令のCPUの初期値 CPU命令で上書きされる 0なら386
+	defines        map[string]int64  // 事前に定義するシンボル
+	includeFS      FileSystem        // INCLUDE命令でファイルを読み込むファイルシステム nilならINCLUDE命令は使用できない
+	format         Format            // 出力形式
+	goPackage      string            // FormatGoのパッケージ名
+	goName         string            // FormatGoの変数名
+	checks         Check             // 出力するイメージの検査
+	sparse         bool              // 出力先がシーク可能であれば、大きなRESBの領域を書き込まずに飛ばすかどうか
+	limits         Limits            // アセンブルの制限
+	inputEncoding  encoding.Encoding // ソースコードの文字コード nilならUTF-8
+	stringEncoding encoding.Encoding // DB命令の文字列を出力する際の文字コード nilならUTF-8
+
+	// 式のパーサー nilならexpr.Parse
+	// ベンチマークで従来のrpn + decimalによる評価と比較するためだけに、テストから設定する
+	parse func(s string) (*expr.Expr, error)
+}
+
+// 1回のアセンブルの状態
+type assembly struct {
+	config           *Assembler               // 設定
+	origin           int64                    // 命令配置基準位置 ORG命令でセットされる
+	address          int64                    // originから現在の命令位置のオフセット
+	bits             int                      // BITS命令で指定されたモード 16 or 32
+	cpu              instruction.CPU          // CPU命令で指定された、使用できる命令のCPU
+	fileName         string                   // 現在解析しているファイル名 メインのソースコードなら空
+	sourceLineNumber int                      // 現在解析しているソースコードの行番号
+	lines            int                      // 解析した行数 INCLUDEしたファイルや展開したマクロの行も含む
+	includeDepth     int                      // INCLUDEのネストの深さ
+	macros           map[string]*macro        // 定義済みのマクロ
+	defining         *macro                   // 定義中のマクロ %macroから%endmacroの間だけnilでない
+	definedAt        position                 // 定義中のマクロの%macroの位置
+	macroDepth       int                      // マクロ展開のネストの深さ
+	expansion        *macroCall               // 展開中のマクロの呼び出し 展開中でなければnil
+	expansions       int                      // マクロを展開した回数 ローカルラベルの名前に使う
+	labels           map[string]int64         // ラベルの名前:アドレス(origin+address)の対応表 EQUの定数も含む
+	equates          map[string]bool          // labelsのうちEQUで定義した定数の名前
+	mnemonics        []instruction.Mnemonic   // バイナリ先頭からのオペコード一覧
+	sources          []sourceRange            // 出力の各範囲がソースコードのどこに書かれていたか アドレス順
+	chunk            *instruction.DB          // 後ろにデータを追加できる、最後に出力したデータ
+	nearJumps        map[int]bool             // SHORTでは届かなかったためNEARでアセンブルし直すジャンプ命令のmnemonics上の位置
+	assertions       []assertion              // ASSERT命令の条件 ラベルの解決後に評価する
+	warnings         []Diagnostic             // %warningなどによる警告
+	files            map[string]*analyzedFile // INCLUDEで読み込んだファイル アセンブルし直す際に再利用する
+	ctx              context.Context          // キャンセルの通知
+}
+
+// ソースコード上の位置
+type position struct {
+	file string // ファイル名 メインのソースコードなら空
+	line int    // 行番号
+}
+
+// 出力の範囲とソースコード上の位置の対応
+// 範囲の終わりは次の要素の開始位置
+type sourceRange struct {
+	offset    int64      // 範囲の開始位置 出力の先頭からのオフセット
+	pos       position   // ソースコード上の位置
+	expansion *macroCall // 範囲を出力したマクロの呼び出し マクロの展開でなければnil
+}
+
+// 字句解析済みのソースコード
+type analyzedFile struct {
+	name    string     // ファイル名 メインのソースコードなら空
+	lines   lexer.File // 空行を除いた各行
+	numbers []int      // linesと同じ並びの行番号
 }
 
+// INCLUDEのネストの深さの上限 自分自身をINCLUDEした場合に止めるためのもの
+const maxIncludeDepth = 64
+
 // 新しいアセンブラインスタンスを作成
-func New() *Assembler {
-	return new(Assembler)
+//
+// @param opts --- オプション
+//
+// @return アセンブラ
+func New(opts ...Option) *Assembler {
+
+	a := new(Assembler)
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
 }
 
 // 指定したファイルのアセンブルを開始
-func (a *Assembler) Exec(sourceFile io.Reader, out io.Writer) error {
+// エラーが発生した場合もそれまでの診断メッセージを含んだ結果を返す
+//
+// @param sourceFile --- ソースコード
+// @param out        --- 出力先
+//
+// @return アセンブル結果、エラー
+func (a *Assembler) Exec(sourceFile io.Reader, out io.Writer) (*Result, error) {
+	return a.ExecContext(context.Background(), sourceFile, out)
+}
+
+// 指定したファイルのアセンブルを開始
+// ctxがキャンセルされるとアセンブルや出力を中断してctx.Err()を返す
+// エラーが発生した場合もそれまでの診断メッセージを含んだ結果を返す
+//
+// @param ctx        --- コンテキスト
+// @param sourceFile --- ソースコード
+// @param out        --- 出力先
+//
+// @return アセンブル結果、エラー
+func (a *Assembler) ExecContext(ctx context.Context, sourceFile io.Reader, out io.Writer) (*Result, error) {
+
+	s := &assembly{
+		config: a,
+		files:  make(map[string]*analyzedFile),
+		ctx:    ctx,
+	}
+
+	result := new(Result)
+	n, err := s.exec(sourceFile, out)
+	result.Size = n
+	result.Diagnostics = append(result.Diagnostics, s.warnings...)
+	result.Symbols = make(map[string]int64, len(s.labels))
+	for name, addr := range s.labels {
+		result.Symbols[name] = addr
+	}
+	result.Constants = make(map[string]bool, len(s.equates))
+	for name := range s.equates {
+		result.Constants[name] = true
+	}
+	result.Sources = make([]SourceRange, len(s.sources))
+	for i, r := range s.sources {
+		result.Sources[i] = SourceRange{Offset: r.offset, File: r.pos.file, Line: r.pos.line}
+	}
+	if err != nil {
+		var e *Error
+		if errors.As(err, &e) {
+			result.Diagnostics = append(result.Diagnostics, e.Diagnostic())
+		} else {
+			result.Diagnostics = append(result.Diagnostics, Diagnostic{Severity: SeverityError, Message: err.Error()})
+		}
+		return result, err
+	}
+	result.SourceMap = s.sourceMap()
+
+	return result, nil
+}
+
+// ソースコードを字句解析する
+//
+// @param name --- ファイル名 メインのソースコードなら空
+// @param src  --- ソースコード
+//
+// @return 字句解析済みのソースコード、エラー
+func (a *Assembler) analyze(name string, src io.Reader) (*analyzedFile, error) {
+
+	// 字句解析器はUTF-8を前提としているので、それ以外の文字コードは事前に変換する
+	if a.inputEncoding != nil {
+		src = transform.NewReader(src, a.inputEncoding.NewDecoder())
+	}
 
-	file, err := lexer.Analyze(sourceFile)
+	lines, numbers, err := lexer.AnalyzeWithLineNumbers(src)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return &analyzedFile{name: name, lines: lines, numbers: numbers}, nil
+}
 
-	a.sourceLineNumber = 1
-	for _, line := range file {
-		if err := a.line(line); err != nil {
-			return err
+// アセンブルして出力する
+//
+// @return 出力したバイト数、エラー
+func (a *assembly) exec(sourceFile io.Reader, out io.Writer) (int64, error) {
+
+	file, err := a.config.analyze("", sourceFile)
+	if err != nil {
+		return 0, err
+	}
+
+	// 距離指定の無い前方へのジャンプはSHORTとしてアセンブルし、届かなかったものをNEARにしてやり直す
+	for {
+		err := a.assemble(file)
+		if err == nil {
+			break
+		}
+		var relocate *relocateError
+		if !errors.As(err, &relocate) || !errors.Is(err, instruction.ErrJumpOutOfRange) || a.nearJumps[relocate.index] {
+			return 0, err
 		}
-		a.sourceLineNumber += 1
+		if a.nearJumps == nil {
+			a.nearJumps = make(map[int]bool)
+		}
+		a.nearJumps[relocate.index] = true
 	}
 
+	var size int64
 	for _, m := range a.mnemonics {
-		if err := m.Relocate(a.labels); err != nil {
-			return err
-		}
+		size += m.Size()
+	}
+	if limit := a.config.limits.MaxOutputSize; limit > 0 && size > limit {
+		return 0, fmt.Errorf("%w: %d bytes (limit %d)", ErrOutputSizeLimit, size, limit)
+	}
+	a.check(size)
+
+	if a.config.format == FormatBinary {
+		return a.writeBinary(out)
+	}
+	e, err := a.encoder(out, size)
+	if err != nil {
+		return 0, err
+	}
+	return a.writeEncoded(e)
+}
+
+// フラットバイナリを出力する
+// WithSparseOutputが指定され出力先がシーク可能であれば、出力先の末尾より後ろにある大きなRESBの領域は書き込まずに飛ばす
+// (Linuxではスパースファイルになる) 出力先の既存の内容に重なるRESBは0を書き込むので、既存の内容が残ったり切り詰められたりすることは無い
+//
+// @return 出力したバイト数、エラー
+func (a *assembly) writeBinary(out io.Writer) (int64, error) {
+
+	var (
+		cw      = &contextWriter{ctx: a.ctx, w: out}
+		w       = bufio.NewWriter(cw)
+		skipped int64 // 書き込まずに飛ばしたバイト数
+	)
+	var (
+		sparse     sparseWriter
+		start, end int64
+		ok         bool
+	)
+	if a.config.sparse {
+		sparse, start, end, ok = sparseOutput(out)
 	}
 
-	w := bufio.NewWriter(out)
 	for _, m := range a.mnemonics {
+		if err := a.ctx.Err(); err != nil {
+			return cw.n + skipped, err
+		}
+
+		resb, isRESB := m.(*instruction.RESB)
+		if ok && isRESB && resb.Size() >= sparseThreshold && start+cw.n+int64(w.Buffered())+skipped >= end {
+			if err := w.Flush(); err != nil {
+				return cw.n + skipped, err
+			}
+			if _, err := sparse.Seek(resb.Size(), io.SeekCurrent); err != nil {
+				return cw.n + skipped, err
+			}
+			skipped += resb.Size()
+			continue
+		}
+
 		if _, err := m.Write(w); err != nil {
-			return err
+			return cw.n + skipped, err
 		}
 	}
 	if err := w.Flush(); err != nil {
+		return cw.n + skipped, err
+	}
+
+	// 最後がRESBだった場合はファイルを伸ばす 飛ばした領域は元の末尾より後ろなので、切り詰めることは無い
+	if ok && skipped > 0 {
+		if err := sparse.Truncate(start + cw.n + skipped); err != nil {
+			return cw.n + skipped, err
+		}
+	}
+
+	return cw.n + skipped, nil
+}
+
+// RESBの領域を書き込まずに飛ばす最小のサイズ
+const sparseThreshold = 4096
+
+// 領域を書き込まずに飛ばせる出力先 (*os.Fileなど)
+type sparseWriter interface {
+	io.Writer
+	io.Seeker
+	Truncate(size int64) error
+}
+
+// 出力先が領域を飛ばして書き込めるかを調べる
+// 末尾より後ろを飛ばした領域だけがゼロになるので、出力先の内容には触れずに現在位置と末尾の位置を返す
+// パイプのようにシークできないものはfalseを返す
+//
+// @param out --- 出力先
+//
+// @return 出力先、現在位置、末尾の位置、領域を飛ばして書き込めるかどうか
+func sparseOutput(out io.Writer) (sparseWriter, int64, int64, bool) {
+
+	sparse, ok := out.(sparseWriter)
+	if !ok {
+		return nil, 0, 0, false
+	}
+	start, err := sparse.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, 0, 0, false
+	}
+	end, err := sparse.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, 0, 0, false
+	}
+	if _, err := sparse.Seek(start, io.SeekStart); err != nil {
+		return nil, 0, 0, false
+	}
+	return sparse, start, end, true
+}
+
+// 書き込んだバイト数を数え、キャンセルされていれば書き込みを中断するio.Writer
+// RESBのように大きな命令の出力中でもキャンセルできるようにする
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+	n   int64 // 書き込んだバイト数
+}
+
+func (w *contextWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// ラベル解決時のエラー
+type relocateError struct {
+	index int    // 命令のmnemonics上の位置
+	err   *Error // 元のエラー
+}
+
+func (e *relocateError) Error() string {
+	return e.err.Error()
+}
+
+func (e *relocateError) Unwrap() error {
+	return e.err
+}
+
+// 字句解析済みのソースコードをアセンブルし、ラベルの解決までを行う
+//
+// @param file --- 字句解析済みのソースコード
+//
+// @return エラー
+func (a *assembly) assemble(file *analyzedFile) error {
+
+	a.origin = a.config.origin
+	a.address = 0
+	a.bits = a.config.bits
+	if a.bits == 0 {
+		a.bits = 16
+	}
+	a.cpu = a.config.cpu
+	if a.cpu == 0 {
+		a.cpu = instruction.CPU386
+	}
+	a.lines = 0
+	a.labels = nil
+	a.equates = nil
+	a.mnemonics = nil
+	a.sources = nil
+	a.chunk = nil
+	a.macros = nil
+	a.expansion = nil
+	a.defining = nil
+	a.expansions = 0
+	a.assertions = nil
+	a.warnings = nil
+
+	if err := a.source(file); err != nil {
+		// 失敗した条件があれば、それが原因である可能性が高いので優先して報告する
+		if failed := a.checkAssertions(a.labels, true); failed != nil {
+			return failed
+		}
 		return err
 	}
+	if a.defining != nil {
+		return &Error{File: a.definedAt.file, Line: a.definedAt.line, Err: fmt.Errorf("マクロ %s が%%endmacroで閉じられていない", a.defining.name)}
+	}
+
+	// 後方参照の式の中で事前定義のシンボルも参照できるようにする
+	symbols := a.labels
+	if len(a.config.defines) > 0 {
+		symbols = make(map[string]int64, len(a.labels)+len(a.config.defines))
+		for name, v := range a.config.defines {
+			symbols[name] = v
+		}
+		for name, v := range a.labels {
+			symbols[name] = v
+		}
+	}
+
+	var offset int64
+	for i, m := range a.mnemonics {
+		if err := m.Relocate(symbols); err != nil {
+			pos := a.positionAt(offset)
+			return &relocateError{index: i, err: &Error{File: pos.file, Line: pos.line, Err: err}}
+		}
+		offset += m.Size()
+	}
+
+	return a.checkAssertions(symbols, false)
+}
+
+// ファイル1つ分の各行を処理する
+//
+// @param file --- 字句解析済みのソースコード
+//
+// @return エラー
+func (a *assembly) source(file *analyzedFile) error {
+
+	fileName, lineNumber := a.fileName, a.sourceLineNumber
+	defer func() {
+		a.fileName, a.sourceLineNumber = fileName, lineNumber
+	}()
+
+	a.fileName = file.name
+	for i, line := range file.lines {
+		a.sourceLineNumber = file.numbers[i]
+		if err := a.ctx.Err(); err != nil {
+			return err
+		}
+		a.lines++
+		if limit := a.config.limits.MaxLines; limit > 0 && a.lines > limit {
+			return a.error(fmt.Errorf("%w: %d lines", ErrLineLimit, limit))
+		}
+		if err := a.line(line); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
+// 現在の行のエラーにする
+// 既に位置を持つエラー(INCLUDEしたファイル内のエラー)はそのまま返す
+//
+// @param err --- エラー
+//
+// @return エラー
+func (a *assembly) error(err error) error {
+
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+	return &Error{File: a.fileName, Line: a.sourceLineNumber, Err: err}
+}
+
+// 命令を追加し、現在の命令位置を進める
+//
+// @param m --- 命令
+func (a *assembly) emit(m instruction.Mnemonic) {
+	a.mnemonics = append(a.mnemonics, m)
+	a.chunk = nil
+	a.mark()
+	a.address += m.Size()
+}
+
+// 値が確定しているデータを出力する
+// 直前もそのようなデータであれば、新しい命令を作らずに後ろに追加する
+//
+// @param b --- データ
+func (a *assembly) emitData(b []byte) {
+
+	if a.chunk != nil {
+		a.chunk.Append(b)
+		a.mark()
+		a.address += int64(len(b))
+		return
+	}
+
+	// 後ろに追加する際に呼び出し元のスライスを書き換えないようにコピーする
+	db := instruction.NewDB(append([]byte(nil), b...))
+	a.emit(db)
+	a.chunk = db
+}
+
+// 現在の命令位置が現在の行から始まることを記録する
+func (a *assembly) mark() {
+
+	pos := position{file: a.fileName, line: a.sourceLineNumber}
+	if n := len(a.sources); n > 0 {
+		last := &a.sources[n-1]
+		if last.pos == pos && last.expansion == a.expansion {
+			return
+		}
+		// 同じ位置から始まる範囲は中身が無いので上書きする
+		if last.offset == a.address {
+			last.pos, last.expansion = pos, a.expansion
+			return
+		}
+	}
+	a.sources = append(a.sources, sourceRange{offset: a.address, pos: pos, expansion: a.expansion})
+}
+
+// 出力のオフセットに対応するソースコード上の位置を返す
+//
+// @param offset --- 出力の先頭からのオフセット
+//
+// @return ソースコード上の位置
+func (a *assembly) positionAt(offset int64) position {
+
+	i := sort.Search(len(a.sources), func(i int) bool {
+		return a.sources[i].offset > offset
+	})
+	if i == 0 {
+		return position{}
+	}
+	return a.sources[i-1].pos
+}
+
 // アセンブリファイル1行分のデータの処理を開始
 //
 // @param line --- 1行分のデータ
 //
 // @return エラー
-func (a *Assembler) line(line lexer.Line) error {
+func (a *assembly) line(line lexer.Line) error {
 
+	if ok, err := a.defineMacro(line); ok {
+		return err
+	}
+
+	if len(line) >= 2 && isEQU(line[1]) {
+		return a.parseEQU(line)
+	}
 	if line[0].Last() == ':' {
 		return a.parseLabel(line)
 	} else {
@@ -72,28 +548,109 @@ func (a *Assembler) line(line lexer.Line) error {
 }
 
 // ラベル行をパースする
-func (a *Assembler) parseLabel(line lexer.Line) error {
+// msg: DB "hi", 0 のようにラベルに続けて命令が書かれている場合は、その命令もアセンブルする
+func (a *assembly) parseLabel(line lexer.Line) error {
 
 	// 末尾のコロンを削除
 	label := string(line[0])
 	label = label[:len(label)-1]
 
 	// 既にラベル名が存在しているのはコンパイルエラー
-	if _, ok := a.labels[label]; ok {
-		return fmt.Errorf("error:%d ラベル名 %s は既に使用されています", a.sourceLineNumber, label)
+	_, defined := a.config.defines[label]
+	if _, ok := a.labels[label]; ok || defined {
+		return a.error(fmt.Errorf("ラベル名 %s は既に使用されています", label))
 	}
 
 	// ラベル名と現在のオフセットアドレスを記憶
 	if a.labels == nil {
 		a.labels = make(map[string]int64)
 	}
-	a.labels[label] = a.address
+	a.labels[label] = a.origin + a.address
 
+	if len(line) > 1 {
+		return a.parseOpCode(splitInstruction(line[1:]))
+	}
 	return nil
 }
 
+// EQU命令の行をパースする
+//
+//	SECTORS EQU 2880
+//
+// 名前に定数を定義する 式はその行で評価するため、前方参照はできない
+// 定数はラベルと同じ名前空間に属するが、ORGで配置位置が変わっても値は変わらない
+func (a *assembly) parseEQU(line lexer.Line) error {
+
+	name := strings.TrimSuffix(string(line[0]), ":")
+	text := strings.TrimSpace(string(line[1])[len("EQU"):])
+	if len(line) != 2 || text == "" {
+		return a.error(fmt.Errorf("EQU命令は1つの式が必要"))
+	}
+
+	_, defined := a.config.defines[name]
+	if _, ok := a.labels[name]; ok || defined {
+		return a.error(fmt.Errorf("ラベル名 %s は既に使用されています", name))
+	}
+
+	e, err := a.parseExpr(text)
+	if err != nil {
+		return a.error(err)
+	}
+	v, err := e.Eval(a.resolver())
+	if err != nil {
+		return a.error(err)
+	}
+
+	if a.labels == nil {
+		a.labels = make(map[string]int64)
+	}
+	if a.equates == nil {
+		a.equates = make(map[string]bool)
+	}
+	a.labels[name] = v
+	a.equates[name] = true
+
+	return nil
+}
+
+// 名前に続くトークンがEQU命令かどうか
+// 字句解析では名前の後の空白までで区切られるので、EQUと式が1つのトークンになる
+func isEQU(t lexer.Token) bool {
+	s := string(t)
+	return s == "EQU" || strings.HasPrefix(s, "EQU ")
+}
+
+// ラベルに続く命令のトークンを、命令名とオペランドに分割する
+// 字句解析では1つ目のトークン(ラベル)の後がカンマで区切られるので、命令名と1つ目のオペランドが1つのトークンになる
+//
+//	msg: DB "hi", 0  →  [msg:] [DB"hi"] [0]  →  [DB] ["hi"] [0]
+//
+// @param tokens --- ラベルの後のトークン
+//
+// @return 命令名から始まるトークンの一覧
+func splitInstruction(tokens []lexer.Token) lexer.Line {
+
+	s := string(tokens[0])
+	n := 0
+	for n < len(s) && isMnemonicChar(s[n]) {
+		n++
+	}
+	if n == 0 || n == len(s) {
+		return tokens
+	}
+
+	result := make(lexer.Line, 0, len(tokens)+1)
+	result = append(result, lexer.Token(s[:n]), lexer.Token(strings.TrimPrefix(s[n:], " ")))
+	return append(result, tokens[1:]...)
+}
+
+// 命令名に使用できる文字かどうか
+func isMnemonicChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_'
+}
+
 // オペレーションコード行をパースする
-func (a *Assembler) parseOpCode(line lexer.Line) error {
+func (a *assembly) parseOpCode(line lexer.Line) error {
 
 	var (
 		mnemonic   = line[0]
@@ -107,25 +664,45 @@ func (a *Assembler) parseOpCode(line lexer.Line) error {
 	return nil
 }
 
-func (a *Assembler) relocate(out io.Writer) error {
-
-	bo := bufio.NewWriter(out)
+// INCLUDE命令
+// 指定したファイルをその位置に書かれているものとしてアセンブルする
+//
+// @param parameters --- パラメーター ファイル名の文字列
+//
+// @return エラー
+func (a *assembly) mnemonicINCLUDE(parameters []lexer.Token) error {
 
-	for _, o := range a.mnemonics {
+	if len(parameters) != 1 || !parameters[0].Quoted() {
+		return fmt.Errorf("INCLUDE命令はファイル名の文字列が1つ必要")
+	}
+	name, err := lexer.Unquote(parameters[0])
+	if err != nil {
+		return err
+	}
+	if a.config.includeFS == nil {
+		return fmt.Errorf("INCLUDE命令を使用するにはファイルシステムを指定する必要がある")
+	}
+	if a.includeDepth >= maxIncludeDepth {
+		return fmt.Errorf("INCLUDEのネストが深すぎる: %s", name)
+	}
 
-		if err := o.Relocate(a.labels); err != nil {
+	file, ok := a.files[string(name)]
+	if !ok {
+		f, err := a.config.includeFS.Open(string(name))
+		if err != nil {
 			return err
 		}
-
-		_, err := o.Write(bo)
+		file, err = a.config.analyze(string(name), f)
+		f.Close()
 		if err != nil {
 			return err
 		}
+		a.files[string(name)] = file
 	}
 
-	if err := bo.Flush(); err != nil {
-		return err
-	}
-
-	return nil
+	a.includeDepth++
+	defer func() {
+		a.includeDepth--
+	}()
+	return a.source(file)
 }
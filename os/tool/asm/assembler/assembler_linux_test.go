@@ -0,0 +1,74 @@
+package assembler
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"go.nanasi880.dev/xtesting"
+)
+
+// RESBの領域がディスク上に確保されていないこと
+func TestAssembler_SparseBlocks(t *testing.T) {
+
+	src, err := ioutil.ReadFile("testdata/helloos.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "asm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f, err := os.Create(filepath.Join(dir, "sparse.img"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer xtesting.MustClose(t, f)
+
+	if _, err := New(WithSparseOutput()).Exec(bytes.NewReader(src), f); err != nil {
+		t.Fatal(err)
+	}
+	size, allocated := allocatedSize(t, f)
+
+	// 同じサイズのファイルを全て書き込んで作り、ゼロの領域が確保されないファイルシステムであればスキップする
+	ref, err := os.Create(filepath.Join(dir, "dense.img"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer xtesting.MustClose(t, ref)
+
+	if _, err := ref.Write(make([]byte, size)); err != nil {
+		t.Fatal(err)
+	}
+	if refSize, refAllocated := allocatedSize(t, ref); refAllocated < refSize {
+		t.Skipf("file system does not allocate blocks for zeros: %d/%d", refAllocated, refSize)
+	}
+
+	if allocated >= size {
+		t.Fatalf("RESB area is allocated: %d/%d", allocated, size)
+	}
+}
+
+// ファイルのサイズとディスク上に確保されたバイト数を返す
+func allocatedSize(t *testing.T, f *os.File) (int64, int64) {
+
+	t.Helper()
+	if err := f.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		t.Skip("block count is not available")
+	}
+	return info.Size(), stat.Blocks * 512
+}
@@ -1,19 +1,17 @@
 package assembler
 
 import (
-	"encoding/binary"
+	"errors"
 	"fmt"
 	"strings"
 
-	"github.com/shopspring/decimal"
-	"go.nanasi880.dev/rpn"
-
+	"github.com/nanasi880/til/os/tool/asm/assembler/expr"
 	"github.com/nanasi880/til/os/tool/asm/assembler/instruction"
 	"github.com/nanasi880/til/os/tool/asm/assembler/lexer"
 	"github.com/nanasi880/til/os/tool/asm/internal"
 )
 
-func (a *Assembler) parseMnemonic(mnemonic lexer.Token, parameters []lexer.Token) error {
+func (a *assembly) parseMnemonic(mnemonic lexer.Token, parameters []lexer.Token) error {
 
 	var (
 		err error
@@ -32,63 +30,149 @@ func (a *Assembler) parseMnemonic(mnemonic lexer.Token, parameters []lexer.Token
 	case "DD":
 		err = a.mnemonicMultiWord(parameters, 4)
 
+	// data quad word
+	case "DQ":
+		err = a.mnemonicMultiWord(parameters, 8)
+
+	// data ten bytes
+	case "DT":
+		err = a.mnemonicMultiWord(parameters, 10)
+
 	// reserve byte
 	case "RESB":
 		err = a.mnemonicRESB(parameters)
 
+	// origin
+	case "ORG":
+		err = a.mnemonicORG(parameters)
+
+	// 16bit / 32bit mode
+	case "BITS":
+		err = a.mnemonicBITS(parameters)
+
+	// target cpu
+	case "CPU":
+		err = a.mnemonicCPU(parameters)
+
+	// include file
+	case "INCLUDE":
+		err = a.mnemonicINCLUDE(parameters)
+
+	// repeat prefix
+	case "REP", "REPE", "REPZ", "REPNE", "REPNZ":
+		err = a.mnemonicREP(mnemonic, parameters)
+
+	// assemble-time assertion
+	case "ASSERT":
+		err = a.mnemonicASSERT(parameters)
+
+	// user error / warning
+	case "%error", "%warning":
+		err = a.directiveMessage(mnemonic, parameters)
+
 	default:
-		return fmt.Errorf("error:%d unknown mnemonic `%s`", a.sourceLineNumber, mnemonic)
+		if m, ok := a.macros[string(mnemonic)]; ok {
+			err = a.expandMacro(m, parameters)
+			break
+		}
+		if !instruction.IsX86Mnemonic(string(mnemonic)) {
+			return a.error(fmt.Errorf("unknown mnemonic `%s`", mnemonic))
+		}
+		err = a.mnemonicX86(mnemonic, parameters)
 	}
 
 	if err != nil {
-		return fmt.Errorf("error:%d %s", a.sourceLineNumber, err.Error())
+		return a.error(err)
 	}
 
 	return nil
 }
 
 // 変数解決のリゾルバを取得する
+// $は現在の命令位置、$$はセクションの先頭位置(ORGで指定した位置)を表す
 //
 // @return リゾルバ
-func (a *Assembler) Resolver() rpn.Resolver {
+func (a *assembly) resolver() expr.Resolver {
 
-	return func(name string) (decimal.Decimal, error) {
+	return func(name string) (int64, error) {
 
-		if name == "$" {
-			return decimal.New(a.address, 0), nil
+		switch name {
+		case "$":
+			return a.origin + a.address, nil
+		case "$$":
+			return a.origin, nil
+		}
+		if v, ok := a.labels[name]; ok {
+			return v, nil
 		}
-		return decimal.Zero, fmt.Errorf("undeclared variable: %s", name)
+		if v, ok := a.config.defines[name]; ok {
+			return v, nil
+		}
+		return 0, expr.UndefinedSymbol(name)
 	}
 }
 
+// 浮動小数点数リテラル
+type floatLiteral string
+
 // トークン列をパラメーターだと仮定してデコードする
-// トークンがクォートされていない場合、それを式と解釈する
-// それ以外はレジスタ名も含めてstringとして取り扱う
+// 式をパースする
+// 文字定数はDB命令の文字列と同じ文字コードで解釈する
 //
-// @param parameters --- 分割対象文字列
+// @param s --- 式
 //
-// @return *rpn.RPN or stringの混合スライス、エラー
-func (a *Assembler) decodeParameters(parameters []lexer.Token) ([]interface{}, error) {
+// @return パース済みの式、エラー
+func (a *assembly) parseExpr(s string) (*expr.Expr, error) {
 
-	// トークンがエスケープされているかどうかを返す
-	quoted := func(tok lexer.Token) bool {
-		if tok[0] == '"' && tok.Last() == '"' && len(tok) >= 2 {
-			return true
-		}
-		return false
+	parse := expr.Parse
+	if a.config.parse != nil {
+		parse = a.config.parse
+	}
+	e, err := parse(s)
+	if err != nil {
+		return nil, err
 	}
+	if err := a.encodeChars(e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// 式の文字定数を、DB命令の文字列と同じ文字コードで解釈し直す
+//
+// @param e --- 式 nilなら何もしない
+//
+// @return エラー
+func (a *assembly) encodeChars(e *expr.Expr) error {
+	if e == nil || a.config.stringEncoding == nil {
+		return nil
+	}
+	return e.EncodeChars(a.config.stringEncoding)
+}
+
+// トークン全体がクォートされていない場合、それを式と解釈する
+// 式の中に含まれるクォートされた文字列は文字定数として扱われる
+// 小数点を含む数値は式ではなく浮動小数点数リテラルとして扱われる
+// それ以外はレジスタ名も含めてstringとして取り扱う
+//
+// @param parameters --- 分割対象文字列
+//
+// @return *expr.Expr or floatLiteral or stringの混合スライス、エラー
+func (a *assembly) decodeParameters(parameters []lexer.Token) ([]interface{}, error) {
 
 	var result []interface{}
 	for _, p := range parameters {
 
-		if quoted(p) {
+		if p.Quoted() {
 			result = append(result, string(p))
+		} else if expr.IsFloatLiteral(string(p)) {
+			result = append(result, floatLiteral(p))
 		} else {
-			rpnObject, err := rpn.Parse(string(p))
+			e, err := a.parseExpr(string(p))
 			if err != nil {
 				return nil, err
 			}
-			result = append(result, rpnObject)
+			result = append(result, e)
 		}
 	}
 
@@ -100,23 +184,26 @@ func (a *Assembler) decodeParameters(parameters []lexer.Token) ([]interface{}, e
 // @param parameters --- パラメーター
 //
 // @return オペレーション一覧、エラー
-func (a *Assembler) mnemonicDB(parameters []lexer.Token) error {
+func (a *assembly) mnemonicDB(parameters []lexer.Token) error {
 
 	return a.mnemonicMultiWordWithConverter(parameters, func(v interface{}) ([]byte, error) {
 
 		switch v := v.(type) {
 
 		case string:
-			v = strings.TrimFunc(v, func(r rune) bool {
-				return r == '"'
-			})
-			return []byte(v), nil
+			if a.config.stringEncoding != nil {
+				return lexer.UnquoteWith(lexer.Token(v), a.config.stringEncoding.NewEncoder())
+			}
+			return lexer.Unquote(lexer.Token(v))
 
 		case int64:
-			if v > 0xFF || v < 0 {
-				return nil, fmt.Errorf("DB命令の即値は0x00 ~ 0xFFの範囲である必要がある")
-			}
-			return []byte{byte(v)}, nil
+			return encodeInt("DB", v, 1)
+
+		case uint64:
+			return encodeUint("DB", v, 1)
+
+		case floatLiteral:
+			return nil, fmt.Errorf("DB命令は浮動小数点数は使用できない")
 
 		default:
 			return nil, nil
@@ -124,47 +211,109 @@ func (a *Assembler) mnemonicDB(parameters []lexer.Token) error {
 	})
 }
 
-// DW命令 / DD命令
+// データ定義命令のサイズと命令名の対応表
+var dataMnemonics = map[int]string{1: "DB", 2: "DW", 4: "DD", 8: "DQ", 10: "DT"}
+
+// DW命令 / DD命令 / DQ命令 / DT命令
+// DD, DQ, DTでは整数の他に浮動小数点数リテラルを使用できる
 //
 // @param parameters --- パラメーター
 // @param size       --- 命令サイズ
-//                       2ならDW、4ならDDと解釈される
+//                       2ならDW、4ならDD、8ならDQ、10ならDTと解釈される
 //
 // @return オペレーション一覧、エラー
-func (a *Assembler) mnemonicMultiWord(parameters []lexer.Token, size int) error {
+func (a *assembly) mnemonicMultiWord(parameters []lexer.Token, size int) error {
+
+	mnemonic := dataMnemonics[size]
 
-	var (
-		mnemonic       = "DW"
-		max      int64 = 0xFFFF
-	)
-	if size == 4 {
-		mnemonic = "DD"
-		max = 0xFFFFFFFF
-	}
 	return a.mnemonicMultiWordWithConverter(parameters, func(v interface{}) ([]byte, error) {
 
-		i, ok := v.(int64)
-		if !ok {
+		switch v := v.(type) {
+
+		case int64:
+			return encodeInt(mnemonic, v, size)
+
+		case uint64:
+			return encodeUint(mnemonic, v, size)
+
+		case floatLiteral:
+			if size == 2 {
+				return nil, fmt.Errorf("%s命令は浮動小数点数は使用できない", mnemonic)
+			}
+			return encodeFloat(string(v), size)
+
+		default:
 			return nil, fmt.Errorf("%s命令は文字列は使用できない", mnemonic)
 		}
+	})
+}
 
-		if i > max || i < 0 {
-			return nil, fmt.Errorf("%s命令の即値は0x00 ~ 0x%Xの範囲である必要がある", mnemonic, max)
+// 整数を指定したサイズのリトルエンディアンのバイト列に変換する
+// 8バイト未満の場合は、符号付き・符号なしのどちらかの範囲に収まっていれば良い (DW -1 は0xFFFFとなる)
+// 8バイトを超える場合は符号拡張される
+//
+// @param mnemonic --- 命令名 エラーメッセージ用
+// @param v        --- 値
+// @param size     --- バイト数
+//
+// @return バイト列、エラー
+func encodeInt(mnemonic string, v int64, size int) ([]byte, error) {
+
+	if size < 8 {
+		var (
+			bits       = uint(size * 8)
+			min  int64 = -1 << (bits - 1)
+			max  int64 = 1<<bits - 1
+		)
+		if v < min || v > max {
+			return nil, fmt.Errorf("%s命令の即値は-0x%X ~ 0x%Xの範囲である必要がある", mnemonic, -min, max)
 		}
+	}
 
-		if size == 4 {
-			bytes := make([]byte, 4)
-			binary.LittleEndian.PutUint32(bytes, uint32(i))
-			return bytes, nil
-		} else {
-			bytes := make([]byte, 2)
-			binary.LittleEndian.PutUint16(bytes, uint16(i))
-			return bytes, nil
-		}
-	})
+	b := make([]byte, size)
+	for i := range b {
+		b[i] = byte(v >> uint(8*i))
+	}
+	return b, nil
 }
 
-func (a *Assembler) mnemonicMultiWordWithConverter(parameters []lexer.Token, c func(v interface{}) ([]byte, error)) error {
+// 2^63以上の符号なし整数を指定したサイズのリトルエンディアンのバイト列に変換する
+// 8バイト未満には収まらないのでエラーとなり、8バイトを超える場合はゼロ拡張される
+//
+// @param mnemonic --- 命令名 エラーメッセージ用
+// @param v        --- 値
+// @param size     --- バイト数
+//
+// @return バイト列、エラー
+func encodeUint(mnemonic string, v uint64, size int) ([]byte, error) {
+
+	if size < 8 {
+		// 範囲外のエラーはencodeIntと同じ形式にする
+		return encodeInt(mnemonic, 1<<uint(size*8), size)
+	}
+
+	b := make([]byte, size)
+	for i := 0; i < 8; i++ {
+		b[i] = byte(v >> uint(8*i))
+	}
+	return b, nil
+}
+
+// 式の評価結果をデータ定義命令のコンバーターに渡す値にする
+// 2^63以上の数値リテラルによって負になった値は、符号なしの値としてuint64で渡す
+//
+// @param e --- 式
+// @param v --- 評価結果
+//
+// @return int64 or uint64
+func dataValue(e *expr.Expr, v int64) interface{} {
+	if v < 0 && e.Unsigned() {
+		return uint64(v)
+	}
+	return v
+}
+
+func (a *assembly) mnemonicMultiWordWithConverter(parameters []lexer.Token, c func(v interface{}) ([]byte, error)) error {
 
 	if len(parameters) == 0 {
 		return fmt.Errorf("最低1つのパラメーターが必要")
@@ -183,21 +332,40 @@ func (a *Assembler) mnemonicMultiWordWithConverter(parameters []lexer.Token, c f
 			if err != nil {
 				return err
 			}
-			a.mnemonics = append(a.mnemonics, instruction.NewDB(b))
-			a.address += int64(len(b))
+			a.emitData(b)
+
+		case *expr.Expr:
+			v, err := p.Eval(a.resolver())
+
+			// 後方で定義されるラベルを参照している場合は、ラベル解決時に値を確定させる
+			var undefined *expr.UndefinedSymbolError
+			if errors.As(err, &undefined) {
+				zero, err := c(int64(0))
+				if err != nil {
+					return err
+				}
+				db := instruction.NewDBExpr(int64(len(zero)), p, a.origin+a.address, a.origin, func(v int64) ([]byte, error) {
+					return c(dataValue(p, v))
+				})
+				a.emit(db)
+				continue
+			}
+			if err != nil {
+				return err
+			}
 
-		case *rpn.RPN:
-			d, err := p.Eval(a.Resolver())
+			b, err := c(dataValue(p, v))
 			if err != nil {
 				return err
 			}
+			a.emitData(b)
 
-			b, err := c(d.IntPart())
+		case floatLiteral:
+			b, err := c(p)
 			if err != nil {
 				return err
 			}
-			a.mnemonics = append(a.mnemonics, instruction.NewDB(b))
-			a.address += int64(len(b))
+			a.emitData(b)
 
 		default:
 			return fmt.Errorf("internal: %#v", p)
@@ -212,21 +380,20 @@ func (a *Assembler) mnemonicMultiWordWithConverter(parameters []lexer.Token, c f
 // @param parameter --- パラメーター
 //
 // @return エラー
-func (a *Assembler) mnemonicRESB(parameters []lexer.Token) error {
+func (a *assembly) mnemonicRESB(parameters []lexer.Token) error {
 
 	if len(parameters) != 1 {
 		return fmt.Errorf("RESB命令は1つのパラメーターが必要")
 	}
 
-	rpnObject, err := rpn.Parse(string(parameters[0]))
+	e, err := a.parseExpr(string(parameters[0]))
 	if err != nil {
 		return err
 	}
-	d, err := rpnObject.Eval(a.Resolver())
+	v, err := e.Eval(a.resolver())
 	if err != nil {
 		return err
 	}
-	v := d.IntPart()
 
 	if v < 0 {
 		return fmt.Errorf("RESB underflow: %d", v)
@@ -235,9 +402,166 @@ func (a *Assembler) mnemonicRESB(parameters []lexer.Token) error {
 		return fmt.Errorf("RESB overflow: %d", v)
 	}
 
-	resb := instruction.NewRESB(v)
-	a.mnemonics = append(a.mnemonics, resb)
-	a.address += resb.Size()
+	a.emit(instruction.NewRESB(v))
+
+	return nil
+}
+
+// ORG命令
+// 以降の命令が配置されるアドレスを指定する ソースコードの先頭でのみ使用できる
+//
+// @param parameters --- パラメーター
+//
+// @return エラー
+func (a *assembly) mnemonicORG(parameters []lexer.Token) error {
+
+	if len(parameters) != 1 {
+		return fmt.Errorf("ORG命令は1つのパラメーターが必要")
+	}
+	if a.address != 0 {
+		return fmt.Errorf("ORG命令は命令より前に記述する必要がある")
+	}
+
+	e, err := a.parseExpr(string(parameters[0]))
+	if err != nil {
+		return err
+	}
+	v, err := e.Eval(a.resolver())
+	if err != nil {
+		return err
+	}
+	if v < 0 {
+		return fmt.Errorf("ORG underflow: %d", v)
+	}
+
+	// ORGより前に定義されたラベルも新しい配置位置に合わせる
+	for name, addr := range a.labels {
+		if !a.equates[name] {
+			a.labels[name] = addr - a.origin + v
+		}
+	}
+	a.origin = v
+
+	return nil
+}
+
+// BITS命令
+// 以降の機械語命令を16bitモード、32bitモードのどちらでアセンブルするかを指定する
+//
+// @param parameters --- パラメーター
+//
+// @return エラー
+func (a *assembly) mnemonicBITS(parameters []lexer.Token) error {
+
+	if len(parameters) != 1 || (parameters[0] != "16" && parameters[0] != "32") {
+		return fmt.Errorf("BITS命令のパラメーターは16または32である必要がある")
+	}
+
+	if parameters[0] == "16" {
+		a.bits = 16
+	} else {
+		a.bits = 32
+	}
+	return nil
+}
+
+// CPU命令
+// 以降の機械語命令で使用できるCPUを指定する 指定したCPUより新しいCPUの命令はエラーになる
+//
+//	CPU 8086
+//
+// @param parameters --- パラメーター
+//
+// @return エラー
+func (a *assembly) mnemonicCPU(parameters []lexer.Token) error {
+
+	if len(parameters) == 1 {
+		switch parameters[0] {
+		case "8086":
+			a.cpu = instruction.CPU8086
+			return nil
+		case "186":
+			a.cpu = instruction.CPU186
+			return nil
+		case "386":
+			a.cpu = instruction.CPU386
+			return nil
+		}
+	}
+	return fmt.Errorf("CPU命令のパラメーターは8086、186または386である必要がある")
+}
+
+// 機械語命令
+//
+// @param mnemonic   --- ニーモニック
+// @param parameters --- パラメーター
+//
+// @return エラー
+func (a *assembly) mnemonicX86(mnemonic lexer.Token, parameters []lexer.Token) error {
+
+	operands := make([]instruction.Operand, 0, len(parameters))
+	for _, p := range parameters {
+
+		op, err := instruction.ParseOperand(string(p))
+		if err != nil {
+			return err
+		}
+		switch op := op.(type) {
+		case instruction.Immediate:
+			err = a.encodeChars(op.Value)
+		case instruction.Memory:
+			err = a.encodeChars(op.Disp)
+		}
+		if err != nil {
+			return err
+		}
+
+		// SHORTで届かなかったジャンプ命令はNEARでアセンブルする
+		if imm, ok := op.(instruction.Immediate); ok && imm.Distance == instruction.DistanceAuto && a.nearJumps[len(a.mnemonics)] {
+			imm.Distance = instruction.DistanceNear
+			op = imm
+		}
+		operands = append(operands, op)
+	}
+
+	x, err := instruction.NewX86(string(mnemonic), operands, a.bits, a.cpu, a.origin+a.address, a.origin, a.resolver())
+	if err != nil {
+		return err
+	}
+	a.emit(x)
 
 	return nil
 }
+
+// REPプレフィックスのバイト
+var repPrefixes = map[lexer.Token]byte{
+	"REP":   0xF3,
+	"REPE":  0xF3,
+	"REPZ":  0xF3,
+	"REPNE": 0xF2,
+	"REPNZ": 0xF2,
+}
+
+// REP / REPE / REPNE プレフィックス
+// 後ろに続く文字列命令 (MOVSB, STOSW など) の前にプレフィックスを出力する
+//
+// @param prefix     --- プレフィックス
+// @param parameters --- パラメーター 文字列命令のニーモニック
+//
+// @return エラー
+func (a *assembly) mnemonicREP(prefix lexer.Token, parameters []lexer.Token) error {
+
+	if len(parameters) != 1 {
+		return fmt.Errorf("%sの後には文字列命令が1つ必要", prefix)
+	}
+
+	mnemonic := parameters[0]
+	switch strings.TrimRight(string(mnemonic), "BWD") {
+	case "MOVS", "STOS", "LODS", "CMPS", "SCAS":
+	default:
+		return fmt.Errorf("%sは文字列命令にしか使用できない: %s", prefix, mnemonic)
+	}
+
+	a.emit(instruction.NewDB([]byte{repPrefixes[prefix]}))
+	return a.mnemonicX86(mnemonic, nil)
+}
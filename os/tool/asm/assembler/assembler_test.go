@@ -2,9 +2,25 @@ package assembler
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/shopspring/decimal"
+	"go.nanasi880.dev/rpn"
 	"go.nanasi880.dev/xtesting"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler/expr"
+	"github.com/nanasi880/til/os/tool/asm/assembler/instruction"
+	"github.com/nanasi880/til/os/tool/asm/emulator"
 )
 
 var (
@@ -40,6 +56,22 @@ func makeHellosImage() []byte {
 	return bin
 }
 
+// 出力がリファレンスのイメージと一致することを確認する
+// 異なる場合は、その範囲と範囲を出力したソースコードの行を報告する
+//
+// @param got    --- アセンブルした出力
+// @param result --- アセンブル結果
+// @param want   --- リファレンスのイメージ
+func assertImage(t *testing.T, got []byte, result *Result, want []byte) {
+
+	t.Helper()
+
+	diffs := Diff(got, want, result.Sources)
+	if len(diffs) > 0 {
+		t.Fatalf("%d bytes, want %d bytes\n%s", len(got), len(want), FormatDiff(diffs, 10))
+	}
+}
+
 func TestAssembler_DB(t *testing.T) {
 
 	asmFile := xtesting.MustOpen(t, "testdata/db_only.asm.txt")
@@ -47,7 +79,7 @@ func TestAssembler_DB(t *testing.T) {
 
 	a := new(Assembler)
 	b := new(bytes.Buffer)
-	err := a.Exec(asmFile, b)
+	_, err := a.Exec(asmFile, b)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -63,14 +95,12 @@ func TestAssembler_RESB(t *testing.T) {
 
 	a := new(Assembler)
 	b := new(bytes.Buffer)
-	err := a.Exec(asmFile, b)
+	result, err := a.Exec(asmFile, b)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if bytes.Compare(b.Bytes(), hellosImage) != 0 {
-		t.Fatal()
-	}
+	assertImage(t, b.Bytes(), result, hellosImage)
 }
 
 func TestAssembler_DWDD(t *testing.T) {
@@ -80,14 +110,12 @@ func TestAssembler_DWDD(t *testing.T) {
 
 	a := new(Assembler)
 	b := new(bytes.Buffer)
-	err := a.Exec(asmFile, b)
+	result, err := a.Exec(asmFile, b)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if bytes.Compare(b.Bytes(), hellosImage) != 0 {
-		t.Fatal()
-	}
+	assertImage(t, b.Bytes(), result, hellosImage)
 }
 
 func TestAssembler_Val(t *testing.T) {
@@ -97,12 +125,997 @@ func TestAssembler_Val(t *testing.T) {
 
 	a := new(Assembler)
 	b := new(bytes.Buffer)
-	err := a.Exec(asmFile, b)
+	result, err := a.Exec(asmFile, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertImage(t, b.Bytes(), result, hellosImage)
+}
+
+func TestAssembler_Instruction(t *testing.T) {
+
+	asmFile := xtesting.MustOpen(t, "testdata/helloos.txt")
+	defer xtesting.MustClose(t, asmFile)
+
+	a := new(Assembler)
+	b := new(bytes.Buffer)
+	result, err := a.Exec(asmFile, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertImage(t, b.Bytes(), result, hellosImage)
+}
+
+// イメージを実行して期待通りの文字が表示されることを確認する
+func TestAssembler_Run(t *testing.T) {
+
+	result, err := emulator.Run(hellosImage, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Reason != emulator.StopHalt {
+		t.Fatal(result.Reason)
+	}
+	if !strings.Contains(string(result.Output), "hello, world") {
+		t.Fatalf("%q", result.Output)
+	}
+}
+
+func TestAssembler_Jump(t *testing.T) {
+
+	testCases := []struct {
+		src  string
+		want []byte
+	}{
+		// 後方へのジャンプは届く範囲でSHORTになる
+		{src: "ORG 0x7c00\nJMP next\nnext:\nJE next", want: []byte{0xeb, 0x00, 0x74, 0xfe}},
+		// 届かない場合はNEARでアセンブルし直される
+		{src: "JMP far\nRESB 200\nfar:", want: append([]byte{0xe9, 0xc8, 0x00}, make([]byte, 200)...)},
+		{src: "JE far\nRESB 200\nfar:", want: append([]byte{0x0f, 0x84, 0xc8, 0x00}, make([]byte, 200)...)},
+		{src: "back:\nRESB 200\nJMP back", want: append(make([]byte, 200), 0xe9, 0x35, 0xff)},
+		{src: "JMP NEAR next\nnext:", want: []byte{0xe9, 0x00, 0x00}},
+		// ラベルと同じ行の命令
+		{src: "loop: JMP loop", want: []byte{0xeb, 0xfe}},
+		{src: "BITS 32\nJMP NEAR next\nnext:\nPUSH 0x1234", want: []byte{0xe9, 0x00, 0x00, 0x00, 0x00, 0x68, 0x34, 0x12, 0x00, 0x00}},
+	}
+
+	for i, tt := range testCases {
+
+		a := New()
+		b := new(bytes.Buffer)
+		if _, err := a.Exec(strings.NewReader(tt.src), b); err != nil {
+			t.Fatal(i, err)
+		}
+		if bytes.Compare(b.Bytes(), tt.want) != 0 {
+			t.Fatalf("%d: % x", i, b.Bytes())
+		}
+	}
+
+	// SHORTを明示した場合は届かなければエラー
+	_, err := New().Exec(strings.NewReader("JMP SHORT far\nRESB 200\nfar:"), new(bytes.Buffer))
+	if err == nil || !strings.HasPrefix(err.Error(), "error:1 ") {
+		t.Fatal(err)
+	}
+}
+
+func TestAssembler_CPU(t *testing.T) {
+
+	testCases := []struct {
+		opts []Option
+		src  string
+		want []byte
+		err  string
+	}{
+		{src: "PUSH 1", want: []byte{0x6a, 0x01}},
+		{src: "CPU 186\nPUSH 1\nSHL AX,2", want: []byte{0x6a, 0x01, 0xc1, 0xe0, 0x02}},
+		{src: "CPU 8086\nPUSH 1", err: "error:2 PUSH requires CPU 186"},
+		{src: "CPU 8086\nSHL AX,1\nPUSH AX", want: []byte{0xd1, 0xe0, 0x50}},
+		{src: "CPU 286", err: "error:1 "},
+		{opts: []Option{WithCPU(instruction.CPU8086)}, src: "CPU 386\nPUSH 1", want: []byte{0x6a, 0x01}},
+		{opts: []Option{WithCPU(instruction.CPU8086)}, src: "MOV EAX,1", err: "error:1 MOV requires CPU 386"},
+		// 8086には16bitの相対アドレスの条件ジャンプが無いので、届かない条件ジャンプはNEARにせずエラーにする
+		{opts: []Option{WithCPU(instruction.CPU8086)}, src: "JE next\nnext:", want: []byte{0x74, 0x00}},
+		{opts: []Option{WithCPU(instruction.CPU8086)}, src: "JE far\nRESB 200\nfar:", err: "error:1 JE requires CPU 386"},
+		{opts: []Option{WithCPU(instruction.CPU8086)}, src: "JMP far\nRESB 200\nfar:", want: append([]byte{0xe9, 0xc8, 0x00}, make([]byte, 200)...)},
+	}
+
+	for i, tt := range testCases {
+
+		b := new(bytes.Buffer)
+		_, err := New(tt.opts...).Exec(strings.NewReader(tt.src), b)
+		if tt.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Fatal(i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if bytes.Compare(b.Bytes(), tt.want) != 0 {
+			t.Fatalf("%d: % x", i, b.Bytes())
+		}
+	}
+}
+
+func TestAssembler_DBString(t *testing.T) {
+
+	asmFile := xtesting.MustOpen(t, "testdata/db_string.txt")
+	defer xtesting.MustClose(t, asmFile)
+
+	a := new(Assembler)
+	b := new(bytes.Buffer)
+	_, err := a.Exec(asmFile, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{
+		'a', '\n', '\t', 0x00, 'b', '\'', 'c', 'A', 'A',
+		'B', '9',
+		0xE3, 0x81, 0x82, 0xE3, 0x81, 0x82,
+		';', '\\',
+	}
+	if bytes.Compare(b.Bytes(), want) != 0 {
+		t.Fatalf("% X", b.Bytes())
+	}
+}
+
+func TestAssembler_StringEncoding(t *testing.T) {
+
+	testCases := []struct {
+		stringEncoding encoding.Encoding
+		want           []byte
+	}{
+		{
+			stringEncoding: nil,
+			want:           []byte("こんにちは世界\n"),
+		},
+		{
+			stringEncoding: japanese.ShiftJIS,
+			want:           []byte{0x82, 0xB1, 0x82, 0xF1, 0x82, 0xC9, 0x82, 0xBF, 0x82, 0xCD, 0x90, 0xA2, 0x8A, 0x45, 0x0A},
+		},
+		{
+			stringEncoding: japanese.EUCJP,
+			want:           []byte{0xA4, 0xB3, 0xA4, 0xF3, 0xA4, 0xCB, 0xA4, 0xC1, 0xA4, 0xCF, 0xC0, 0xA4, 0xB3, 0xA6, 0x0A},
+		},
+	}
+
+	for i, tt := range testCases {
+
+		asmFile := xtesting.MustOpen(t, "testdata/sjis.txt")
+
+		a := New(WithInputEncoding(japanese.ShiftJIS), WithStringEncoding(tt.stringEncoding))
+		b := new(bytes.Buffer)
+		_, err := a.Exec(asmFile, b)
+		xtesting.MustClose(t, asmFile)
+		if err != nil {
+			t.Fatal(i, " ", err)
+		}
+
+		if bytes.Compare(b.Bytes(), tt.want) != 0 {
+			t.Fatalf("%d: % X", i, b.Bytes())
+		}
+	}
+}
+
+// 文字定数も文字列と同じ文字コードで解釈される
+func TestAssembler_CharEncoding(t *testing.T) {
+
+	src := "DW 'あ'+0\nMOV AX,'あ'\nDB 'あ'\nDB 'A'+1"
+	b := new(bytes.Buffer)
+	if _, err := New(WithStringEncoding(japanese.ShiftJIS)).Exec(strings.NewReader(src), b); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x82, 0xA0, 0xB8, 0x82, 0xA0, 0x82, 0xA0, 'B'}; bytes.Compare(b.Bytes(), want) != 0 {
+		t.Fatalf("% X", b.Bytes())
+	}
+}
+
+func TestAssembler_Label(t *testing.T) {
+
+	asmFile := xtesting.MustOpen(t, "testdata/label.txt")
+	defer xtesting.MustClose(t, asmFile)
+
+	a := New()
+	b := new(bytes.Buffer)
+	_, err := a.Exec(asmFile, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{
+		0x0C, 0x00,
+		'B', 0xFF,
+		0x34, 0x12, 0x01, 0x00,
+		0x08, 0x00, 0x0A, 0x00,
+	}
+	if bytes.Compare(b.Bytes(), want) != 0 {
+		t.Fatalf("% X", b.Bytes())
+	}
+}
+
+func TestAssembler_UndefinedLabel(t *testing.T) {
+
+	_, err := New().Exec(strings.NewReader("DW nowhere"), ioutil.Discard)
+	if err == nil || !strings.Contains(err.Error(), "nowhere") {
+		t.Fatal(err)
+	}
+}
+
+// 10万行のDB命令からなるソースコードを生成する
+func makeDB100k() []byte {
+
+	b := new(bytes.Buffer)
+	for i := 0; i < 100000; i++ {
+		_, _ = fmt.Fprintf(b, "    DB    0x%02x, 0x%02x, 0x%02x, 0x%02x, 0x%02x, 0x%02x, 0x%02x, 0x%02x\n",
+			i&0xFF, (i+1)&0xFF, (i+2)&0xFF, (i+3)&0xFF, (i+4)&0xFF, (i+5)&0xFF, (i+6)&0xFF, (i+7)&0xFF)
+	}
+	return b.Bytes()
+}
+
+// 10万行のDB命令をアセンブルする
+// rpnはオペランドを従来のrpn + decimalで評価し、その値を定数の式としてアセンブラに渡す
+func BenchmarkAssembler_DB100k(b *testing.B) {
+
+	src := makeDB100k()
+
+	run := func(b *testing.B, a *Assembler) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := a.Exec(bytes.NewReader(src), ioutil.Discard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("rpn", func(b *testing.B) {
+		a := New()
+		a.parse = func(s string) (*expr.Expr, error) {
+			r, err := rpn.Parse(s)
+			if err != nil {
+				return nil, err
+			}
+			d, err := r.Eval(func(name string) (decimal.Decimal, error) {
+				return decimal.Zero, expr.UndefinedSymbol(name)
+			})
+			if err != nil {
+				return nil, err
+			}
+			return expr.FromInt(d.IntPart()), nil
+		}
+		run(b, a)
+	})
+
+	b.Run("expr", func(b *testing.B) {
+		run(b, New())
+	})
+}
+
+func TestAssembler_DQDT(t *testing.T) {
+
+	asmFile := xtesting.MustOpen(t, "testdata/dq_dt.txt")
+	defer xtesting.MustClose(t, asmFile)
+
+	a := New()
+	b := new(bytes.Buffer)
+	_, err := a.Exec(asmFile, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{
+		0xFF, 0x80, 0xFF,
+		0xFF, 0xFF, 0x00, 0x80, 0xFF, 0xFF,
+		0xFE, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0xC0, 0x3F, 0x00, 0x00, 0x00, 0x80,
+		0xFE, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xF8, 0x3F,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC0, 0xFF, 0x3F,
+		0xCD, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xCC, 0xFB, 0x3F,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+		0x00, 0x10, 0x7A, 0x44, 0x00, 0x00, 0x40, 0x40,
+		0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00,
+		0xFF,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x80,
+	}
+	if bytes.Compare(b.Bytes(), want) != 0 {
+		t.Fatalf("% X", b.Bytes())
+	}
+}
+
+func TestAssembler_DataRange(t *testing.T) {
+
+	testCases := []struct {
+		src string
+		err string
+	}{
+		{src: "DB 256", err: "-0x80 ~ 0xFF"},
+		{src: "DB -129", err: "-0x80 ~ 0xFF"},
+		{src: "DW 0x10000", err: "-0x8000 ~ 0xFFFF"},
+		{src: "DD -0x80000001", err: "-0x80000000 ~ 0xFFFFFFFF"},
+		{src: "DB 0xFFFFFFFFFFFFFFFF", err: "-0x80 ~ 0xFF"},
+		{src: "DW 0xFFFFFFFFFFFFFFFF", err: "-0x8000 ~ 0xFFFF"},
+		{src: "DD 0x8000000000000000", err: "-0x80000000 ~ 0xFFFFFFFF"},
+		{src: "DW 0xFFFFFFFFFFFFFFFF + 0", err: "-0x8000 ~ 0xFFFF"},
+		{src: "DQ -0x8000000000000000 - 1", err: "integer overflow"},
+		{src: "DB 1.5", err: "浮動小数点数"},
+		{src: "DW 1.5", err: "浮動小数点数"},
+		{src: "DD 1.0e39", err: "範囲"},
+		{src: "DQ \"str\"", err: "文字列"},
+	}
+
+	for _, tt := range testCases {
+		_, err := New().Exec(strings.NewReader(tt.src), ioutil.Discard)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Fatal(tt.src, " ", err)
+		}
+	}
+}
+
+// 既存のイメージの途中に書き込む場合、書き込んだ範囲以外の内容は残り、RESBの範囲は0になる
+func TestAssembler_SparseOutput(t *testing.T) {
+
+	testCases := []struct {
+		size int64  // 既存のイメージのサイズ
+		at   int64  // 書き込む位置
+		src  string // ソースコード
+	}{
+		{size: 0x4000, at: 4, src: "DB 1\nRESB 0x2000\nDB 2"},
+		{size: 8, at: 4, src: "DB 1\nRESB 0x2000"},
+		{size: 8, at: 8, src: "RESB 0x2000\nDB 3"},
+		{size: 8, at: 8, src: "DB 3\nRESB 0x2000"},
+	}
+
+	for i, tt := range testCases {
+		for _, options := range [][]Option{nil, {WithSparseOutput()}} {
+
+			f, err := ioutil.TempFile("", "asm")
+			if err != nil {
+				t.Fatal(err)
+			}
+			image := bytes.Repeat([]byte{0xAA}, int(tt.size))
+			if _, err := f.Write(image); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := f.Seek(tt.at, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = New(options...).Exec(strings.NewReader(tt.src), f)
+			xtesting.MustClose(t, f)
+			got, readErr := ioutil.ReadFile(f.Name())
+			_ = os.Remove(f.Name())
+			if err != nil || readErr != nil {
+				t.Fatal(i, err, readErr)
+			}
+
+			b := new(bytes.Buffer)
+			if _, err := New().Exec(strings.NewReader(tt.src), b); err != nil {
+				t.Fatal(err)
+			}
+			want := append(image[:tt.at:tt.at], b.Bytes()...)
+			if int64(len(want)) < tt.size {
+				want = append(want, image[len(want):]...)
+			}
+			if bytes.Compare(got, want) != 0 {
+				t.Fatalf("%d %d: %d bytes", i, len(options), len(got))
+			}
+		}
+	}
+}
+
+func TestAssembler_Reuse(t *testing.T) {
+
+	src, err := ioutil.ReadFile("testdata/helloos.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := New()
+	for i := 0; i < 2; i++ {
+		b := new(bytes.Buffer)
+		result, err := a.Exec(bytes.NewReader(src), b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertImage(t, b.Bytes(), result, hellosImage)
+		if result.Size != int64(len(hellosImage)) {
+			t.Fatal(i, result.Size)
+		}
+		if result.Symbols["msg"] != 0x7c74 || result.Symbols["entry"] != 0x7c50 {
+			t.Fatal(i, result.Symbols)
+		}
+	}
+}
+
+func TestAssembler_Concurrent(t *testing.T) {
+
+	a := New(WithOrigin(0x100))
+
+	var (
+		wg     sync.WaitGroup
+		errors = make([]error, 16)
+	)
+	for i := range errors {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			src := fmt.Sprintf("start:\nRESB %d\nend:\nDW start, end", i)
+			b := new(bytes.Buffer)
+			if _, err := a.Exec(strings.NewReader(src), b); err != nil {
+				errors[i] = err
+				return
+			}
+			want := append(make([]byte, i), 0x00, 0x01, byte(0x100+i), 0x01)
+			if bytes.Compare(b.Bytes(), want) != 0 {
+				errors[i] = fmt.Errorf("%d: % x", i, b.Bytes())
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errors {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAssembler_Options(t *testing.T) {
+
+	testCases := []struct {
+		opts []Option
+		src  string
+		want []byte
+	}{
+		{[]Option{WithOrigin(0x7c00)}, "DW $", []byte{0x00, 0x7c}},
+		{[]Option{WithOrigin(0x7c00)}, "ORG 0x100\nDW $", []byte{0x00, 0x01}},
+		{[]Option{WithBits(32)}, "MOV EAX,1", []byte{0xb8, 0x01, 0x00, 0x00, 0x00}},
+		{[]Option{WithBits(32)}, "BITS 16\nMOV EAX,1", []byte{0x66, 0xb8, 0x01, 0x00, 0x00, 0x00}},
+		{[]Option{WithDefine("SIZE", 3)}, "DB SIZE", []byte{0x03}},
+		{[]Option{WithDefine("SIZE", 3)}, "DW end+SIZE\nend:", []byte{0x05, 0x00}},
+		{[]Option{WithLimits(Limits{MaxOutputSize: 2, MaxLines: 2})}, "DB 1\nDB 2", []byte{0x01, 0x02}},
+	}
+
+	for i, tt := range testCases {
+		b := new(bytes.Buffer)
+		if _, err := New(tt.opts...).Exec(strings.NewReader(tt.src), b); err != nil {
+			t.Fatal(i, err)
+		}
+		if bytes.Compare(b.Bytes(), tt.want) != 0 {
+			t.Fatalf("%d: % x", i, b.Bytes())
+		}
+	}
+}
+
+func TestAssembler_Include(t *testing.T) {
+
+	asmFile := xtesting.MustOpen(t, "testdata/include.txt")
+	defer xtesting.MustClose(t, asmFile)
+
+	b := new(bytes.Buffer)
+	result, err := New(WithIncludeFS(DirFS("testdata"))).Exec(asmFile, b)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	if bytes.Compare(b.Bytes(), hellosImage) != 0 {
-		t.Fatal()
+	want := []byte{
+		0xeb, 0x01, 0x90, // JMP entry, DB 0x90
+		0xbe, 0x16, 0x7c, // MOV SI, msg
+		0x8a, 0x04, 0x83, 0xc6, 0x01, 0x3c, 0x00, 0x74, 0x06, 0xb4, 0x0e, 0xcd, 0x10, 0xeb, 0xf1, 0xf4,
+		'h', 'i', 0x00,
+	}
+	if bytes.Compare(b.Bytes(), want) != 0 {
+		t.Fatalf("% x", b.Bytes())
+	}
+	if result.Symbols["putloop"] != 0x7c06 || result.Symbols["fin"] != 0x7c15 {
+		t.Fatal(result.Symbols)
+	}
+}
+
+func TestAssembler_Diagnostics(t *testing.T) {
+
+	testCases := []struct {
+		opts []Option
+		src  string
+		want Diagnostic
+	}{
+		{nil, "DB 1\n\nDB 256", Diagnostic{Line: 3, Severity: SeverityError}},
+		{nil, "\nDW nowhere", Diagnostic{Line: 2, Severity: SeverityError}},
+		{nil, `INCLUDE "include/error.inc"`, Diagnostic{Line: 1, Severity: SeverityError}},
+		{[]Option{WithIncludeFS(DirFS("testdata"))}, "\n" + `INCLUDE "include/error.inc"`, Diagnostic{File: "include/error.inc", Line: 3, Severity: SeverityError}},
+		{[]Option{WithIncludeFS(DirFS("testdata"))}, `INCLUDE "include/self.inc"`, Diagnostic{File: "include/self.inc", Line: 1, Severity: SeverityError}},
+		{[]Option{WithIncludeFS(DirFS("testdata"))}, `INCLUDE "include/none.inc"`, Diagnostic{Line: 1, Severity: SeverityError}},
+		{[]Option{WithLimits(Limits{MaxOutputSize: 1})}, "DB 1, 2", Diagnostic{Severity: SeverityError}},
+		{[]Option{WithLimits(Limits{MaxLines: 1})}, "DB 1\nDB 2", Diagnostic{Line: 2, Severity: SeverityError}},
+		{[]Option{WithOutputFormat(Format(-1))}, "DB 1", Diagnostic{Severity: SeverityError}},
+	}
+
+	for i, tt := range testCases {
+		result, err := New(tt.opts...).Exec(strings.NewReader(tt.src), ioutil.Discard)
+		if err == nil {
+			t.Fatal(i)
+		}
+		if len(result.Diagnostics) != 1 {
+			t.Fatal(i, result.Diagnostics)
+		}
+		d := result.Diagnostics[0]
+		if d.File != tt.want.File || d.Line != tt.want.Line || d.Severity != tt.want.Severity || d.Message == "" {
+			t.Fatal(i, d)
+		}
+		if tt.want.Line != 0 && err.Error() != d.String() {
+			t.Fatal(i, err)
+		}
+	}
+}
+
+func TestAssembler_Macro(t *testing.T) {
+
+	asmFile := xtesting.MustOpen(t, "testdata/macro.txt")
+	defer xtesting.MustClose(t, asmFile)
+
+	b := new(bytes.Buffer)
+	if _, err := New().Exec(asmFile, b); err != nil {
+		t.Fatal(err)
+	}
+
+	// 展開後と同じソースコード
+	src := `
+        MOV     SI, msg
+        CALL    puts
+        INC     CX
+        INC     CX
+loop1:
+        HLT
+        JMP     loop1
+loop2:
+        HLT
+        JMP     loop2
+puts:
+        RET
+msg:
+        DB      "%1", 0`
+	want := new(bytes.Buffer)
+	if _, err := New().Exec(strings.NewReader(src), want); err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Compare(b.Bytes(), want.Bytes()) != 0 {
+		t.Fatalf("% x", b.Bytes())
+	}
+}
+
+// ラベルと同じ行に書いた命令は、ラベルを別の行に書いた場合と同じ結果になる
+func TestAssembler_LabelLine(t *testing.T) {
+
+	src := "%macro TWICE 1\nDB %1, %1\n%endmacro\n" +
+		"start: MOV AX, 1\nmsg: DB \"hi\", 0\nneg: DB -1, 'A'+1\nhalt: HLT\nm: TWICE 7\nJMP start"
+	want := "%macro TWICE 1\nDB %1, %1\n%endmacro\n" +
+		"start:\nMOV AX, 1\nmsg:\nDB \"hi\", 0\nneg:\nDB -1, 'A'+1\nhalt:\nHLT\nm:\nTWICE 7\nJMP start"
+
+	got, wantImage := new(bytes.Buffer), new(bytes.Buffer)
+	result, err := New().Exec(strings.NewReader(src), got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantResult, err := New().Exec(strings.NewReader(want), wantImage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(got.Bytes(), wantImage.Bytes()) != 0 || fmt.Sprint(result.Symbols) != fmt.Sprint(wantResult.Symbols) {
+		t.Fatalf("% x %v", got.Bytes(), result.Symbols)
+	}
+}
+
+// マクロ本体の%%は、シンボル名が続く場合だけローカルラベルとなり、それ以外は符号付き剰余の演算子となる
+func TestAssembler_MacroSignedModulo(t *testing.T) {
+
+	src := "%macro SMOD 2\n%%L:\nDB %1 %% %2, -%1 %%%2, %%L - $$\n%endmacro\nSMOD 7, 3\nSMOD 7, 3"
+
+	b := new(bytes.Buffer)
+	if _, err := New().Exec(strings.NewReader(src), b); err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{1, 0xFF, 0, 1, 0xFF, 3}; bytes.Compare(b.Bytes(), want) != 0 {
+		t.Fatalf("% x", b.Bytes())
+	}
+}
+
+func TestAssembler_MacroError(t *testing.T) {
+
+	testCases := []struct {
+		src  string
+		line int
+	}{
+		{"%macro A 1\nDB %1\n%endmacro\nA", 4},
+		{"%macro A 0\nDB 1", 1},
+		{"%endmacro", 1},
+		{"%macro A\n%endmacro", 1},
+		{"%macro A 0\n%macro B 0", 2},
+		{"%macro A 0\n%endmacro\n%macro A 0\n%endmacro", 3},
+		{"%macro A 0\nDB 256\n%endmacro\nA", 2},
+	}
+
+	for i, tt := range testCases {
+		_, err := New().Exec(strings.NewReader(tt.src), ioutil.Discard)
+		var e *Error
+		if !errors.As(err, &e) || e.Line != tt.line {
+			t.Fatal(i, err)
+		}
+	}
+}
+
+func TestAssembler_Limits(t *testing.T) {
+
+	testCases := []struct {
+		limits Limits
+		src    string
+		err    error
+	}{
+		{Limits{MaxOutputSize: 0x200}, "RESB 0x1fe\nDB 0x55, 0xaa\nDB 0", ErrOutputSizeLimit},
+		{Limits{MaxLines: 2}, "DB 1\nDB 2\nDB 3", ErrLineLimit},
+		{Limits{MaxLines: 3}, "%macro A 0\nDB 1\n%endmacro\nA", ErrLineLimit},
+		{Limits{}, "%macro A 0\nA\n%endmacro\nA", ErrMacroDepthLimit},
+		{Limits{MaxMacroDepth: 1}, "%macro A 0\nDB 1\n%endmacro\n%macro B 0\nA\n%endmacro\nB", ErrMacroDepthLimit},
+	}
+
+	for i, tt := range testCases {
+		_, err := New(WithLimits(tt.limits)).Exec(strings.NewReader(tt.src), ioutil.Discard)
+		if !errors.Is(err, tt.err) {
+			t.Fatal(i, err)
+		}
+	}
+
+	// 制限ちょうどなら成功する
+	if _, err := New(WithLimits(Limits{MaxOutputSize: 0x200, MaxLines: 2})).Exec(strings.NewReader("RESB 0x1fe\nDB 0x55, 0xaa"), ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 書き込む度にコールバックを呼び出すio.Writer
+type callbackWriter struct {
+	n        int64
+	callback func()
+}
+
+func (w *callbackWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	w.callback()
+	return len(p), nil
+}
+
+func TestAssembler_ExecContext(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := New().ExecContext(ctx, strings.NewReader("DB 1"), ioutil.Discard); !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+
+	// 出力中にキャンセルする
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	w := &callbackWriter{callback: cancel}
+	result, err := New().ExecContext(ctx, strings.NewReader("RESB 0x100000"), w)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+	if w.n >= 0x100000 || result.Size != w.n {
+		t.Fatal(w.n, result.Size)
+	}
+}
+
+func TestAssembler_Sparse(t *testing.T) {
+
+	src, err := ioutil.ReadFile("testdata/helloos.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 書き込んだ範囲より後ろの既存の内容は残ること
+	f, err := ioutil.TempFile("", "asm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer xtesting.MustClose(t, f)
+	if _, err := f.Write(bytes.Repeat([]byte{0xFF}, len(hellosImage)+100)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := New(WithSparseOutput()).Exec(bytes.NewReader(src), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Size != int64(len(hellosImage)) {
+		t.Fatal(result.Size)
+	}
+	got, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertImage(t, got[:len(hellosImage)], result, hellosImage)
+	if bytes.Compare(got[len(hellosImage):], bytes.Repeat([]byte{0xFF}, 100)) != 0 {
+		t.Fatal(len(got))
+	}
+
+	// 最後がRESBの場合もファイルサイズが合っていること
+	if err := f.Truncate(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(WithSparseOutput()).Exec(strings.NewReader("DB 1\nRESB 10000"), f); err != nil {
+		t.Fatal(err)
+	}
+	got, err = ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(got, append([]byte{1}, make([]byte, 10000)...)) != 0 {
+		t.Fatal(len(got))
+	}
+}
+
+func TestAssembler_Pipe(t *testing.T) {
+
+	src, err := ioutil.ReadFile("testdata/helloos.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer xtesting.MustClose(t, r)
+
+	done := make(chan []byte)
+	go func() {
+		b, _ := ioutil.ReadAll(r)
+		done <- b
+	}()
+
+	_, err = New().Exec(bytes.NewReader(src), w)
+	xtesting.MustClose(t, w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := <-done; bytes.Compare(got, hellosImage) != 0 {
+		t.Fatal(len(got))
+	}
+}
+
+// 文字列と前方参照を含む大きなデータテーブルを生成する
+func makeDataTable() []byte {
+
+	b := new(bytes.Buffer)
+	for i := 0; i < 20000; i++ {
+		_, _ = fmt.Fprintf(b, "entry%d:\n", i)
+		_, _ = fmt.Fprintf(b, "    DD    entry%d\n    DW    0x%04x\n", (i+1)%20000, i)
+		_, _ = fmt.Fprintf(b, "    DD    0x%08x, %d\n", i*0x10001, -i)
+		_, _ = fmt.Fprintf(b, "    DB    \"name%05d\", 0\n", i)
+	}
+	return b.Bytes()
+}
+
+func BenchmarkAssembler_DataTable(b *testing.B) {
+
+	src := makeDataTable()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := New().Exec(bytes.NewReader(src), ioutil.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestAssembler_Coalesce(t *testing.T) {
+
+	src := "DB 1, 2, 3\nDW 4\nDB 'ab'\nlabel:\nDW later\nDB 5\nRESB 2\nDB 6\nlater:\nDB 7"
+
+	a := &assembly{config: New(), ctx: context.Background()}
+	b := new(bytes.Buffer)
+	if _, err := a.exec(strings.NewReader(src), b); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{1, 2, 3, 4, 0, 'a', 'b', 13, 0, 5, 0, 0, 6, 7}
+	if bytes.Compare(b.Bytes(), want) != 0 {
+		t.Fatalf("% x", b.Bytes())
+	}
+
+	// 値の確定しているデータは1つにまとめられ、前方参照とRESBは別の命令になる
+	sizes := make([]int64, len(a.mnemonics))
+	for i, m := range a.mnemonics {
+		sizes[i] = m.Size()
+	}
+	if fmt.Sprint(sizes) != "[7 2 1 2 2]" {
+		t.Fatal(sizes)
+	}
+
+	// 各バイトがどの行から出力されたか
+	lines := make([]int, len(want))
+	for i := range lines {
+		lines[i] = a.positionAt(int64(i)).line
+	}
+	if fmt.Sprint(lines) != "[1 1 1 2 2 3 3 5 5 6 7 7 8 10]" {
+		t.Fatal(lines)
+	}
+}
+
+func TestAssembler_Assert(t *testing.T) {
+
+	asmFile := xtesting.MustOpen(t, "testdata/assert.txt")
+	defer xtesting.MustClose(t, asmFile)
+
+	result, err := New().Exec(asmFile, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Size != 512 || len(result.Diagnostics) != 0 {
+		t.Fatal(result)
+	}
+
+	testCases := []struct {
+		src     string
+		line    int
+		message string
+	}{
+		// ラベルの解決後に評価される
+		{"ASSERT end-$ == 2, \"size\"\nDB 1,2,3\nend:", 1, "assertion failed: size"},
+		{"ASSERT end-$ == 2\nDB 1,2,3\nend:", 1, "assertion failed: end-$==2"},
+		{"ASSERT undefined", 1, "undeclared variable: undefined"},
+		// RESBが先に失敗しても、それより前の条件の失敗を報告する
+		{"RESB 0x200\nASSERT $-$$ <= 510, \"boot sector overflow\"\nRESB 0x1fe-($-$$)", 2, "assertion failed: boot sector overflow"},
+		{"ASSERT fin-$$ < 4, \"late\"\nRESB 0x200\nRESB 0x1fe-($-$$)\nfin:", 3, "RESB"},
+		{"ASSERT", 1, "ASSERT"},
+		{"ASSERT 1, msg", 1, "文字列"},
+		{"DB 1\n%error \"stop\"\nDB 2", 2, "stop"},
+		{"%macro OLD 0\n%error \"OLD is removed\"\n%endmacro\nOLD", 2, "OLD is removed"},
+		{"%warning", 1, "%warning"},
+	}
+
+	for i, tt := range testCases {
+		_, err := New().Exec(strings.NewReader(tt.src), ioutil.Discard)
+		var e *Error
+		if !errors.As(err, &e) || e.Line != tt.line || !strings.Contains(e.Err.Error(), tt.message) {
+			t.Fatal(i, err)
+		}
+	}
+}
+
+func TestAssembler_Warning(t *testing.T) {
+
+	src := "%macro OLD 0\n%warning \"OLD is deprecated\"\nDB 1\n%endmacro\nOLD\nOLD\nJMP far\nRESB 200\nfar:"
+	result, err := New().Exec(strings.NewReader(src), ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// NEARでアセンブルし直しても警告は重複しない
+	want := Diagnostic{Line: 2, Severity: SeverityWarning, Message: "OLD is deprecated"}
+	if len(result.Diagnostics) != 2 || result.Diagnostics[0] != want || result.Diagnostics[1] != want {
+		t.Fatal(result.Diagnostics)
+	}
+	if result.Diagnostics[0].String() != "warning:2 OLD is deprecated" {
+		t.Fatal(result.Diagnostics[0])
+	}
+
+	// エラーの場合も、それまでの警告は結果に含まれる
+	result, err = New().Exec(strings.NewReader("%warning \"first\"\n%error \"second\""), ioutil.Discard)
+	if err == nil || len(result.Diagnostics) != 2 {
+		t.Fatal(err, result.Diagnostics)
+	}
+	if result.Diagnostics[0].Severity != SeverityWarning || result.Diagnostics[1].Severity != SeverityError {
+		t.Fatal(result.Diagnostics)
+	}
+}
+
+func TestAssembler_Check(t *testing.T) {
+
+	b, err := ioutil.ReadFile("testdata/helloos.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	helloos := string(b)
+
+	// 置換後のソースコードで、指定した文字列を含む行の行番号
+	lineOf := func(src string, s string) int {
+		for i, line := range strings.Split(src, "\n") {
+			if strings.Contains(line, s) {
+				return i + 1
+			}
+		}
+		t.Fatal(s)
+		return 0
+	}
+
+	testCases := []struct {
+		src   string
+		lines []string // 警告が出る行に含まれる文字列
+	}{
+		{src: helloos},
+		{src: strings.Replace(helloos, "DW    2880", "DW    2881", 1), lines: []string{"DW    2881"}},
+		{src: strings.Replace(helloos, "0x55, 0xaa", "0x55, 0xab", 1), lines: []string{"0x55, 0xab"}},
+		{src: strings.Replace(helloos, "RESB  1469432", "RESB  1469000", 1), lines: []string{"DW    2880", "RESB  1469000"}},
+		{src: "ORG 0x7c00\nJMP 0x7c00+0x300\nRESB 510-($-$$)\nDB 0x55, 0xaa", lines: []string{"JMP"}},
+		{src: "ORG 0x7c00\nDB 0xEA\nDW 0x7f00, 0\nRESB 510-($-$$)\nDB 0x55, 0xaa", lines: []string{"DB 0xEA"}},
+		{src: "ORG 0x7c00\nJMP $\nDB 0x55, 0xaa", lines: []string{"DB 0x55", "DB 0x55"}},
+	}
+
+	for i, tt := range testCases {
+		result, err := New(WithChecks(CheckBoot)).Exec(strings.NewReader(tt.src), ioutil.Discard)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if len(result.Diagnostics) != len(tt.lines) {
+			t.Fatal(i, result.Diagnostics)
+		}
+		for j, d := range result.Diagnostics {
+			if d.Severity != SeverityWarning || d.Line != lineOf(tt.src, tt.lines[j]) {
+				t.Fatal(i, d)
+			}
+		}
+	}
+
+	// 検査を指定しなければ警告は出ない
+	result, err := New().Exec(strings.NewReader("DB 1"), ioutil.Discard)
+	if err != nil || len(result.Diagnostics) != 0 {
+		t.Fatal(err, result.Diagnostics)
+	}
+
+	if c, err := ParseChecks("signature, bpb"); err != nil || c != CheckSignature|CheckBPB {
+		t.Fatal(c, err)
+	}
+	if c, err := ParseChecks("all"); err != nil || c != CheckBoot {
+		t.Fatal(c, err)
+	}
+	if _, err := ParseChecks("size"); err == nil {
+		t.Fatal("size")
+	}
+}
+
+func TestAssembler_EQU(t *testing.T) {
+
+	// ORGより前に定義した定数はORGで値が変わらない
+	src := "SIZE EQU 4\nORG 0x100\nDB SIZE\nDW end\nend:\nEND2:\tEQU\tend + SIZE*2"
+	b := new(bytes.Buffer)
+	result, err := New().Exec(strings.NewReader(src), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(b.Bytes(), []byte{4, 0x03, 0x01}) != 0 {
+		t.Fatalf("% x", b.Bytes())
+	}
+	if result.Symbols["SIZE"] != 4 || result.Symbols["end"] != 0x103 || result.Symbols["END2"] != 0x10B {
+		t.Fatal(result.Symbols)
+	}
+
+	testCases := []struct {
+		src  string
+		line int
+	}{
+		{"SIZE EQU 1\nSIZE EQU 2", 2},
+		{"SIZE:\nSIZE EQU 2", 2},
+		{"SIZE EQU", 1},
+		{"SIZE EQU 1, 2", 1},
+		{"SIZE EQU later\nlater:", 1},
+	}
+	for i, tt := range testCases {
+		result, err := New().Exec(strings.NewReader(tt.src), ioutil.Discard)
+		if err == nil || result.Diagnostics[0].Line != tt.line {
+			t.Fatal(i, err)
+		}
 	}
 }
@@ -0,0 +1,147 @@
+package assembler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler/expr"
+	"github.com/nanasi880/til/os/tool/asm/assembler/lexer"
+)
+
+// ASSERT命令で記述された条件
+// ラベルの解決後に評価する
+type assertion struct {
+	expr    *expr.Expr // 条件式 0なら失敗
+	message string     // 失敗した場合のメッセージ 省略された場合は空
+	here    int64      // 式を評価する際の$の値
+	base    int64      // 式を評価する際の$$の値
+	pos     position   // ASSERT命令の位置
+}
+
+// ASSERT命令
+//
+//	ASSERT $-$$ <= 510, "ブートセクタは510バイトに収める必要がある"
+//
+// 条件式は前方参照を含められるよう、全てのラベルが確定してから評価する
+//
+// @param parameters --- パラメーター 条件式と省略可能なメッセージの文字列
+//
+// @return エラー
+func (a *assembly) mnemonicASSERT(parameters []lexer.Token) error {
+
+	if len(parameters) != 1 && len(parameters) != 2 {
+		return fmt.Errorf("ASSERT命令は条件式と省略可能なメッセージが必要")
+	}
+	e, err := a.parseExpr(string(parameters[0]))
+	if err != nil {
+		return err
+	}
+
+	var message string
+	if len(parameters) == 2 {
+		if message, err = a.messageOf(parameters[1]); err != nil {
+			return err
+		}
+	}
+
+	a.assertions = append(a.assertions, assertion{
+		expr:    e,
+		message: message,
+		here:    a.origin + a.address,
+		base:    a.origin,
+		pos:     position{file: a.fileName, line: a.sourceLineNumber},
+	})
+	return nil
+}
+
+// %error, %warning
+// %errorはその場でアセンブルを中止し、%warningは診断メッセージを追加して続行する
+//
+//	%warning "この命令は次の版で削除される"
+//
+// @param directive  --- %error or %warning
+// @param parameters --- パラメーター メッセージの文字列
+//
+// @return エラー
+func (a *assembly) directiveMessage(directive lexer.Token, parameters []lexer.Token) error {
+
+	if len(parameters) != 1 {
+		return fmt.Errorf("%sにはメッセージの文字列が1つ必要", directive)
+	}
+	message, err := a.messageOf(parameters[0])
+	if err != nil {
+		return err
+	}
+
+	if directive == "%error" {
+		return errors.New(message)
+	}
+	a.warnings = append(a.warnings, Diagnostic{
+		File:     a.fileName,
+		Line:     a.sourceLineNumber,
+		Severity: SeverityWarning,
+		Message:  message,
+	})
+	return nil
+}
+
+// メッセージの文字列をデコードする
+//
+// @param t --- クォートされたトークン
+//
+// @return メッセージ、エラー
+func (a *assembly) messageOf(t lexer.Token) (string, error) {
+
+	if !t.Quoted() {
+		return "", fmt.Errorf("メッセージは文字列である必要がある: %s", t)
+	}
+	b, err := lexer.Unquote(t)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// ASSERT命令の条件を評価する
+//
+// @param symbols --- シンボルの値
+// @param partial --- アセンブルが途中で失敗しているかどうか trueなら評価できない条件は無視する
+//
+// @return 最初に失敗した条件のエラー
+func (a *assembly) checkAssertions(symbols map[string]int64, partial bool) error {
+
+	for _, as := range a.assertions {
+
+		v, err := as.expr.Eval(func(name string) (int64, error) {
+			switch name {
+			case "$":
+				return as.here, nil
+			case "$$":
+				return as.base, nil
+			}
+			if v, ok := symbols[name]; ok {
+				return v, nil
+			}
+			if v, ok := a.config.defines[name]; ok {
+				return v, nil
+			}
+			return 0, expr.UndefinedSymbol(name)
+		})
+		switch {
+		case err != nil && partial:
+			continue
+		case err != nil:
+			return &Error{File: as.pos.file, Line: as.pos.line, Err: err}
+		case v != 0:
+			continue
+		}
+
+		message := as.message
+		if message == "" {
+			message = as.expr.String()
+		}
+		return &Error{File: as.pos.file, Line: as.pos.line, Err: fmt.Errorf("assertion failed: %s", message)}
+	}
+
+	return nil
+}
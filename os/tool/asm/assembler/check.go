@@ -0,0 +1,215 @@
+package assembler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler/instruction"
+)
+
+// 出力したイメージに対する検査
+// 問題が見つかった場合は、そのバイトを出力した行の警告として診断メッセージに追加する
+type Check int
+
+const (
+	CheckSignature  Check = 1 << iota // オフセット510にブートシグネチャ 0x55, 0xAA があること
+	CheckBPB                          // BPBの総セクタ数とセクタサイズがイメージのサイズと一致すること
+	CheckSectorSize                   // イメージのサイズがセクタサイズの倍数であること
+	CheckBootJump                     // 先頭のジャンプ命令の飛び先がブートセクタ内であること
+
+	CheckBoot = CheckSignature | CheckBPB | CheckSectorSize | CheckBootJump // ブートイメージの全ての検査
+)
+
+// 検査の名前 ParseChecks()で使用する
+var checkNames = []struct {
+	name  string
+	check Check
+}{
+	{"signature", CheckSignature},
+	{"bpb", CheckBPB},
+	{"sector", CheckSectorSize},
+	{"jump", CheckBootJump},
+	{"all", CheckBoot},
+}
+
+// カンマ区切りの名前から検査を取得する
+//
+// @param s --- signature, bpb, sector, jump, all をカンマで区切ったもの
+//
+// @return 検査、エラー
+func ParseChecks(s string) (Check, error) {
+
+	var result Check
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, c := range checkNames {
+			if c.name == name {
+				result |= c.check
+				found = true
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown check: %s", name)
+		}
+	}
+	return result, nil
+}
+
+const (
+	bootSectorSize    = 512 // ブートセクタの大きさ
+	bootSignatureAt   = 510 // ブートシグネチャの位置
+	bpbBytesPerSector = 11  // BPBの1セクタのバイト数(DW)の位置
+	bpbTotalSectors16 = 19  // BPBの総セクタ数(DW)の位置
+	bpbTotalSectors32 = 32  // BPBの総セクタ数(DD)の位置 DWの方が0の場合に使われる
+	bpbSize           = 36  // BPBの総セクタ数までを含む大きさ
+)
+
+// 出力するイメージを検査する
+//
+// @param size --- イメージのバイト数
+func (a *assembly) check(size int64) {
+
+	checks := a.config.checks
+	if checks == 0 {
+		return
+	}
+
+	sector := a.read(0, bootSectorSize)
+
+	if checks&CheckSignature != 0 {
+		if len(sector) < bootSectorSize {
+			a.warnAt(size-1, "boot signature 0x55AA is missing: image is only %d bytes", size)
+		} else if sector[bootSignatureAt] != 0x55 || sector[bootSignatureAt+1] != 0xAA {
+			a.warnAt(bootSignatureAt, "boot signature 0x55AA is missing at offset 510: %02X %02X", sector[bootSignatureAt], sector[bootSignatureAt+1])
+		}
+	}
+
+	// BPBが無ければ512バイトのセクタとみなす
+	sectorSize := int64(bootSectorSize)
+	bpb := hasBPB(sector)
+	if bpb {
+		sectorSize = int64(binary.LittleEndian.Uint16(sector[bpbBytesPerSector:]))
+	}
+
+	if checks&CheckBPB != 0 && bpb {
+		at := int64(bpbTotalSectors16)
+		total := int64(binary.LittleEndian.Uint16(sector[bpbTotalSectors16:]))
+		if total == 0 {
+			at, total = bpbTotalSectors32, int64(binary.LittleEndian.Uint32(sector[bpbTotalSectors32:]))
+		}
+		if total*sectorSize != size {
+			a.warnAt(at, "BPB total sectors %d x %d bytes = %d does not match the image size %d", total, sectorSize, total*sectorSize, size)
+		}
+	}
+
+	if checks&CheckSectorSize != 0 && size%sectorSize != 0 {
+		a.warnAt(size-1, "image size %d is not a multiple of the sector size %d", size, sectorSize)
+	}
+
+	if checks&CheckBootJump != 0 {
+		if target, ok := a.bootJumpTarget(sector); ok && (target < 0 || target >= bootSignatureAt) {
+			a.warnAt(0, "boot jump target 0x%X is outside the boot sector", a.origin+target)
+		}
+	}
+}
+
+// BPBを含むブートセクタかどうか
+// 先頭がジャンプ命令で、1セクタのバイト数とクラスタのセクタ数が妥当な値であればBPBがあるとみなす
+func hasBPB(sector []byte) bool {
+
+	if len(sector) < bpbSize || (sector[0] != 0xEB && sector[0] != 0xE9) {
+		return false
+	}
+	switch binary.LittleEndian.Uint16(sector[bpbBytesPerSector:]) {
+	case 512, 1024, 2048, 4096:
+	default:
+		return false
+	}
+	clusters := sector[bpbBytesPerSector+2]
+	return clusters != 0 && clusters&(clusters-1) == 0
+}
+
+// 先頭のジャンプ命令の飛び先を求める
+//
+// @param sector --- ブートセクタ
+//
+// @return イメージの先頭からの飛び先のオフセット、先頭がジャンプ命令かどうか
+func (a *assembly) bootJumpTarget(sector []byte) (int64, bool) {
+
+	switch {
+	case len(sector) >= 2 && sector[0] == 0xEB:
+		return 2 + int64(int8(sector[1])), true
+	case len(sector) >= 3 && sector[0] == 0xE9:
+		return 3 + int64(int16(binary.LittleEndian.Uint16(sector[1:]))), true
+	case len(sector) >= 5 && sector[0] == 0xEA:
+		offset := int64(binary.LittleEndian.Uint16(sector[1:]))
+		segment := int64(binary.LittleEndian.Uint16(sector[3:]))
+		return segment<<4 + offset - a.origin, true
+	}
+	return 0, false
+}
+
+// 出力されるイメージの一部を取得する
+//
+// @param offset --- イメージの先頭からのオフセット
+// @param n      --- バイト数
+//
+// @return イメージの末尾を超える部分を除いたバイト列
+func (a *assembly) read(offset, n int64) []byte {
+
+	var (
+		result []byte
+		start  int64
+		buf    bytes.Buffer
+	)
+	for _, m := range a.mnemonics {
+
+		size := m.Size()
+		end := start + size
+		if end <= offset {
+			start = end
+			continue
+		}
+		if start >= offset+n {
+			break
+		}
+
+		lo, hi := offset-start, offset+n-start
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > size {
+			hi = size
+		}
+		if _, ok := m.(*instruction.RESB); ok {
+			result = append(result, make([]byte, hi-lo)...)
+		} else {
+			buf.Reset()
+			_, _ = m.Write(&buf)
+			result = append(result, buf.Bytes()[lo:hi]...)
+		}
+		start = end
+	}
+	return result
+}
+
+// イメージの指定したオフセットを出力した行の警告を追加する
+//
+// @param offset --- イメージの先頭からのオフセット
+// @param format --- メッセージの書式
+// @param args   --- 書式の引数
+func (a *assembly) warnAt(offset int64, format string, args ...interface{}) {
+	pos := a.positionAt(offset)
+	a.warnings = append(a.warnings, Diagnostic{
+		File:     pos.file,
+		Line:     pos.line,
+		Severity: SeverityWarning,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
@@ -0,0 +1,123 @@
+package assembler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 出力とリファレンスのイメージで内容が異なる範囲
+// 異なるバイトが連続していても、出力したソースコードの行が変わる位置で分割する
+type DiffRange struct {
+	Offset int64  // 範囲の開始位置 出力の先頭からのオフセット
+	Got    []byte // 出力の内容 出力の末尾を超える部分は含まない
+	Want   []byte // リファレンスの内容 リファレンスの末尾を超える部分は含まない
+	File   string // 範囲を出力したファイル名 メインのソースコードなら空
+	Line   int    // 範囲を出力した行番号 出力の末尾を超える範囲なら0
+}
+
+// 範囲の長さ
+func (d DiffRange) Size() int64 {
+	if len(d.Got) > len(d.Want) {
+		return int64(len(d.Got))
+	}
+	return int64(len(d.Want))
+}
+
+// 範囲を出力したソースコード上の位置
+func (d DiffRange) Position() string {
+	switch {
+	case d.Line == 0:
+		return "no source"
+	case d.File == "":
+		return fmt.Sprintf("line %d", d.Line)
+	}
+	return fmt.Sprintf("%s:%d", d.File, d.Line)
+}
+
+func (d DiffRange) String() string {
+	return fmt.Sprintf("0x%06X-0x%06X %s: got %s, want %s", d.Offset, d.Offset+d.Size()-1, d.Position(), diffBytes(d.Got), diffBytes(d.Want))
+}
+
+// 差分の表示で1つの範囲に表示する最大のバイト数
+const maxDiffBytes = 16
+
+// 差分の表示用にバイト列を16進数で表す
+func diffBytes(b []byte) string {
+	switch {
+	case len(b) == 0:
+		return "(none)"
+	case len(b) > maxDiffBytes:
+		return fmt.Sprintf("% X ... (%d bytes)", b[:maxDiffBytes], len(b))
+	}
+	return fmt.Sprintf("% X", b)
+}
+
+// アセンブルした出力とリファレンスのイメージを比較する
+// 長さが異なる場合、短い方の末尾を超える部分も異なる範囲として扱う
+//
+// @param got     --- アセンブルした出力
+// @param want    --- リファレンスのイメージ
+// @param sources --- 出力の各範囲を出力したソースコード上の位置 Result.Sources
+//
+// @return 異なる範囲 オフセット順 一致する場合は空
+func Diff(got, want []byte, sources []SourceRange) []DiffRange {
+
+	size := len(got)
+	if len(want) > size {
+		size = len(want)
+	}
+
+	var (
+		result  []DiffRange
+		current *DiffRange
+		next    int // 次に切り替わるsourcesの位置
+	)
+	for i := 0; i < size; i++ {
+
+		// 現在のオフセットを出力した行
+		for next < len(sources) && sources[next].Offset <= int64(i) {
+			next++
+		}
+		var source SourceRange
+		if next > 0 && i < len(got) {
+			source = sources[next-1]
+		}
+
+		if i < len(got) && i < len(want) && got[i] == want[i] {
+			current = nil
+			continue
+		}
+		if current == nil || current.File != source.File || current.Line != source.Line {
+			result = append(result, DiffRange{Offset: int64(i), File: source.File, Line: source.Line})
+			current = &result[len(result)-1]
+		}
+		if i < len(got) {
+			current.Got = got[current.Offset : i+1]
+		}
+		if i < len(want) {
+			current.Want = want[current.Offset : i+1]
+		}
+	}
+
+	return result
+}
+
+// 差分を1行に1つの範囲で表す
+//
+// @param diffs --- 異なる範囲
+// @param max   --- 表示する範囲の最大数 0なら全て
+//
+// @return 差分の表示 一致する場合は空
+func FormatDiff(diffs []DiffRange, max int) string {
+
+	var b strings.Builder
+	for i, d := range diffs {
+		if max > 0 && i == max {
+			fmt.Fprintf(&b, "... and %d more ranges\n", len(diffs)-max)
+			break
+		}
+		b.WriteString(d.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
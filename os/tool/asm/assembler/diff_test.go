@@ -0,0 +1,78 @@
+package assembler
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+
+	// EB 01 90 はINCLUDEしたファイルの2, 3行目
+	src := "DB 1, 2, 3\nDB 4\nINCLUDE \"include/bpb.inc\"\nentry:\nDB 6"
+
+	b := new(bytes.Buffer)
+	result, err := New(WithIncludeFS(DirFS("testdata"))).Exec(strings.NewReader(src), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diffs := Diff(b.Bytes(), b.Bytes(), result.Sources); len(diffs) != 0 {
+		t.Fatal(diffs)
+	}
+
+	testCases := []struct {
+		want []byte
+		diff string
+	}{
+		// 行の境界で範囲を分割する
+		{[]byte{1, 2, 0, 0, 0, 1, 0x90, 6}, "2:line 1:03:00 3:line 2:04:00 4:include/bpb.inc:2:EB:00"},
+		{[]byte{1, 2, 3, 4, 0xEB, 1, 0x90, 7}, "7:line 5:06:07"},
+		// 長さが異なる場合は末尾を超える部分も差分とする
+		{[]byte{1, 2, 3, 4, 0xEB, 1, 0x90}, "7:line 5:06:"},
+		{[]byte{1, 2, 3, 4, 0xEB, 1, 0x90, 6, 8, 9}, "8:no source::08 09"},
+		{nil, "0:line 1:01 02 03: 3:line 2:04: 4:include/bpb.inc:2:EB 01: 6:include/bpb.inc:3:90: 7:line 5:06:"},
+	}
+
+	for i, tt := range testCases {
+		var s []string
+		for _, d := range Diff(b.Bytes(), tt.want, result.Sources) {
+			s = append(s, fmt.Sprintf("%d:%s:% X:% X", d.Offset, d.Position(), d.Got, d.Want))
+		}
+		if strings.Join(s, " ") != tt.diff {
+			t.Fatal(i, s)
+		}
+	}
+}
+
+// 差分が値を変えた行を指すこと
+func TestDiff_Helloos(t *testing.T) {
+
+	src, err := ioutil.ReadFile("testdata/helloos.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src = bytes.Replace(src, []byte("DW    2880"), []byte("DW    2881"), 1)
+
+	b := new(bytes.Buffer)
+	result, err := New().Exec(bytes.NewReader(src), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diffs := Diff(b.Bytes(), hellosImage, result.Sources)
+	if len(diffs) != 1 || diffs[0].Line != 16 {
+		t.Fatal(diffs)
+	}
+	if s := FormatDiff(diffs, 0); s != "0x000013-0x000013 line 16: got 41, want 40\n" {
+		t.Fatal(s)
+	}
+
+	long := make([]byte, 40)
+	s := FormatDiff([]DiffRange{{Offset: 0, Got: long}, {Offset: 40, Want: []byte{1}}, {Offset: 41, Want: []byte{2}}}, 2)
+	if s != "0x000000-0x000027 no source: got 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 00 ... (40 bytes), want (none)\n"+
+		"0x000028-0x000028 no source: got (none), want 01\n... and 1 more ranges\n" {
+		t.Fatal(s)
+	}
+}
@@ -0,0 +1,406 @@
+package assembler
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// デバッグ用のELFファイルを出力する
+// QEMUに接続したgdbでラベルとソースコードの行を表示するためのもので、フラットバイナリと組み合わせて使う
+//
+//	(gdb) symbol-file boot.elf
+//
+// フラットバイナリと同じアドレスに配置した中身の無い.text(SHT_NOBITS)と、
+// ラベルのシンボルテーブル、DWARFの.debug_lineを含む
+// 命令の中身はデバッグ対象のメモリから読めるので含めない
+//
+// @param w    --- 出力先
+// @param name --- メインのソースコードのファイル名 ソースマップのファイル名が空の行に使う
+// @param dir  --- 相対パスのファイル名の基準となるディレクトリ
+//
+// @return エラー
+func (r *Result) WriteDebugELF(w io.Writer, name, dir string) error {
+
+	m := r.SourceMap
+	if m == nil {
+		return fmt.Errorf("アセンブルに失敗した結果からはELFを出力できない")
+	}
+	if m.Origin+m.Size > 1<<32 {
+		return fmt.Errorf("ELF32で表現できるアドレスを超えている: 0x%X", m.Origin+m.Size)
+	}
+
+	var (
+		e        = &elfWriter{}
+		shstrtab = newStringTable()
+	)
+	e.add(shstrtab, "", elf.SHT_NULL, 0, nil)
+	text := e.add(shstrtab, ".text", elf.SHT_NOBITS, elf.SHF_ALLOC|elf.SHF_EXECINSTR, nil)
+	e.sections[text].addr = uint32(m.Origin)
+	e.sections[text].size = uint32(m.Size)
+
+	symtab, strtab := r.elfSymbols(text)
+	s := e.add(shstrtab, ".symtab", elf.SHT_SYMTAB, 0, symtab)
+	e.sections[s].link = uint32(s + 1)
+	e.sections[s].info = 1
+	e.sections[s].entsize = elfSymSize
+	e.sections[s].addralign = 4
+	e.add(shstrtab, ".strtab", elf.SHT_STRTAB, 0, strtab)
+
+	e.add(shstrtab, ".debug_abbrev", elf.SHT_PROGBITS, 0, debugAbbrev())
+	e.add(shstrtab, ".debug_info", elf.SHT_PROGBITS, 0, debugInfo(m, name, dir))
+	e.add(shstrtab, ".debug_line", elf.SHT_PROGBITS, 0, debugLine(m, name))
+	e.shstrndx = e.add(shstrtab, ".shstrtab", elf.SHT_STRTAB, 0, nil)
+	e.sections[e.shstrndx].data = shstrtab.Bytes()
+
+	return e.write(w, m)
+}
+
+// ELF32のヘッダー、プログラムヘッダー、セクションヘッダー、シンボルの大きさ
+const (
+	elfHeaderSize  = 52
+	elfProgSize    = 32
+	elfSectionSize = 40
+	elfSymSize     = 16
+)
+
+// ELFのセクション
+type elfSection struct {
+	name      uint32 // .shstrtab上の名前の位置
+	typ       elf.SectionType
+	flags     elf.SectionFlag
+	addr      uint32
+	offset    uint32
+	size      uint32
+	link      uint32
+	info      uint32
+	addralign uint32
+	entsize   uint32
+	data      []byte // 中身 SHT_NOBITSならnil
+}
+
+// ELFファイルの組み立て
+type elfWriter struct {
+	sections []elfSection
+	shstrndx int // .shstrtabのセクション番号
+}
+
+// セクションを追加する
+//
+// @return セクション番号
+func (e *elfWriter) add(shstrtab *stringTable, name string, typ elf.SectionType, flags elf.SectionFlag, data []byte) int {
+	e.sections = append(e.sections, elfSection{
+		name:      shstrtab.add(name),
+		typ:       typ,
+		flags:     flags,
+		size:      uint32(len(data)),
+		addralign: 1,
+		data:      data,
+	})
+	return len(e.sections) - 1
+}
+
+// ELFファイルを出力する
+// ヘッダー、プログラムヘッダー、各セクションの中身、セクションヘッダーの順に並べる
+func (e *elfWriter) write(w io.Writer, m *SourceMap) error {
+
+	offset := uint32(elfHeaderSize + elfProgSize)
+	for i := range e.sections {
+		s := &e.sections[i]
+		if s.typ == elf.SHT_NOBITS || s.typ == elf.SHT_NULL {
+			continue
+		}
+		s.size = uint32(len(s.data))
+		if s.addralign > 1 {
+			offset = (offset + s.addralign - 1) &^ (s.addralign - 1)
+		}
+		s.offset = offset
+		offset += s.size
+	}
+	shoff := (offset + 3) &^ 3
+
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	put := func(v ...interface{}) {
+		for _, x := range v {
+			_ = binary.Write(&b, le, x)
+		}
+	}
+
+	// ELFヘッダー
+	b.Write([]byte{0x7F, 'E', 'L', 'F', byte(elf.ELFCLASS32), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT), byte(elf.ELFOSABI_NONE)})
+	b.Write(make([]byte, 8))
+	put(uint16(elf.ET_EXEC), uint16(elf.EM_386), uint32(elf.EV_CURRENT), uint32(m.Origin))
+	put(uint32(elfHeaderSize), shoff, uint32(0))
+	put(uint16(elfHeaderSize), uint16(elfProgSize), uint16(1), uint16(elfSectionSize), uint16(len(e.sections)), uint16(e.shstrndx))
+
+	// フラットバイナリを読み込むアドレスを示すプログラムヘッダー
+	put(uint32(elf.PT_LOAD), uint32(0), uint32(m.Origin), uint32(m.Origin), uint32(0), uint32(m.Size), uint32(elf.PF_R|elf.PF_W|elf.PF_X), uint32(1))
+
+	for _, s := range e.sections {
+		if s.data == nil {
+			continue
+		}
+		b.Write(make([]byte, int(s.offset)-b.Len()))
+		b.Write(s.data)
+	}
+	b.Write(make([]byte, int(shoff)-b.Len()))
+
+	for _, s := range e.sections {
+		put(s.name, uint32(s.typ), uint32(s.flags), s.addr, s.offset, s.size, s.link, s.info, s.addralign, s.entsize)
+	}
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// NUL終端の文字列を並べた文字列テーブル
+type stringTable struct {
+	bytes.Buffer
+}
+
+func newStringTable() *stringTable {
+	t := new(stringTable)
+	t.WriteByte(0)
+	return t
+}
+
+// 文字列を追加する
+//
+// @return テーブル上の位置 空文字列なら先頭のNUL
+func (t *stringTable) add(s string) uint32 {
+	if s == "" {
+		return 0
+	}
+	offset := uint32(t.Len())
+	t.WriteString(s)
+	t.WriteByte(0)
+	return offset
+}
+
+// ラベルのシンボルテーブルを作成する
+// 出力の範囲内のラベルは.textの、EQUの定数と出力の範囲外のラベルは絶対値のシンボルとする
+// EQUの定数は値が出力の範囲内であってもコードのラベルではないので.textには含めない
+//
+// @param text --- .textのセクション番号
+//
+// @return .symtab、.strtab
+func (r *Result) elfSymbols(text int) ([]byte, []byte) {
+
+	names := make([]string, 0, len(r.Symbols))
+	for name := range r.Symbols {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if r.Symbols[names[i]] != r.Symbols[names[j]] {
+			return r.Symbols[names[i]] < r.Symbols[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	var (
+		symtab bytes.Buffer
+		strtab = newStringTable()
+		m      = r.SourceMap
+	)
+	symtab.Write(make([]byte, elfSymSize))
+	for _, name := range names {
+		value := r.Symbols[name]
+		section := uint16(elf.SHN_ABS)
+		if !r.Constants[name] && m.Origin <= value && value <= m.Origin+m.Size {
+			section = uint16(text)
+		}
+		_ = binary.Write(&symtab, binary.LittleEndian, struct {
+			Name  uint32
+			Value uint32
+			Size  uint32
+			Info  uint8
+			Other uint8
+			Shndx uint16
+		}{strtab.add(name), uint32(value), 0, elf.ST_INFO(elf.STB_GLOBAL, elf.STT_NOTYPE), 0, section})
+	}
+	return symtab.Bytes(), strtab.Bytes()
+}
+
+// DWARFの属性の形式
+const (
+	dwFormAddr   = 0x01
+	dwFormData2  = 0x05
+	dwFormData4  = 0x06
+	dwFormString = 0x08
+)
+
+// DW_LANG_Mips_Assembler アセンブラのソースコードを表す言語
+const dwLangAssembler = 0x8001
+
+// .debug_abbrev
+// 子を持たないコンパイル単位1つだけを定義する
+func debugAbbrev() []byte {
+
+	var b bytes.Buffer
+	b.Write(uleb128(1))
+	b.Write(uleb128(uint64(dwarf.TagCompileUnit)))
+	b.WriteByte(0) // DW_CHILDREN_no
+	for _, a := range [][2]uint64{
+		{uint64(dwarf.AttrName), dwFormString},
+		{uint64(dwarf.AttrCompDir), dwFormString},
+		{uint64(dwarf.AttrProducer), dwFormString},
+		{uint64(dwarf.AttrLanguage), dwFormData2},
+		{uint64(dwarf.AttrStmtList), dwFormData4},
+		{uint64(dwarf.AttrLowpc), dwFormAddr},
+		{uint64(dwarf.AttrHighpc), dwFormAddr},
+	} {
+		b.Write(uleb128(a[0]))
+		b.Write(uleb128(a[1]))
+	}
+	b.Write([]byte{0, 0, 0})
+	return b.Bytes()
+}
+
+// .debug_info
+// DWARF 2のコンパイル単位1つで、出力全体のアドレスの範囲と.debug_lineの位置を示す
+func debugInfo(m *SourceMap, name, dir string) []byte {
+
+	var body bytes.Buffer
+	le := binary.LittleEndian
+	_ = binary.Write(&body, le, uint16(2)) // version
+	_ = binary.Write(&body, le, uint32(0)) // debug_abbrev_offset
+	body.WriteByte(4)                      // address_size
+	body.Write(uleb128(1))                 // DW_TAG_compile_unit
+	body.WriteString(name + "\x00")        // DW_AT_name
+	body.WriteString(dir + "\x00")         // DW_AT_comp_dir
+	body.WriteString("asm\x00")            // DW_AT_producer
+	_ = binary.Write(&body, le, uint16(dwLangAssembler))
+	_ = binary.Write(&body, le, uint32(0)) // DW_AT_stmt_list
+	_ = binary.Write(&body, le, uint32(m.Origin))
+	_ = binary.Write(&body, le, uint32(m.Origin+m.Size))
+
+	return withLength(body.Bytes())
+}
+
+// 行番号プログラムの標準命令
+const (
+	dwLNSCopy        = 1
+	dwLNSAdvanceLine = 3
+	dwLNSSetFile     = 4
+	dwLNEEndSequence = 1
+	dwLNESetAddress  = 2
+)
+
+// .debug_line
+// DWARF 2の行番号プログラムで、ソースマップの各範囲の先頭アドレスに行を対応付ける
+func debugLine(m *SourceMap, name string) []byte {
+
+	// ファイル名の一覧 番号は1から
+	var (
+		files   []string
+		fileIDs = make(map[string]int)
+	)
+	fileOf := func(file string) int {
+		if file == "" {
+			file = name
+		}
+		id, ok := fileIDs[file]
+		if !ok {
+			files = append(files, file)
+			id = len(files)
+			fileIDs[file] = id
+		}
+		return id
+	}
+	fileOf("")
+
+	var (
+		program bytes.Buffer
+		file    = 1
+		line    = 1
+	)
+	setAddress := func(address int64) {
+		program.WriteByte(0)
+		program.Write(uleb128(5))
+		program.WriteByte(dwLNESetAddress)
+		_ = binary.Write(&program, binary.LittleEndian, uint32(address))
+	}
+	for _, e := range m.Entries {
+		if e.Line <= 0 {
+			continue
+		}
+		setAddress(e.Address)
+		if id := fileOf(e.File); id != file {
+			program.WriteByte(dwLNSSetFile)
+			program.Write(uleb128(uint64(id)))
+			file = id
+		}
+		if e.Line != line {
+			program.WriteByte(dwLNSAdvanceLine)
+			program.Write(sleb128(int64(e.Line - line)))
+			line = e.Line
+		}
+		program.WriteByte(dwLNSCopy)
+	}
+	setAddress(m.Origin + m.Size)
+	program.Write([]byte{0, 1, dwLNEEndSequence})
+
+	// ヘッダーのheader_length以降
+	var header bytes.Buffer
+	header.WriteByte(1)                             // minimum_instruction_length
+	header.WriteByte(1)                             // default_is_stmt
+	header.WriteByte(0xFB)                          // line_base -5
+	header.WriteByte(14)                            // line_range
+	header.WriteByte(10)                            // opcode_base
+	header.Write([]byte{0, 1, 1, 1, 1, 0, 0, 0, 1}) // standard_opcode_lengths
+	header.WriteByte(0)                             // include_directories
+	for _, f := range files {
+		header.WriteString(f + "\x00")
+		header.Write([]byte{0, 0, 0}) // ディレクトリ、更新日時、サイズ
+	}
+	header.WriteByte(0)
+
+	var body bytes.Buffer
+	_ = binary.Write(&body, binary.LittleEndian, uint16(2))
+	_ = binary.Write(&body, binary.LittleEndian, uint32(header.Len()))
+	body.Write(header.Bytes())
+	body.Write(program.Bytes())
+
+	return withLength(body.Bytes())
+}
+
+// 先頭に32bitの長さを付ける
+func withLength(b []byte) []byte {
+	result := make([]byte, 4, 4+len(b))
+	binary.LittleEndian.PutUint32(result, uint32(len(b)))
+	return append(result, b...)
+}
+
+// 符号無しLEB128
+func uleb128(v uint64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7F)
+		v >>= 7
+		if v != 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if v == 0 {
+			return b
+		}
+	}
+}
+
+// 符号付きLEB128
+func sleb128(v int64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7F)
+		v >>= 7
+		if v == 0 && c&0x40 == 0 || v == -1 && c&0x40 != 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
@@ -0,0 +1,149 @@
+package assembler
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"debug/elf"
+	"strings"
+	"testing"
+
+	"go.nanasi880.dev/xtesting"
+)
+
+// debug/elfとdebug/dwarfで読み込み、シンボルと行番号がアセンブル結果と一致すること
+func TestResult_WriteDebugELF(t *testing.T) {
+
+	asmFile := xtesting.MustOpen(t, "testdata/include.txt")
+	defer xtesting.MustClose(t, asmFile)
+
+	result, err := New(WithIncludeFS(DirFS("testdata"))).Exec(asmFile, new(bytes.Buffer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := new(bytes.Buffer)
+	if err := result.WriteDebugELF(b, "include.txt", "/src"); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := elf.NewFile(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Class != elf.ELFCLASS32 || f.Machine != elf.EM_386 || f.Entry != 0x7c00 {
+		t.Fatal(f.FileHeader)
+	}
+	text := f.Section(".text")
+	if text == nil || text.Type != elf.SHT_NOBITS || text.Addr != 0x7c00 || text.Size != uint64(result.Size) {
+		t.Fatal(text)
+	}
+	if len(f.Progs) != 1 || f.Progs[0].Vaddr != 0x7c00 || f.Progs[0].Memsz != uint64(result.Size) {
+		t.Fatal(f.Progs)
+	}
+
+	symbols, err := f.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(symbols) != len(result.Symbols) {
+		t.Fatal(symbols)
+	}
+	for _, s := range symbols {
+		if v, ok := result.Symbols[s.Name]; !ok || uint64(v) != s.Value || s.Section != elf.SectionIndex(1) {
+			t.Fatal(s)
+		}
+	}
+
+	d, err := f.DWARF()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cu, err := d.Reader().Next()
+	if err != nil || cu == nil || cu.Tag != dwarf.TagCompileUnit || cu.Val(dwarf.AttrName) != "include.txt" {
+		t.Fatal(cu, err)
+	}
+	lr, err := d.LineReader(cu)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ソースマップの各範囲の中のアドレスが、その範囲の行に対応すること
+	for _, e := range result.SourceMap.Entries {
+		for pc := e.Address; pc < e.Address+e.Size; pc++ {
+			var entry dwarf.LineEntry
+			if err := lr.SeekPC(uint64(pc), &entry); err != nil {
+				t.Fatalf("0x%X: %v", pc, err)
+			}
+			want := "/src/include.txt"
+			if e.File != "" {
+				want = "/src/" + e.File
+			}
+			if entry.File.Name != want || entry.Line != e.Line {
+				t.Fatalf("0x%X: %s:%d, want %s:%d", pc, entry.File.Name, entry.Line, want, e.Line)
+			}
+		}
+	}
+	var entry dwarf.LineEntry
+	if err := lr.SeekPC(uint64(0x7c00+result.Size), &entry); err == nil {
+		t.Fatal(entry)
+	}
+}
+
+// EQUの定数は値が出力の範囲内であっても絶対値のシンボルとなること
+func TestResult_WriteDebugELFConstant(t *testing.T) {
+
+	src := "ORG 0x7c00\nSECT EQU 0x7c05\nstart:\nDB 1, 2, 3, 4, 5\nmsg:\nDB 0"
+	result, err := New().Exec(strings.NewReader(src), new(bytes.Buffer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := new(bytes.Buffer)
+	if err := result.WriteDebugELF(b, "main.asm", "/src"); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := elf.NewFile(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	symbols, err := f.Symbols()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]elf.SectionIndex{"SECT": elf.SHN_ABS, "start": 1, "msg": 1}
+	if len(symbols) != len(want) {
+		t.Fatal(symbols)
+	}
+	for _, s := range symbols {
+		if s.Section != want[s.Name] || s.Value != uint64(result.Symbols[s.Name]) || elf.ST_TYPE(s.Info) != elf.STT_NOTYPE {
+			t.Fatal(s)
+		}
+	}
+}
+
+func TestResult_WriteDebugELFError(t *testing.T) {
+
+	if err := new(Result).WriteDebugELF(new(bytes.Buffer), "", ""); err == nil {
+		t.Fatal("no source map")
+	}
+	result := &Result{SourceMap: &SourceMap{Origin: 0xFFFFFFFF, Size: 2}}
+	if err := result.WriteDebugELF(new(bytes.Buffer), "", ""); err == nil {
+		t.Fatal("out of range")
+	}
+}
+
+func TestLEB128(t *testing.T) {
+
+	for _, tt := range []struct {
+		v    int64
+		want []byte
+	}{
+		{0, []byte{0}}, {2, []byte{2}}, {-2, []byte{0x7E}}, {127, []byte{0xFF, 0}}, {-128, []byte{0x80, 0x7F}}, {129, []byte{0x81, 1}},
+	} {
+		if got := sleb128(tt.v); !bytes.Equal(got, tt.want) {
+			t.Fatal(tt.v, got)
+		}
+	}
+	if got := uleb128(624485); !bytes.Equal(got, []byte{0xE5, 0x8E, 0x26}) {
+		t.Fatal(got)
+	}
+}
@@ -0,0 +1,345 @@
+// Package encoder : Goのコードから機械語を組み立てるためのビルダー
+//
+//	b := encoder.New().Org(0x7c00)
+//	b.Mov(encoder.AX, encoder.Imm(0))
+//	b.Label("loop")
+//	b.Int(0x10)
+//	b.Jmp("loop")
+//	image, err := b.Bytes()
+//
+// 命令のエンコードはテキストのアセンブラと同じinstructionパッケージで行われるので、
+// 同じソースコードをアセンブルした場合と同じバイト列が得られる
+package encoder
+
+//go:generate go run ../../internal/x86gen -encoder mnemonic_gen.go ../instruction/x86.txt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler/expr"
+	"github.com/nanasi880/til/os/tool/asm/assembler/instruction"
+)
+
+// ビルダーに追加された要素
+type entry struct {
+	kind     entryKind
+	label    string                // ラベル名
+	mnemonic string                // 機械語命令のニーモニック
+	operands []instruction.Operand // 機械語命令のオペランド
+	data     []byte                // DBのデータ
+	value    int64                 // ORG, BITS, CPUの値
+}
+
+type entryKind int
+
+const (
+	entryLabel       entryKind = iota // ラベル
+	entryInstruction                  // 機械語命令
+	entryData                         // データ
+	entryOrg                          // ORG
+	entryBits                         // BITS
+	entryCPU                          // CPU
+)
+
+// 機械語を組み立てるビルダー
+// 各メソッドはエラーを返さず、最初に発生したエラーをBuild()/Bytes()で返す
+type Builder struct {
+	entries []entry
+	err     error
+}
+
+// 新しいビルダーを作成する
+// 初期状態は ORG 0、BITS 16、CPU 386
+func New() *Builder {
+	return new(Builder)
+}
+
+// 以降の命令が配置されるアドレスを指定する (ORG)
+func (b *Builder) Org(v int64) *Builder {
+	b.entries = append(b.entries, entry{kind: entryOrg, value: v})
+	return b
+}
+
+// 以降の命令を16bitモード、32bitモードのどちらでエンコードするかを指定する (BITS)
+func (b *Builder) Bits(bits int) *Builder {
+	if bits != 16 && bits != 32 {
+		b.setError(fmt.Errorf("invalid bits: %d", bits))
+		return b
+	}
+	b.entries = append(b.entries, entry{kind: entryBits, value: int64(bits)})
+	return b
+}
+
+// 以降の命令で使用できるCPUを指定する (CPU)
+// 指定したCPUより新しいCPUの命令はエラーになる
+func (b *Builder) CPU(cpu instruction.CPU) *Builder {
+	if cpu != instruction.CPU8086 && cpu != instruction.CPU186 && cpu != instruction.CPU386 {
+		b.setError(fmt.Errorf("invalid cpu: %d", cpu))
+		return b
+	}
+	b.entries = append(b.entries, entry{kind: entryCPU, value: int64(cpu)})
+	return b
+}
+
+// 現在の位置にラベルを定義する
+func (b *Builder) Label(name string) *Builder {
+	if !expr.IsSymbol(name) || name == "$" || name == "$$" {
+		b.setError(fmt.Errorf("invalid label name: %s", name))
+		return b
+	}
+	b.entries = append(b.entries, entry{kind: entryLabel, label: name})
+	return b
+}
+
+// 機械語命令を追加する
+//
+// @param mnemonic --- ニーモニック 大文字
+// @param operands --- オペランド
+func (b *Builder) Emit(mnemonic string, operands ...instruction.Operand) *Builder {
+	if !instruction.IsX86Mnemonic(mnemonic) {
+		b.setError(fmt.Errorf("unknown mnemonic `%s`", mnemonic))
+		return b
+	}
+	for _, op := range operands {
+		if imm, ok := op.(instruction.Immediate); ok && imm.Value == nil {
+			b.setError(fmt.Errorf("%s: invalid immediate operand", mnemonic))
+			return b
+		}
+	}
+	b.entries = append(b.entries, entry{kind: entryInstruction, mnemonic: mnemonic, operands: operands})
+	return b
+}
+
+// データを追加する (DB)
+func (b *Builder) DB(data ...byte) *Builder {
+	b.entries = append(b.entries, entry{kind: entryData, data: append([]byte(nil), data...)})
+	return b
+}
+
+// 文字列をデータとして追加する
+func (b *Builder) String(s string) *Builder {
+	return b.DB([]byte(s)...)
+}
+
+// 16bitの値をリトルエンディアンで追加する (DW)
+func (b *Builder) DW(values ...uint16) *Builder {
+	data := make([]byte, 0, len(values)*2)
+	for _, v := range values {
+		data = append(data, byte(v), byte(v>>8))
+	}
+	return b.DB(data...)
+}
+
+// ゼロで埋めた領域を追加する (RESB)
+func (b *Builder) Resb(n int) *Builder {
+	if n < 0 {
+		b.setError(fmt.Errorf("RESB underflow: %d", n))
+		return b
+	}
+	return b.DB(make([]byte, n)...)
+}
+
+// ジャンプ命令を追加する
+//
+// @param mnemonic --- ニーモニック
+// @param label    --- 飛び先のラベル
+func (b *Builder) jump(mnemonic string, label string) *Builder {
+	if !expr.IsSymbol(label) {
+		b.setError(fmt.Errorf("%s: invalid label `%s`", mnemonic, label))
+		return b
+	}
+	e, err := expr.Parse(label)
+	if err != nil {
+		b.setError(fmt.Errorf("%s: invalid label `%s`", mnemonic, label))
+		return b
+	}
+	return b.Emit(mnemonic, instruction.Immediate{Value: e})
+}
+
+func (b *Builder) setError(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// 追加した命令をエンコードし、ラベルを解決した命令の一覧を返す
+// 距離指定の無い前方へのジャンプはSHORTでエンコードし、届かなかったものはNEARにしてやり直す
+//
+// @return 命令の一覧、エラー
+func (b *Builder) Build() ([]instruction.Mnemonic, error) {
+
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	near := make(map[int]bool)
+	for {
+		mnemonics, owners, labels, err := b.build(near)
+		if err != nil {
+			return nil, err
+		}
+
+		retry := false
+		for i, m := range mnemonics {
+			err := m.Relocate(labels)
+			if errors.Is(err, instruction.ErrJumpOutOfRange) && !near[owners[i]] {
+				near[owners[i]] = true
+				retry = true
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", b.describe(owners[i]), err.Error())
+			}
+		}
+		if !retry {
+			return mnemonics, nil
+		}
+	}
+}
+
+// 命令を作成する
+//
+// @param near --- NEARでエンコードするジャンプ命令のエントリ番号
+//
+// @return 命令の一覧、各命令のエントリ番号、ラベルテーブル、エラー
+func (b *Builder) build(near map[int]bool) ([]instruction.Mnemonic, []int, map[string]int64, error) {
+
+	var (
+		origin    int64
+		address   int64
+		bits      = 16
+		cpu       = instruction.CPU386
+		labels    = make(map[string]int64)
+		mnemonics []instruction.Mnemonic
+		owners    []int
+	)
+	resolve := func(name string) (int64, error) {
+		switch name {
+		case "$":
+			return origin + address, nil
+		case "$$":
+			return origin, nil
+		}
+		if v, ok := labels[name]; ok {
+			return v, nil
+		}
+		return 0, expr.UndefinedSymbol(name)
+	}
+
+	for i, e := range b.entries {
+
+		var m instruction.Mnemonic
+		switch e.kind {
+
+		case entryLabel:
+			if _, ok := labels[e.label]; ok {
+				return nil, nil, nil, fmt.Errorf("ラベル名 %s は既に使用されています", e.label)
+			}
+			labels[e.label] = origin + address
+			continue
+
+		case entryInstruction:
+			operands := e.operands
+			if near[i] {
+				operands = nearOperands(operands)
+			}
+			x, err := instruction.NewX86(e.mnemonic, operands, bits, cpu, origin+address, origin, resolve)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("%s: %s", b.describe(i), err.Error())
+			}
+			m = x
+
+		case entryData:
+			m = instruction.NewDB(e.data)
+
+		case entryOrg:
+			if address != 0 {
+				return nil, nil, nil, fmt.Errorf("ORG must be specified before instructions")
+			}
+			origin = e.value
+			continue
+
+		case entryBits:
+			bits = int(e.value)
+			continue
+
+		case entryCPU:
+			cpu = instruction.CPU(e.value)
+			continue
+		}
+
+		mnemonics = append(mnemonics, m)
+		owners = append(owners, i)
+		address += m.Size()
+	}
+
+	return mnemonics, owners, labels, nil
+}
+
+// 距離指定の無いジャンプ先をNEARにしたオペランドを返す
+func nearOperands(operands []instruction.Operand) []instruction.Operand {
+
+	result := make([]instruction.Operand, len(operands))
+	for i, op := range operands {
+		if imm, ok := op.(instruction.Immediate); ok && imm.Distance == instruction.DistanceAuto {
+			imm.Distance = instruction.DistanceNear
+			op = imm
+		}
+		result[i] = op
+	}
+	return result
+}
+
+// エラーメッセージ用にエントリをテキストにする
+func (b *Builder) describe(i int) string {
+
+	e := b.entries[i]
+	s := e.mnemonic
+	for j, op := range e.operands {
+		if j == 0 {
+			s += " "
+		} else {
+			s += ", "
+		}
+		s += op.String()
+	}
+	return s
+}
+
+// 追加した命令をエンコードしたバイト列を返す
+//
+// @return バイト列、エラー
+func (b *Builder) Bytes() ([]byte, error) {
+
+	buf := new(bytes.Buffer)
+	if _, err := b.WriteTo(buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// 追加した命令をエンコードして書き込む
+//
+// @param w --- 出力先
+//
+// @return 書き込んだバイト数、エラー
+func (b *Builder) WriteTo(w io.Writer) (int64, error) {
+
+	mnemonics, err := b.Build()
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, m := range mnemonics {
+		n, err := m.Write(w)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
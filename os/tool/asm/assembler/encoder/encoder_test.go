@@ -0,0 +1,173 @@
+package encoder
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler"
+	"github.com/nanasi880/til/os/tool/asm/assembler/instruction"
+	"go.nanasi880.dev/xtesting"
+)
+
+// testdata/helloos.txt と同じプログラムをビルダーで組み立てる
+func buildHello() *Builder {
+
+	b := New().Org(0x7c00)
+
+	b.Jmp("entry")
+	b.DB(0x90)
+	b.String("HELLOIPL")
+	b.DW(512).DB(1).DW(1).DB(2).DW(224).DW(2880).DB(0xf0).DW(9).DW(18).DW(2)
+	b.DW(0, 0).DW(2880, 0)
+	b.DB(0, 0, 0x29)
+	b.DW(0xffff, 0xffff)
+	b.String("HELLO-OS   ")
+	b.String("FAT12   ")
+	b.Resb(18)
+
+	b.Label("entry")
+	b.Mov(AX, Imm(0))
+	b.Mov(SS, AX)
+	b.Mov(SP, Imm(0x7c00))
+	b.Mov(DS, AX)
+	b.Mov(ES, AX)
+
+	b.Mov(SI, Sym("msg"))
+	b.Label("putloop")
+	b.Mov(AL, Mem(SI, 0))
+	b.Add(SI, Imm(1))
+	b.Cmp(AL, Imm(0))
+	b.Je("fin")
+	b.Mov(AH, Imm(0x0e))
+	b.Mov(BX, Imm(15))
+	b.Int(0x10)
+	b.Jmp("putloop")
+	b.Label("fin")
+	b.Hlt()
+	b.Jmp("fin")
+
+	b.Label("msg")
+	b.DB(0x0a, 0x0a)
+	b.String("hello, world")
+	b.DB(0x0a)
+	b.DB(0)
+
+	return b
+}
+
+func TestBuilder_Hello(t *testing.T) {
+
+	asmFile := xtesting.MustOpen(t, "../testdata/helloos.txt")
+	defer xtesting.MustClose(t, asmFile)
+
+	want := new(bytes.Buffer)
+	if _, err := assembler.New().Exec(asmFile, want); err != nil {
+		t.Fatal(err)
+	}
+
+	b := buildHello()
+	got, err := b.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Compare(got, want.Bytes()[:len(got)]) != 0 {
+		t.Fatalf("% x", got)
+	}
+
+	mnemonics, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mnemonics[0].(*instruction.X86); !ok {
+		t.Fatalf("%T", mnemonics[0])
+	}
+	if _, ok := mnemonics[1].(*instruction.DB); !ok {
+		t.Fatalf("%T", mnemonics[1])
+	}
+}
+
+func TestBuilder_Jump(t *testing.T) {
+
+	testCases := []struct {
+		build func(b *Builder)
+		src   string
+	}{
+		{
+			build: func(b *Builder) { b.Label("top").Nop().Jmp("top") },
+			src:   "top:\nNOP\nJMP top",
+		},
+		{
+			build: func(b *Builder) { b.Jnz("far").Resb(200).Label("far") },
+			src:   "JNZ far\nRESB 200\nfar:",
+		},
+		{
+			build: func(b *Builder) { b.Call("sub").Label("sub").Ret() },
+			src:   "CALL sub\nsub:\nRET",
+		},
+		{
+			build: func(b *Builder) { b.Emit("JMP", Near("next")).Label("next") },
+			src:   "JMP NEAR next\nnext:",
+		},
+		{
+			build: func(b *Builder) { b.Bits(32).Mov(Dword(Mem(EBX, 4)), Imm(1)).Loop("$") },
+			src:   "BITS 32\nMOV DWORD [EBX+4],1\nLOOP $",
+		},
+		{
+			build: func(b *Builder) { b.CPU(instruction.CPU186).Push(Imm(1)) },
+			src:   "CPU 186\nPUSH 1",
+		},
+		{
+			build: func(b *Builder) { b.Mov(Byte(Seg(ES, MemIndex(BX, SI, -2))), Imm(0x41)) },
+			src:   "MOV BYTE [ES:BX+SI-2],0x41",
+		},
+	}
+
+	for i, tt := range testCases {
+
+		want := new(bytes.Buffer)
+		if _, err := assembler.New().Exec(strings.NewReader(tt.src), want); err != nil {
+			t.Fatal(i, err)
+		}
+
+		b := New()
+		tt.build(b)
+		got, err := b.Bytes()
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if bytes.Compare(got, want.Bytes()) != 0 {
+			t.Fatalf("%d: % x, want % x", i, got, want.Bytes())
+		}
+	}
+}
+
+func TestBuilder_Error(t *testing.T) {
+
+	testCases := []func(b *Builder){
+		func(b *Builder) { b.Emit("FOO") },
+		func(b *Builder) { b.Label("a").Nop().Label("a") },
+		func(b *Builder) { b.Jmp("nowhere") },
+		func(b *Builder) { b.Jmp("1+") },
+		func(b *Builder) { b.Jmp("1+2") },
+		func(b *Builder) { b.Label("a-b") },
+		func(b *Builder) { b.Label("1+2") },
+		func(b *Builder) { b.Label("$") },
+		func(b *Builder) { b.Mov(AX, BL) },
+		func(b *Builder) { b.Mov(AX, Sym("+")) },
+		func(b *Builder) { b.Nop().Org(0x7c00) },
+		func(b *Builder) { b.Bits(64) },
+		func(b *Builder) { b.CPU(286) },
+		func(b *Builder) { b.CPU(instruction.CPU8086).Push(Imm(1)) },
+		func(b *Builder) { b.CPU(instruction.CPU8086).Jnz("far").Resb(200).Label("far") },
+		func(b *Builder) { b.Emit("JMP", Short("far")).Resb(200).Label("far") },
+	}
+
+	for i, build := range testCases {
+		b := New()
+		build(b)
+		if _, err := b.Bytes(); err == nil {
+			t.Fatal(i)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+// Code generated by x86gen from x86.txt; DO NOT EDIT.
+
+package encoder
+
+import "github.com/nanasi880/til/os/tool/asm/assembler/instruction"
+
+// ADD命令を追加する
+func (b *Builder) Add(dst, src instruction.Operand) *Builder { return b.Emit("ADD", dst, src) }
+
+// OR命令を追加する
+func (b *Builder) Or(dst, src instruction.Operand) *Builder { return b.Emit("OR", dst, src) }
+
+// ADC命令を追加する
+func (b *Builder) Adc(dst, src instruction.Operand) *Builder { return b.Emit("ADC", dst, src) }
+
+// SBB命令を追加する
+func (b *Builder) Sbb(dst, src instruction.Operand) *Builder { return b.Emit("SBB", dst, src) }
+
+// AND命令を追加する
+func (b *Builder) And(dst, src instruction.Operand) *Builder { return b.Emit("AND", dst, src) }
+
+// SUB命令を追加する
+func (b *Builder) Sub(dst, src instruction.Operand) *Builder { return b.Emit("SUB", dst, src) }
+
+// XOR命令を追加する
+func (b *Builder) Xor(dst, src instruction.Operand) *Builder { return b.Emit("XOR", dst, src) }
+
+// CMP命令を追加する
+func (b *Builder) Cmp(dst, src instruction.Operand) *Builder { return b.Emit("CMP", dst, src) }
+
+// MOV命令を追加する
+func (b *Builder) Mov(dst, src instruction.Operand) *Builder { return b.Emit("MOV", dst, src) }
+
+// TEST命令を追加する
+func (b *Builder) Test(dst, src instruction.Operand) *Builder { return b.Emit("TEST", dst, src) }
+
+// LEA命令を追加する
+func (b *Builder) Lea(dst, src instruction.Operand) *Builder { return b.Emit("LEA", dst, src) }
+
+// INC命令を追加する
+func (b *Builder) Inc(op instruction.Operand) *Builder { return b.Emit("INC", op) }
+
+// DEC命令を追加する
+func (b *Builder) Dec(op instruction.Operand) *Builder { return b.Emit("DEC", op) }
+
+// NOT命令を追加する
+func (b *Builder) Not(op instruction.Operand) *Builder { return b.Emit("NOT", op) }
+
+// NEG命令を追加する
+func (b *Builder) Neg(op instruction.Operand) *Builder { return b.Emit("NEG", op) }
+
+// MUL命令を追加する
+func (b *Builder) Mul(op instruction.Operand) *Builder { return b.Emit("MUL", op) }
+
+// IMUL命令を追加する
+func (b *Builder) Imul(op instruction.Operand) *Builder { return b.Emit("IMUL", op) }
+
+// DIV命令を追加する
+func (b *Builder) Div(op instruction.Operand) *Builder { return b.Emit("DIV", op) }
+
+// IDIV命令を追加する
+func (b *Builder) Idiv(op instruction.Operand) *Builder { return b.Emit("IDIV", op) }
+
+// ROL命令を追加する
+func (b *Builder) Rol(dst, src instruction.Operand) *Builder { return b.Emit("ROL", dst, src) }
+
+// ROR命令を追加する
+func (b *Builder) Ror(dst, src instruction.Operand) *Builder { return b.Emit("ROR", dst, src) }
+
+// RCL命令を追加する
+func (b *Builder) Rcl(dst, src instruction.Operand) *Builder { return b.Emit("RCL", dst, src) }
+
+// RCR命令を追加する
+func (b *Builder) Rcr(dst, src instruction.Operand) *Builder { return b.Emit("RCR", dst, src) }
+
+// SHL命令を追加する
+func (b *Builder) Shl(dst, src instruction.Operand) *Builder { return b.Emit("SHL", dst, src) }
+
+// SHR命令を追加する
+func (b *Builder) Shr(dst, src instruction.Operand) *Builder { return b.Emit("SHR", dst, src) }
+
+// SAR命令を追加する
+func (b *Builder) Sar(dst, src instruction.Operand) *Builder { return b.Emit("SAR", dst, src) }
+
+// PUSH命令を追加する
+func (b *Builder) Push(op instruction.Operand) *Builder { return b.Emit("PUSH", op) }
+
+// POP命令を追加する
+func (b *Builder) Pop(op instruction.Operand) *Builder { return b.Emit("POP", op) }
+
+// JMP命令を追加する
+func (b *Builder) Jmp(label string) *Builder { return b.jump("JMP", label) }
+
+// CALL命令を追加する
+func (b *Builder) Call(label string) *Builder { return b.jump("CALL", label) }
+
+// JO命令を追加する
+func (b *Builder) Jo(label string) *Builder { return b.jump("JO", label) }
+
+// JNO命令を追加する
+func (b *Builder) Jno(label string) *Builder { return b.jump("JNO", label) }
+
+// JB命令を追加する
+func (b *Builder) Jb(label string) *Builder { return b.jump("JB", label) }
+
+// JAE命令を追加する
+func (b *Builder) Jae(label string) *Builder { return b.jump("JAE", label) }
+
+// JE命令を追加する
+func (b *Builder) Je(label string) *Builder { return b.jump("JE", label) }
+
+// JNE命令を追加する
+func (b *Builder) Jne(label string) *Builder { return b.jump("JNE", label) }
+
+// JBE命令を追加する
+func (b *Builder) Jbe(label string) *Builder { return b.jump("JBE", label) }
+
+// JA命令を追加する
+func (b *Builder) Ja(label string) *Builder { return b.jump("JA", label) }
+
+// JS命令を追加する
+func (b *Builder) Js(label string) *Builder { return b.jump("JS", label) }
+
+// JNS命令を追加する
+func (b *Builder) Jns(label string) *Builder { return b.jump("JNS", label) }
+
+// JP命令を追加する
+func (b *Builder) Jp(label string) *Builder { return b.jump("JP", label) }
+
+// JNP命令を追加する
+func (b *Builder) Jnp(label string) *Builder { return b.jump("JNP", label) }
+
+// JL命令を追加する
+func (b *Builder) Jl(label string) *Builder { return b.jump("JL", label) }
+
+// JGE命令を追加する
+func (b *Builder) Jge(label string) *Builder { return b.jump("JGE", label) }
+
+// JLE命令を追加する
+func (b *Builder) Jle(label string) *Builder { return b.jump("JLE", label) }
+
+// JG命令を追加する
+func (b *Builder) Jg(label string) *Builder { return b.jump("JG", label) }
+
+// JC命令を追加する
+func (b *Builder) Jc(label string) *Builder { return b.jump("JC", label) }
+
+// JNB命令を追加する
+func (b *Builder) Jnb(label string) *Builder { return b.jump("JNB", label) }
+
+// JZ命令を追加する
+func (b *Builder) Jz(label string) *Builder { return b.jump("JZ", label) }
+
+// JNZ命令を追加する
+func (b *Builder) Jnz(label string) *Builder { return b.jump("JNZ", label) }
+
+// JNA命令を追加する
+func (b *Builder) Jna(label string) *Builder { return b.jump("JNA", label) }
+
+// JNBE命令を追加する
+func (b *Builder) Jnbe(label string) *Builder { return b.jump("JNBE", label) }
+
+// JPE命令を追加する
+func (b *Builder) Jpe(label string) *Builder { return b.jump("JPE", label) }
+
+// JPO命令を追加する
+func (b *Builder) Jpo(label string) *Builder { return b.jump("JPO", label) }
+
+// JNGE命令を追加する
+func (b *Builder) Jnge(label string) *Builder { return b.jump("JNGE", label) }
+
+// JNL命令を追加する
+func (b *Builder) Jnl(label string) *Builder { return b.jump("JNL", label) }
+
+// JNG命令を追加する
+func (b *Builder) Jng(label string) *Builder { return b.jump("JNG", label) }
+
+// JNLE命令を追加する
+func (b *Builder) Jnle(label string) *Builder { return b.jump("JNLE", label) }
+
+// JNAE命令を追加する
+func (b *Builder) Jnae(label string) *Builder { return b.jump("JNAE", label) }
+
+// JNC命令を追加する
+func (b *Builder) Jnc(label string) *Builder { return b.jump("JNC", label) }
+
+// LOOP命令を追加する
+func (b *Builder) Loop(label string) *Builder { return b.jump("LOOP", label) }
+
+// LOOPE命令を追加する
+func (b *Builder) Loope(label string) *Builder { return b.jump("LOOPE", label) }
+
+// LOOPZ命令を追加する
+func (b *Builder) Loopz(label string) *Builder { return b.jump("LOOPZ", label) }
+
+// LOOPNE命令を追加する
+func (b *Builder) Loopne(label string) *Builder { return b.jump("LOOPNE", label) }
+
+// LOOPNZ命令を追加する
+func (b *Builder) Loopnz(label string) *Builder { return b.jump("LOOPNZ", label) }
+
+// JCXZ命令を追加する
+func (b *Builder) Jcxz(label string) *Builder { return b.jump("JCXZ", label) }
+
+// RET命令を追加する
+func (b *Builder) Ret(operands ...instruction.Operand) *Builder { return b.Emit("RET", operands...) }
+
+// RETF命令を追加する
+func (b *Builder) Retf(operands ...instruction.Operand) *Builder { return b.Emit("RETF", operands...) }
+
+// INT3命令を追加する
+func (b *Builder) Int3() *Builder { return b.Emit("INT3") }
+
+// INT命令を追加する
+func (b *Builder) Int(n int64) *Builder { return b.Emit("INT", Imm(n)) }
+
+// IRET命令を追加する
+func (b *Builder) Iret() *Builder { return b.Emit("IRET") }
+
+// IRETD命令を追加する
+func (b *Builder) Iretd() *Builder { return b.Emit("IRETD") }
+
+// IN命令を追加する
+func (b *Builder) In(dst, src instruction.Operand) *Builder { return b.Emit("IN", dst, src) }
+
+// OUT命令を追加する
+func (b *Builder) Out(dst, src instruction.Operand) *Builder { return b.Emit("OUT", dst, src) }
+
+// NOP命令を追加する
+func (b *Builder) Nop() *Builder { return b.Emit("NOP") }
+
+// HLT命令を追加する
+func (b *Builder) Hlt() *Builder { return b.Emit("HLT") }
+
+// CMC命令を追加する
+func (b *Builder) Cmc() *Builder { return b.Emit("CMC") }
+
+// CLC命令を追加する
+func (b *Builder) Clc() *Builder { return b.Emit("CLC") }
+
+// STC命令を追加する
+func (b *Builder) Stc() *Builder { return b.Emit("STC") }
+
+// CLI命令を追加する
+func (b *Builder) Cli() *Builder { return b.Emit("CLI") }
+
+// STI命令を追加する
+func (b *Builder) Sti() *Builder { return b.Emit("STI") }
+
+// CLD命令を追加する
+func (b *Builder) Cld() *Builder { return b.Emit("CLD") }
+
+// STD命令を追加する
+func (b *Builder) Std() *Builder { return b.Emit("STD") }
+
+// MOVSB命令を追加する
+func (b *Builder) Movsb() *Builder { return b.Emit("MOVSB") }
+
+// CMPSB命令を追加する
+func (b *Builder) Cmpsb() *Builder { return b.Emit("CMPSB") }
+
+// STOSB命令を追加する
+func (b *Builder) Stosb() *Builder { return b.Emit("STOSB") }
+
+// LODSB命令を追加する
+func (b *Builder) Lodsb() *Builder { return b.Emit("LODSB") }
+
+// SCASB命令を追加する
+func (b *Builder) Scasb() *Builder { return b.Emit("SCASB") }
+
+// MOVSW命令を追加する
+func (b *Builder) Movsw() *Builder { return b.Emit("MOVSW") }
+
+// MOVSD命令を追加する
+func (b *Builder) Movsd() *Builder { return b.Emit("MOVSD") }
+
+// CMPSW命令を追加する
+func (b *Builder) Cmpsw() *Builder { return b.Emit("CMPSW") }
+
+// CMPSD命令を追加する
+func (b *Builder) Cmpsd() *Builder { return b.Emit("CMPSD") }
+
+// STOSW命令を追加する
+func (b *Builder) Stosw() *Builder { return b.Emit("STOSW") }
+
+// STOSD命令を追加する
+func (b *Builder) Stosd() *Builder { return b.Emit("STOSD") }
+
+// LODSW命令を追加する
+func (b *Builder) Lodsw() *Builder { return b.Emit("LODSW") }
+
+// LODSD命令を追加する
+func (b *Builder) Lodsd() *Builder { return b.Emit("LODSD") }
+
+// SCASW命令を追加する
+func (b *Builder) Scasw() *Builder { return b.Emit("SCASW") }
+
+// SCASD命令を追加する
+func (b *Builder) Scasd() *Builder { return b.Emit("SCASD") }
+
+// PUSHF命令を追加する
+func (b *Builder) Pushf() *Builder { return b.Emit("PUSHF") }
+
+// PUSHFD命令を追加する
+func (b *Builder) Pushfd() *Builder { return b.Emit("PUSHFD") }
+
+// POPF命令を追加する
+func (b *Builder) Popf() *Builder { return b.Emit("POPF") }
+
+// POPFD命令を追加する
+func (b *Builder) Popfd() *Builder { return b.Emit("POPFD") }
+
+// PUSHA命令を追加する
+func (b *Builder) Pusha() *Builder { return b.Emit("PUSHA") }
+
+// PUSHAD命令を追加する
+func (b *Builder) Pushad() *Builder { return b.Emit("PUSHAD") }
+
+// POPA命令を追加する
+func (b *Builder) Popa() *Builder { return b.Emit("POPA") }
+
+// POPAD命令を追加する
+func (b *Builder) Popad() *Builder { return b.Emit("POPAD") }
+
+// CBW命令を追加する
+func (b *Builder) Cbw() *Builder { return b.Emit("CBW") }
+
+// CWDE命令を追加する
+func (b *Builder) Cwde() *Builder { return b.Emit("CWDE") }
+
+// CWD命令を追加する
+func (b *Builder) Cwd() *Builder { return b.Emit("CWD") }
+
+// CDQ命令を追加する
+func (b *Builder) Cdq() *Builder { return b.Emit("CDQ") }
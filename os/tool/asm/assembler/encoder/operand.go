@@ -0,0 +1,126 @@
+package encoder
+
+import (
+	"github.com/nanasi880/til/os/tool/asm/assembler/expr"
+	"github.com/nanasi880/til/os/tool/asm/assembler/instruction"
+)
+
+// レジスタ
+const (
+	AL = instruction.AL
+	CL = instruction.CL
+	DL = instruction.DL
+	BL = instruction.BL
+	AH = instruction.AH
+	CH = instruction.CH
+	DH = instruction.DH
+	BH = instruction.BH
+
+	AX = instruction.AX
+	CX = instruction.CX
+	DX = instruction.DX
+	BX = instruction.BX
+	SP = instruction.SP
+	BP = instruction.BP
+	SI = instruction.SI
+	DI = instruction.DI
+
+	EAX = instruction.EAX
+	ECX = instruction.ECX
+	EDX = instruction.EDX
+	EBX = instruction.EBX
+	ESP = instruction.ESP
+	EBP = instruction.EBP
+	ESI = instruction.ESI
+	EDI = instruction.EDI
+
+	ES = instruction.ES
+	CS = instruction.CS
+	SS = instruction.SS
+	DS = instruction.DS
+	FS = instruction.FS
+	GS = instruction.GS
+)
+
+// 即値オペランド
+//
+// @param v --- 値
+func Imm(v int64) instruction.Immediate {
+	return instruction.Immediate{Value: expr.FromInt(v)}
+}
+
+// ラベルやEQUを参照する即値オペランド
+// 式が不正な場合はBuild()でエラーになる
+//
+// @param s --- 式 "msg", "msg+1", "$-entry" など
+func Sym(s string) instruction.Immediate {
+	e, err := expr.Parse(s)
+	if err != nil {
+		return instruction.Immediate{Value: nil}
+	}
+	return instruction.Immediate{Value: e}
+}
+
+// SHORTを指定したジャンプ先
+func Short(label string) instruction.Immediate {
+	imm := Sym(label)
+	imm.Distance = instruction.DistanceShort
+	return imm
+}
+
+// NEARを指定したジャンプ先
+func Near(label string) instruction.Immediate {
+	imm := Sym(label)
+	imm.Distance = instruction.DistanceNear
+	return imm
+}
+
+// メモリオペランド [base+disp]
+//
+// @param base --- ベースレジスタ
+// @param disp --- ディスプレースメント
+func Mem(base instruction.Register, disp int64) instruction.Memory {
+	return MemIndex(base, instruction.NoRegister, disp)
+}
+
+// メモリオペランド [base+index+disp]
+//
+// @param base  --- ベースレジスタ
+// @param index --- インデックスレジスタ
+// @param disp  --- ディスプレースメント
+func MemIndex(base, index instruction.Register, disp int64) instruction.Memory {
+	m := instruction.Memory{Base: base, Index: index}
+	if disp != 0 {
+		m.Disp = expr.FromInt(disp)
+	}
+	return m
+}
+
+// アドレスを直接指定したメモリオペランド [addr]
+func Abs(addr int64) instruction.Memory {
+	return instruction.Memory{Disp: expr.FromInt(addr)}
+}
+
+// セグメントオーバーライドを指定する [seg:...]
+func Seg(seg instruction.Register, m instruction.Memory) instruction.Memory {
+	m.Segment = seg
+	return m
+}
+
+// BYTE [...]
+func Byte(m instruction.Memory) instruction.Memory {
+	m.Size = 1
+	return m
+}
+
+// WORD [...]
+func Word(m instruction.Memory) instruction.Memory {
+	m.Size = 2
+	return m
+}
+
+// DWORD [...]
+func Dword(m instruction.Memory) instruction.Memory {
+	m.Size = 4
+	return m
+}
@@ -0,0 +1,549 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"golang.org/x/text/encoding"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler/lexer"
+)
+
+// シンボル名から値を解決する関数
+type Resolver func(name string) (int64, error)
+
+// 未定義のシンボルを参照した
+type UndefinedSymbolError struct {
+	Name string // シンボル名
+	hint string // エラーメッセージに付加する補足
+}
+
+func (e *UndefinedSymbolError) Error() string {
+	if e.hint != "" {
+		return fmt.Sprintf("undeclared variable: %s (%s)", e.Name, e.hint)
+	}
+	return fmt.Sprintf("undeclared variable: %s", e.Name)
+}
+
+// パース済みの式
+// 評価に適した後置記法の命令列として保持する
+type Expr struct {
+	text     string
+	code     []instr
+	single   [1]instr // 単一の値だけからなる式の場合にcodeが指す領域
+	unsigned bool     // 2^63以上の数値リテラルを含むかどうか
+}
+
+// 後置記法の命令
+type instr struct {
+	op    opcode
+	value int64  // opPushの場合の値
+	name  string // opSymbolの場合のシンボル名 文字定数のopPushの場合はクォートを含む表記
+}
+
+type opcode uint8
+
+const (
+	opPush   opcode = iota // 定数
+	opSymbol               // シンボル
+	opNeg                  // 単項 -
+	opNot                  // 単項 ~
+	opLNot                 // 単項 !
+	opOr                   // |
+	opXor                  // ^
+	opAnd                  // &
+	opShl                  // <<
+	opShr                  // >>
+	opAdd                  // +
+	opSub                  // -
+	opMul                  // *
+	opDiv                  // /  符号なし除算
+	opSDiv                 // // 符号付き除算
+	opMod                  // %  符号なし剰余
+	opSMod                 // %% 符号付き剰余
+	opLOr                  // ||
+	opLXor                 // ^^
+	opLAnd                 // &&
+	opEq                   // == =
+	opNe                   // != <>
+	opLt                   // <  符号付き比較
+	opLe                   // <= 符号付き比較
+	opGt                   // >  符号付き比較
+	opGe                   // >= 符号付き比較
+)
+
+// 二項演算子
+type operator struct {
+	text string
+	op   opcode
+}
+
+// 二項演算子の優先順位表 nasmと同じく下に行くほど優先順位が高い
+var binaryOperators = [][]operator{
+	{{"||", opLOr}},
+	{{"^^", opLXor}},
+	{{"&&", opLAnd}},
+	{{"==", opEq}, {"=", opEq}, {"!=", opNe}, {"<>", opNe}, {"<", opLt}, {"<=", opLe}, {">", opGt}, {">=", opGe}},
+	{{"|", opOr}},
+	{{"^", opXor}},
+	{{"&", opAnd}},
+	{{"<<", opShl}, {">>", opShr}},
+	{{"+", opAdd}, {"-", opSub}},
+	{{"*", opMul}, {"/", opDiv}, {"//", opSDiv}, {"%", opMod}, {"%%", opSMod}},
+}
+
+// 式をパースする
+// 演算子の優先順位はnasmと同じで、低いものから順に
+//
+//	||
+//	^^
+//	&&
+//	== = != <> < <= > >=
+//	|
+//	^
+//	&
+//	<< >>
+//	+ -
+//	* / // % %%
+//	単項 - + ~ !
+//
+// となる 括弧で優先順位を変更できる
+//
+// @param s --- 式
+//
+// @return パース済みの式、エラー
+func Parse(s string) (*Expr, error) {
+
+	// データ定義命令のオペランドの大半は単一の数値リテラルなので、字句解析を省略する
+	if isNumberLiteral(s) {
+		v, err := ParseNumber(s)
+		if err != nil {
+			return nil, err
+		}
+		e := &Expr{text: s, unsigned: v < 0}
+		e.single[0] = instr{op: opPush, value: v}
+		e.code = e.single[:]
+		return e, nil
+	}
+
+	tokens, err := Scan(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty expression")
+	}
+
+	p := &parser{
+		tokens: tokens,
+		code:   make([]instr, 0, len(tokens)),
+	}
+	if err := p.binary(0); err != nil {
+		return nil, fmt.Errorf("%s: %s", s, err.Error())
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("%s: unexpected `%s`", s, p.tokens[p.pos].Text)
+	}
+
+	return &Expr{
+		text:     s,
+		code:     p.code,
+		unsigned: p.unsigned,
+	}, nil
+}
+
+// 定数だけからなる式を作成する
+// 文字列表現は16進数になる
+//
+// @param v --- 値
+//
+// @return 式
+func FromInt(v int64) *Expr {
+
+	text := fmt.Sprintf("0x%X", v)
+	if v < 0 {
+		text = fmt.Sprintf("-0x%X", uint64(-v))
+	}
+	e := &Expr{text: text}
+	e.single[0] = instr{op: opPush, value: v}
+	e.code = e.single[:]
+	return e
+}
+
+// 式の文字列表現
+func (e *Expr) String() string {
+	return e.text
+}
+
+// 文字定数の値を、指定した文字コードに変換したバイト列から求め直す
+// Parse()は文字定数をUTF-8として解釈するので、DB命令の文字列と同じ文字コードを使う場合に呼ぶ
+//
+// @param enc --- 文字コード
+//
+// @return エラー
+func (e *Expr) EncodeChars(enc encoding.Encoding) error {
+
+	for i := range e.code {
+		c := &e.code[i]
+		if c.op != opPush || c.name == "" {
+			continue
+		}
+		b, err := lexer.UnquoteWith(lexer.Token(c.name), enc.NewEncoder())
+		if err != nil {
+			return err
+		}
+		v, err := packChars(b, c.name)
+		if err != nil {
+			return err
+		}
+		c.value = v
+	}
+	return nil
+}
+
+// 式が2^63以上の数値リテラルを含むかどうか
+// そのようなリテラルはint64のビットパターンとして扱われるので、評価結果が負の値であれば符号なしの値と解釈する必要がある
+func (e *Expr) Unsigned() bool {
+	return e.unsigned
+}
+
+// 式がシンボルを含まない定数であればその値を返す
+//
+// @return 値、定数かどうか
+func (e *Expr) Constant() (int64, bool) {
+
+	for _, c := range e.code {
+		if c.op == opSymbol {
+			return 0, false
+		}
+	}
+	v, err := e.Eval(nil)
+	return v, err == nil
+}
+
+// 式が参照しているシンボル名の一覧を返す
+func (e *Expr) Symbols() []string {
+
+	var result []string
+	for _, c := range e.code {
+		if c.op == opSymbol {
+			result = append(result, c.name)
+		}
+	}
+	return result
+}
+
+// 式を評価する
+// 演算はint64で行われ、オーバーフローやゼロ除算はエラーとなる
+//
+// @param resolve --- シンボル名の解決に使用する関数 式がシンボルを含まない場合はnilでもよい
+//
+// @return 評価結果、エラー
+func (e *Expr) Eval(resolve Resolver) (int64, error) {
+
+	// 一般的な式であればスタックはこの程度で足りる
+	var (
+		buf   [16]int64
+		stack = buf[:0]
+	)
+	for _, c := range e.code {
+
+		switch c.op {
+
+		case opPush:
+			stack = append(stack, c.value)
+			continue
+
+		case opSymbol:
+			if resolve == nil {
+				return 0, UndefinedSymbol(c.name)
+			}
+			v, err := resolve(c.name)
+			if err != nil {
+				return 0, err
+			}
+			stack = append(stack, v)
+			continue
+
+		case opNeg, opNot, opLNot:
+			x := &stack[len(stack)-1]
+			switch c.op {
+			case opNeg:
+				if *x == math.MinInt64 {
+					return 0, e.overflow()
+				}
+				*x = -*x
+			case opNot:
+				*x = ^*x
+			case opLNot:
+				if *x == 0 {
+					*x = 1
+				} else {
+					*x = 0
+				}
+			}
+			continue
+		}
+
+		x, y := stack[len(stack)-2], stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		v, err := e.binary(c.op, x, y)
+		if err != nil {
+			return 0, err
+		}
+		stack[len(stack)-1] = v
+	}
+
+	return stack[0], nil
+}
+
+// 二項演算を行う
+// 論理演算と比較の結果は真なら1、偽なら0となる
+func (e *Expr) binary(op opcode, x, y int64) (int64, error) {
+
+	switch op {
+
+	case opLOr:
+		return boolValue(x != 0 || y != 0), nil
+	case opLXor:
+		return boolValue((x != 0) != (y != 0)), nil
+	case opLAnd:
+		return boolValue(x != 0 && y != 0), nil
+	case opEq:
+		return boolValue(x == y), nil
+	case opNe:
+		return boolValue(x != y), nil
+	case opLt:
+		return boolValue(x < y), nil
+	case opLe:
+		return boolValue(x <= y), nil
+	case opGt:
+		return boolValue(x > y), nil
+	case opGe:
+		return boolValue(x >= y), nil
+
+	case opOr:
+		return x | y, nil
+	case opXor:
+		return x ^ y, nil
+	case opAnd:
+		return x & y, nil
+
+	case opShl:
+		if y < 0 || y > 63 {
+			return 0, fmt.Errorf("%s: shift count out of range: %d", e.text, y)
+		}
+		v := x << uint(y)
+		// 符号付き、符号なしいずれかの解釈でビットが失われていなければ許容する
+		if v>>uint(y) != x && uint64(v)>>uint(y) != uint64(x) {
+			return 0, e.overflow()
+		}
+		return v, nil
+
+	case opShr:
+		if y < 0 || y > 63 {
+			return 0, fmt.Errorf("%s: shift count out of range: %d", e.text, y)
+		}
+		return int64(uint64(x) >> uint(y)), nil
+
+	case opAdd:
+		v := x + y
+		if (x > 0 && y > 0 && v < 0) || (x < 0 && y < 0 && v >= 0) {
+			return 0, e.overflow()
+		}
+		return v, nil
+
+	case opSub:
+		v := x - y
+		if (x >= 0 && y < 0 && v < 0) || (x < 0 && y > 0 && v >= 0) {
+			return 0, e.overflow()
+		}
+		return v, nil
+
+	case opMul:
+		if x == 0 || y == 0 {
+			return 0, nil
+		}
+		v := x * y
+		if v/y != x || (x == -1 && y == math.MinInt64) || (y == -1 && x == math.MinInt64) {
+			return 0, e.overflow()
+		}
+		return v, nil
+
+	case opDiv, opSDiv, opMod, opSMod:
+		if y == 0 {
+			return 0, fmt.Errorf("%s: division by zero", e.text)
+		}
+		switch op {
+		case opDiv:
+			return int64(uint64(x) / uint64(y)), nil
+		case opMod:
+			return int64(uint64(x) % uint64(y)), nil
+		case opSDiv:
+			if x == math.MinInt64 && y == -1 {
+				return 0, e.overflow()
+			}
+			return x / y, nil
+		default:
+			if y == -1 {
+				return 0, nil
+			}
+			return x % y, nil
+		}
+	}
+
+	return 0, fmt.Errorf("internal: unknown opcode %d", op)
+}
+
+// 真偽値を1または0に変換する
+func boolValue(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (e *Expr) overflow() error {
+	return fmt.Errorf("%s: integer overflow", e.text)
+}
+
+// 式のパーサー
+// 再帰下降で後置記法の命令列を組み立てる
+type parser struct {
+	tokens   []Token
+	pos      int
+	code     []instr
+	unsigned bool // 2^63以上の数値リテラルを含むかどうか
+}
+
+// 指定した優先順位以上の二項演算子で構成される式をパースする
+func (p *parser) binary(level int) error {
+
+	if level >= len(binaryOperators) {
+		return p.unary()
+	}
+
+	if err := p.binary(level + 1); err != nil {
+		return err
+	}
+	for p.pos < len(p.tokens) {
+
+		tok := p.tokens[p.pos]
+		if tok.Kind != Operator {
+			return fmt.Errorf("unexpected `%s`", tok.Text)
+		}
+
+		op, ok := lookupOperator(binaryOperators[level], tok.Text)
+		if !ok {
+			return nil
+		}
+		p.pos++
+
+		if err := p.binary(level + 1); err != nil {
+			return err
+		}
+		p.code = append(p.code, instr{op: op})
+	}
+
+	return nil
+}
+
+// 単項演算子、括弧、値をパースする
+func (p *parser) unary() error {
+
+	if p.pos >= len(p.tokens) {
+		return errors.New("unexpected end of expression")
+	}
+
+	tok := p.tokens[p.pos]
+	p.pos++
+
+	switch tok.Kind {
+
+	case Number:
+		// 数値リテラルは負にならないので、負の値は2^63以上のリテラルである
+		if tok.Value < 0 {
+			p.unsigned = true
+		}
+		p.code = append(p.code, instr{op: opPush, value: tok.Value})
+		return nil
+
+	case Char:
+		p.code = append(p.code, instr{op: opPush, value: tok.Value, name: tok.Text})
+		return nil
+
+	case Ident:
+		p.code = append(p.code, instr{op: opSymbol, name: tok.Text})
+		return nil
+	}
+
+	switch tok.Text {
+
+	case "(":
+		if err := p.binary(0); err != nil {
+			return err
+		}
+		if p.pos >= len(p.tokens) || p.tokens[p.pos].Text != ")" {
+			return errors.New("missing `)`")
+		}
+		p.pos++
+		return nil
+
+	case "+":
+		return p.unary()
+
+	case "-", "~", "!":
+		// -0x8000000000000000は符号付き64bitの最小値として扱う
+		if tok.Text == "-" && p.pos < len(p.tokens) && p.tokens[p.pos].Kind == Number && p.tokens[p.pos].Value == math.MinInt64 {
+			p.pos++
+			p.code = append(p.code, instr{op: opPush, value: math.MinInt64})
+			return nil
+		}
+		if err := p.unary(); err != nil {
+			return err
+		}
+		op := opNeg
+		if tok.Text == "~" {
+			op = opNot
+		} else if tok.Text == "!" {
+			op = opLNot
+		}
+
+		// 定数の符号反転はその場で畳み込む
+		last := &p.code[len(p.code)-1]
+		if op == opNeg && last.op == opPush && last.name == "" && last.value != math.MinInt64 {
+			last.value = -last.value
+			return nil
+		}
+		p.code = append(p.code, instr{op: op})
+		return nil
+	}
+
+	return fmt.Errorf("unexpected `%s`", tok.Text)
+}
+
+// 優先順位が同じ演算子の中から表記が一致するものを探す
+func lookupOperator(operators []operator, text string) (opcode, bool) {
+
+	for _, o := range operators {
+		if o.text == text {
+			return o.op, true
+		}
+	}
+	return 0, false
+}
+
+// 文字列全体が1つの数値リテラルかどうか
+func isNumberLiteral(s string) bool {
+
+	if len(s) == 0 || !isDigit(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isIdentChar(s[i]) || s[i] == '$' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,247 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"go.nanasi880.dev/rpn"
+	"golang.org/x/text/encoding/japanese"
+)
+
+func TestExpr_Eval(t *testing.T) {
+
+	symbols := map[string]int64{
+		"$":     0x7C10,
+		"$$":    0x7C00,
+		"label": 0x7C40,
+	}
+	resolve := func(name string) (int64, error) {
+		if v, ok := symbols[name]; ok {
+			return v, nil
+		}
+		return 0, UndefinedSymbol(name)
+	}
+
+	testCases := []struct {
+		s    string
+		want int64
+		err  string
+	}{
+		{s: "1+2*3", want: 7},
+		{s: "(1+2)*3", want: 9},
+		{s: "1 | 2 ^ 3 & 4", want: 1 | (2 ^ (3 & 4))},
+		{s: "1 << 4 + 1", want: 1 << 5},
+		{s: "0xF0 >> 4 - 2", want: 0xF0 >> 2},
+		{s: "-1 + -(2 * 3)", want: -7},
+		{s: "~0 & 0xFF", want: 0xFF},
+		{s: "!0 + !5", want: 1},
+		{s: "+5 - -5", want: 10},
+		{s: "7 / 2 + 7 % 2", want: 4},
+		{s: "-7 // 2", want: -3},
+		{s: "-7 %% 2", want: -1},
+		{s: "-8 / 2", want: int64(uint64(1<<63) - 4)},
+		{s: "-1 >> 60", want: 0xF},
+		{s: "'A' + 1", want: 'B'},
+		{s: "'AB'", want: 0x4241},
+		{s: "0FFh + 1010b + 17q", want: 0xFF + 10 + 15},
+		{s: "0x1fe-$", want: 0x1FE - 0x7C10},
+		{s: "$-$$", want: 0x10},
+		{s: "label-$$", want: 0x40},
+		{s: "1 << 63", want: -1 << 63},
+		{s: "-0x8000000000000000", want: -1 << 63},
+		{s: "-9223372036854775808 + 1", want: -1<<63 + 1},
+		{s: "$-$$ <= 0x10", want: 1},
+		{s: "$-$$ < 0x10", want: 0},
+		{s: "-1 < 0 && 2 >= 2", want: 1},
+		{s: "1 == 2 || 3 <> 3", want: 0},
+		{s: "1 = 1 ^^ 2 != 2", want: 1},
+		{s: "1 | 2 == 3", want: 1},
+		{s: "label > $ + 0x20", want: 1},
+		{s: "0x7FFFFFFFFFFFFFFF + 1", err: "integer overflow"},
+		{s: "-0x7FFFFFFFFFFFFFFF - 2", err: "integer overflow"},
+		{s: "0x100000000 * 0x100000000", err: "integer overflow"},
+		{s: "3 << 63", err: "integer overflow"},
+		{s: "1 << 64", err: "shift count out of range"},
+		{s: "1 / 0", err: "division by zero"},
+		{s: "1 %% (1-1)", err: "division by zero"},
+		{s: "unknown + 1", err: "undeclared variable: unknown"},
+		{s: "ABh", err: "0ABh"},
+		{s: "(1 + 2", err: "missing `)`"},
+		{s: "1 2", err: "unexpected `2`"},
+		{s: "1 +", err: "unexpected end of expression"},
+		{s: "1 # 2", err: "unexpected `#`"},
+	}
+
+	for _, tt := range testCases {
+
+		e, err := Parse(tt.s)
+		if err == nil {
+			var v int64
+			v, err = e.Eval(resolve)
+			if err == nil && tt.err == "" {
+				if v != tt.want {
+					t.Fatalf("%s: %d != %d", tt.s, v, tt.want)
+				}
+				continue
+			}
+		}
+		if tt.err == "" || !strings.Contains(err.Error(), tt.err) {
+			t.Fatalf("%s: %v", tt.s, err)
+		}
+	}
+}
+
+func TestExpr_Constant(t *testing.T) {
+
+	e, err := Parse("2 * (3 + 4)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := e.Constant(); !ok || v != 14 {
+		t.Fatal(v, ok)
+	}
+
+	e, err = Parse("label + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := e.Constant(); ok {
+		t.Fatal(e)
+	}
+	if s := e.Symbols(); len(s) != 1 || s[0] != "label" {
+		t.Fatal(s)
+	}
+}
+
+func TestExpr_EncodeChars(t *testing.T) {
+
+	e, err := Parse("-'あ' + 'A'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := e.Eval(nil); err != nil || v != -0x8281E3+'A' {
+		t.Fatal(v, err)
+	}
+	if err := e.EncodeChars(japanese.ShiftJIS); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := e.Eval(nil); err != nil || v != -0xA082+'A' {
+		t.Fatal(v, err)
+	}
+}
+
+func TestExpr_Unsigned(t *testing.T) {
+
+	for text, want := range map[string]bool{
+		"0xFFFFFFFFFFFFFFFF":         true,
+		"0x8000000000000000 | 1":     true,
+		"-1":                         false,
+		"0x7FFFFFFFFFFFFFFF":         false,
+		"label + 0xFFFFFFFFFFFFFFFF": true,
+	} {
+		e, err := Parse(text)
+		if err != nil {
+			t.Fatal(text, err)
+		}
+		if e.Unsigned() != want {
+			t.Fatal(text)
+		}
+	}
+}
+
+func TestIsSymbol(t *testing.T) {
+
+	for text, want := range map[string]bool{
+		"label":   true,
+		".loop":   true,
+		"?x@1":    true,
+		"$":       true,
+		"":        false,
+		"1label":  false,
+		"1+2":     false,
+		"a-b":     false,
+		"msg + 1": false,
+		"'A'":     false,
+	} {
+		if IsSymbol(text) != want {
+			t.Fatal(text)
+		}
+	}
+}
+
+// 10万行のDB命令のオペランドに相当する式を評価する
+// 従来のrpn + decimalによる評価との比較用
+func BenchmarkParseEval(b *testing.B) {
+
+	operands := make([]string, 0, 100000*8)
+	for i := 0; i < 100000; i++ {
+		for j := 0; j < 8; j++ {
+			operands = append(operands, fmt.Sprintf("0x%02x", (i+j)&0xFF))
+		}
+	}
+
+	b.Run("rpn", func(b *testing.B) {
+		resolve := func(name string) (decimal.Decimal, error) {
+			return decimal.Zero, fmt.Errorf("undeclared variable: %s", name)
+		}
+		for i := 0; i < b.N; i++ {
+			for _, s := range operands {
+				r, err := rpn.Parse(s)
+				if err != nil {
+					b.Fatal(err)
+				}
+				d, err := r.Eval(resolve)
+				if err != nil {
+					b.Fatal(err)
+				}
+				_ = d.IntPart()
+			}
+		}
+	})
+
+	b.Run("expr", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, s := range operands {
+				e, err := Parse(s)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := e.Eval(nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func TestFromInt(t *testing.T) {
+
+	testCases := []struct {
+		v    int64
+		text string
+	}{
+		{v: 0, text: "0x0"},
+		{v: 0x7c00, text: "0x7C00"},
+		{v: -2, text: "-0x2"},
+	}
+
+	for _, tt := range testCases {
+		e := FromInt(tt.v)
+		if e.String() != tt.text {
+			t.Fatal(e.String())
+		}
+		if v, ok := e.Constant(); !ok || v != tt.v {
+			t.Fatal(v, ok)
+		}
+		// 文字列表現をパースし直しても同じ値になること
+		p, err := Parse(e.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, _ := p.Eval(nil); v != tt.v {
+			t.Fatal(v)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package expr
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// 数値リテラルの基数を表す接頭辞/接尾辞の文字と基数の対応
+// 0x, 0h, 0d, 0t, 0o, 0q, 0b, 0y の2文字目 及び 接尾辞として使用される
+func radixOf(c byte) int {
+	switch c | 0x20 {
+	case 'x', 'h':
+		return 16
+	case 'd', 't':
+		return 10
+	case 'o', 'q':
+		return 8
+	case 'b', 'y':
+		return 2
+	}
+	return 0
+}
+
+// 数値リテラルを解釈する
+// nasm/naskで使用される以下の表記に対応する 英字の大文字小文字は区別しない
+//
+//	10進数 123  0d123  123d
+//	16進数 0x7B 0h7B   7Bh   $7B
+//	8進数  0o173 0q173 173o  173q
+//	2進数  0b1111011 0y1111011 1111011b 1111011y
+//
+// 数字の間には読みやすさのためにアンダースコアを置くことができる (0b0111_1011)
+// 接尾辞形式は数字で始まる必要がある FFh はシンボル名であり、16進数は 0FFh と書く
+// 0B800h や 0D0h のように接頭辞にも見えるリテラルは、接尾辞の基数で全体を読めればそちらを優先し、先頭の0を数字とみなす
+// 接頭辞と接尾辞のどちらで読んでも解釈できないリテラル (0x1Fh など) は曖昧なのでエラーとする
+// 64bitを超える値はエラーとなる 符号なし64bitの範囲の値はint64のビットパターンとして返す
+//
+// @param text --- 数値リテラル
+//
+// @return 値、エラー
+func ParseNumber(text string) (int64, error) {
+
+	if text == "" {
+		return 0, fmt.Errorf("invalid numeric literal: empty")
+	}
+
+	var (
+		base   = 10
+		digits = text
+		prefix string
+	)
+	switch {
+	case len(text) > 1 && text[0] == '$' && isDigit(text[1]):
+		prefix, base, digits = text[:1], 16, text[1:]
+	case len(text) > 2 && text[0] == '0' && radixOf(text[1]) != 0:
+		prefix, base, digits = text[:2], radixOf(text[1]), text[2:]
+	}
+
+	last := text[len(text)-1]
+	if b := radixOf(last); b != 0 && last|0x20 != 'x' {
+		if prefix == "" {
+			base, digits = b, text[:len(text)-1]
+		} else if v, err := parseDigitsWithBase(text[:len(text)-1], b); err == nil {
+			return int64(v), nil
+		} else if digitValue(last) >= base {
+			return 0, fmt.Errorf("ambiguous numeric literal %s: it has both prefix %s and suffix %c", text, prefix, last)
+		}
+	}
+
+	if !isDigit(text[0]) && prefix != "$" {
+		return 0, fmt.Errorf("invalid numeric literal %s: must start with a digit", text)
+	}
+
+	v, err := parseDigitsWithBase(digits, base)
+	if err != nil {
+		return 0, fmt.Errorf("invalid numeric literal %s: %s", text, err.Error())
+	}
+	return int64(v), nil
+}
+
+// 指定した基数で数字列を解釈する
+// アンダースコアは無視される
+//
+// @param s    --- 数字列
+// @param base --- 基数
+//
+// @return 値、エラー
+func parseDigitsWithBase(s string, base int) (uint64, error) {
+
+	var (
+		v uint64
+		n int
+	)
+	for i := 0; i < len(s); i++ {
+
+		c := s[i]
+		if c == '_' {
+			continue
+		}
+
+		d := digitValue(c)
+		if d < 0 || d >= base {
+			return 0, fmt.Errorf("invalid digit '%c' for base %d", c, base)
+		}
+
+		hi, lo := bits.Mul64(v, uint64(base))
+		lo, carry := bits.Add64(lo, uint64(d), 0)
+		if hi != 0 || carry != 0 {
+			return 0, fmt.Errorf("out of 64bit range")
+		}
+		v = lo
+		n++
+	}
+
+	if n == 0 {
+		return 0, fmt.Errorf("no digits")
+	}
+	return v, nil
+}
+
+// 数字1文字の値を返す 数字でなければ-1を返す
+func digitValue(c byte) int {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c - '0')
+	case 'a' <= c && c <= 'z':
+		return int(c-'a') + 10
+	case 'A' <= c && c <= 'Z':
+		return int(c-'A') + 10
+	}
+	return -1
+}
+
+// 浮動小数点数リテラルかどうかを判定する
+// nasmと同様に小数点を含むものを浮動小数点数とみなす 符号と指数部を付けることができる
+//
+//	1.5  -0.25  1.e10  3.0e-5  0x1.8p3
+//
+// 浮動小数点数は式の中では使用できず、データ定義命令のオペランドとしてのみ使用できる
+//
+// @param s --- 判定対象
+//
+// @return 浮動小数点数リテラルかどうか
+func IsFloatLiteral(s string) bool {
+
+	if len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		s = s[1:]
+	}
+	if len(s) == 0 || !isDigit(s[0]) {
+		return false
+	}
+
+	hex := len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X')
+	if hex {
+		s = s[2:]
+	}
+
+	var (
+		point    bool
+		exponent bool
+	)
+	for i := 0; i < len(s); i++ {
+
+		c := s[i]
+		switch {
+
+		case c == '.' && !point && !exponent:
+			point = true
+
+		case !exponent && (!hex && (c == 'e' || c == 'E') || hex && (c == 'p' || c == 'P')):
+			exponent = true
+			if i+1 < len(s) && (s[i+1] == '+' || s[i+1] == '-') {
+				i++
+			}
+			if i+1 >= len(s) {
+				return false
+			}
+
+		case isDigit(c) || c == '_':
+
+		case hex && !exponent && digitValue(c) >= 0 && digitValue(c) < 16:
+
+		default:
+			return false
+		}
+	}
+
+	// 16進数の浮動小数点数は指数部が必須
+	return point && (!hex || exponent)
+}
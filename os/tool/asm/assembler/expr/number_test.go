@@ -0,0 +1,104 @@
+package expr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseNumber(t *testing.T) {
+
+	testCases := []struct {
+		text string
+		want int64
+		err  string
+	}{
+		{text: "123", want: 123},
+		{text: "0d123", want: 123},
+		{text: "123d", want: 123},
+		{text: "1_000_000", want: 1000000},
+		{text: "0x7B", want: 0x7B},
+		{text: "0h7b", want: 0x7B},
+		{text: "0FFh", want: 0xFF},
+		{text: "0ABCDH", want: 0xABCD},
+		{text: "$0A", want: 0x0A},
+		{text: "0x1b", want: 0x1B},
+		{text: "777q", want: 0777},
+		{text: "777o", want: 0777},
+		{text: "0o17", want: 017},
+		{text: "1010b", want: 10},
+		{text: "1010y", want: 10},
+		{text: "0b1010_0000", want: 0xA0},
+		{text: "0y11", want: 3},
+		{text: "0xFFFFFFFFFFFFFFFF", want: -1},
+		{text: "0x10000000000000000", err: "out of 64bit range"},
+		{text: "0B800h", want: 0xB800},
+		{text: "0BEEFh", want: 0xBEEF},
+		{text: "0DEADh", want: 0xDEAD},
+		{text: "0Bh", want: 0x0B},
+		{text: "0D0h", want: 0xD0},
+		{text: "0b10h", want: 0xB10},
+		{text: "0d1b", err: "ambiguous"},
+		{text: "0x1Fh", err: "ambiguous"},
+		{text: "102b", err: "invalid digit '2'"},
+		{text: "8q", err: "invalid digit '8'"},
+		{text: "12a", err: "invalid digit 'a'"},
+		{text: "0x", err: "invalid digit 'x'"},
+		{text: "FFh", err: "must start with a digit"},
+	}
+
+	for _, tt := range testCases {
+
+		v, err := ParseNumber(tt.text)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("%s: %v", tt.text, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.text, err)
+		}
+		if v != tt.want {
+			t.Fatalf("%s: %d", tt.text, v)
+		}
+	}
+}
+
+func TestUndefinedSymbol(t *testing.T) {
+
+	if err := UndefinedSymbol("FFh"); !strings.Contains(err.Error(), "0FFh") {
+		t.Fatal(err)
+	}
+	if err := UndefinedSymbol("label"); err.Error() != "undeclared variable: label" {
+		t.Fatal(err)
+	}
+}
+
+func TestIsFloatLiteral(t *testing.T) {
+
+	testCases := []struct {
+		s    string
+		want bool
+	}{
+		{s: "1.5", want: true},
+		{s: "-0.25", want: true},
+		{s: "+1.", want: true},
+		{s: "1.e10", want: true},
+		{s: "3.0e-5", want: true},
+		{s: "1_000.5", want: true},
+		{s: "0x1.8p3", want: true},
+		{s: "1", want: false},
+		{s: "1e10", want: false},
+		{s: ".5", want: false},
+		{s: "1.5e", want: false},
+		{s: "0x1.8", want: false},
+		{s: "1.5+1", want: false},
+		{s: "label.x", want: false},
+	}
+
+	for _, tt := range testCases {
+		if IsFloatLiteral(tt.s) != tt.want {
+			t.Fatal(tt.s)
+		}
+	}
+}
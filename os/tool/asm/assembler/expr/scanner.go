@@ -0,0 +1,191 @@
+// Package expr : オペランドに書かれた式の処理
+package expr
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler/lexer"
+)
+
+// 式を構成するトークンの種類
+type Kind int
+
+const (
+	Number   Kind = iota // 数値リテラル
+	Char                 // 文字定数 'A' など
+	Ident                // シンボル名 ラベルや$など
+	Operator             // 演算子 括弧も含む
+)
+
+// 式を構成するトークン
+type Token struct {
+	Kind  Kind
+	Text  string // ソース上の表記
+	Value int64  // Number, Charの場合の値
+}
+
+// 式の文字列をトークン列に分割する
+//
+// @param s --- 式
+//
+// @return トークン列、エラー
+func Scan(s string) ([]Token, error) {
+
+	var result []Token
+	for i := 0; i < len(s); {
+
+		c := s[i]
+		switch {
+
+		case c == ' ':
+			i++
+
+		case c == '"' || c == '\'' || c == '`':
+			end := closingQuote(s, i)
+			if end < 0 {
+				return nil, fmt.Errorf("quotation isn't closed: %s", s[i:])
+			}
+			text := s[i : end+1]
+			v, err := charValue(text)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, Token{Kind: Char, Text: text, Value: v})
+			i = end + 1
+
+		case isDigit(c) || c == '$' && i+1 < len(s) && isDigit(s[i+1]):
+			end := i + 1
+			for end < len(s) && isIdentChar(s[end]) {
+				end++
+			}
+			text := s[i:end]
+			v, err := ParseNumber(text)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, Token{Kind: Number, Text: text, Value: v})
+			i = end
+
+		case isIdentStart(c):
+			end := i + 1
+			for end < len(s) && isIdentChar(s[end]) {
+				end++
+			}
+			result = append(result, Token{Kind: Ident, Text: s[i:end]})
+			i = end
+
+		default:
+			n := 1
+			for _, op := range []string{"<<", ">>", "//", "%%", "||", "^^", "&&", "==", "!=", "<>", "<=", ">="} {
+				if strings.HasPrefix(s[i:], op) {
+					n = 2
+					break
+				}
+			}
+			result = append(result, Token{Kind: Operator, Text: s[i : i+n]})
+			i += n
+		}
+	}
+
+	return result, nil
+}
+
+// 文字定数の値を求める
+// nasmと同様に、先頭の文字が最下位バイトとなるリトルエンディアンで解釈する
+//
+// @param text --- クォートを含む文字定数
+//
+// @return 値、エラー
+func charValue(text string) (int64, error) {
+
+	b, err := lexer.Unquote(lexer.Token(text))
+	if err != nil {
+		return 0, err
+	}
+	return packChars(b, text)
+}
+
+// 文字定数のバイト列を値にする
+//
+// @param b    --- 文字コードを変換したバイト列
+// @param text --- クォートを含む文字定数 エラーメッセージ用
+//
+// @return 値、エラー
+func packChars(b []byte, text string) (int64, error) {
+
+	if len(b) > 8 {
+		return 0, fmt.Errorf("character constant too long: %s", text)
+	}
+
+	var v uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	return int64(v), nil
+}
+
+// s[start]で開始したクォートを閉じるクォートの位置を返す
+// 閉じられていない場合は-1を返す
+func closingQuote(s string, start int) int {
+
+	quote := s[start]
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case quote:
+			return i
+		}
+	}
+	return -1
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// 文字列がシンボル名(ラベル名)として使用できるかどうか
+// "1+2" や "a-b" のような式はシンボル名ではない
+//
+// @param s --- 文字列
+func IsSymbol(s string) bool {
+	if s == "" || !isIdentStart(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isIdentChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// シンボル名の先頭に使用できる文字かどうか
+func isIdentStart(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_' || c == '.' || c == '?' || c == '@' || c == '$'
+}
+
+// シンボル名の2文字目以降に使用できる文字かどうか
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
+
+// 未定義のシンボルを参照した場合のエラーを作成する
+// 接尾辞付きの16進数を数字以外で書き始めたように見える場合はその旨をエラーメッセージに含める
+//
+// @param name --- シンボル名
+//
+// @return エラー
+func UndefinedSymbol(name string) error {
+
+	err := &UndefinedSymbolError{Name: name}
+
+	last := name[len(name)-1]
+	if last == 'h' || last == 'H' {
+		if _, e := ParseNumber("0" + name); e == nil {
+			err.hint = fmt.Sprintf("hexadecimal literals with the h suffix must start with a digit, e.g. 0%s", name)
+		}
+	}
+	return err
+}
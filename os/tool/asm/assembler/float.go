@@ -0,0 +1,99 @@
+package assembler
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// 浮動小数点数リテラルを指定したサイズのバイナリ表現に変換する
+// 4バイトならIEEE 754単精度、8バイトなら倍精度、10バイトならx87拡張倍精度とし、リトルエンディアンで出力する
+//
+// @param text --- 浮動小数点数リテラル
+// @param size --- 出力サイズ 4, 8, 10のいずれか
+//
+// @return バイト列、エラー
+func encodeFloat(text string, size int) ([]byte, error) {
+
+	s := strings.Replace(text, "_", "", -1)
+
+	switch size {
+
+	case 4:
+		f, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return nil, floatError(text, err)
+		}
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, math.Float32bits(float32(f)))
+		return b, nil
+
+	case 8:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, floatError(text, err)
+		}
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, math.Float64bits(f))
+		return b, nil
+
+	case 10:
+		return encodeFloat80(text, s)
+	}
+
+	return nil, fmt.Errorf("internal: unsupported float size %d", size)
+}
+
+// x87拡張倍精度(80bit)に変換する
+// 符号1bit、指数部15bit、整数部1bitを明示した仮数部64bitの形式
+// 倍精度を経由すると精度が落ちるため、リテラルから直接64bit精度で丸める
+//
+// @param text --- 元のリテラル エラーメッセージ用
+// @param s    --- アンダースコアを除去したリテラル
+//
+// @return バイト列、エラー
+func encodeFloat80(text string, s string) ([]byte, error) {
+
+	f, _, err := big.ParseFloat(s, 0, 64, big.ToNearestEven)
+	if err != nil {
+		return nil, floatError(text, err)
+	}
+
+	var (
+		b    = make([]byte, 10)
+		sign uint16
+	)
+	if f.Signbit() {
+		sign = 0x8000
+	}
+	if f.Sign() == 0 {
+		binary.LittleEndian.PutUint16(b[8:], sign)
+		return b, nil
+	}
+
+	// f = mant * 2^exp (0.5 <= |mant| < 1) なので、仮数部の最上位ビットが整数部になるように指数を調整する
+	mant := new(big.Float)
+	exp := f.MantExp(mant)
+	exp80 := exp - 1 + 16383
+	if exp80 <= 0 || exp80 >= 0x7FFF {
+		return nil, fmt.Errorf("浮動小数点数 %s は拡張倍精度で表現できる範囲を超えている", text)
+	}
+
+	mant.Abs(mant).SetMantExp(mant, 64)
+	m, _ := mant.Uint64()
+
+	binary.LittleEndian.PutUint64(b, m)
+	binary.LittleEndian.PutUint16(b[8:], sign|uint16(exp80))
+	return b, nil
+}
+
+func floatError(text string, err error) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return fmt.Errorf("浮動小数点数 %s は表現できる範囲を超えている", text)
+	}
+	return fmt.Errorf("不正な浮動小数点数 %s", text)
+}
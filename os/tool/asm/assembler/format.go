@@ -0,0 +1,362 @@
+package assembler
+
+import (
+	"bufio"
+	"fmt"
+	"go/token"
+	"io"
+)
+
+// フラットバイナリを変換して出力する形式
+type encoder interface {
+	io.Writer
+
+	// 残りのデータと終端を出力する
+	Close() error
+}
+
+// バイナリを指定した形式に変換して出力する
+//
+// @param e --- 変換器
+//
+// @return 変換前のバイト数、エラー
+func (a *assembly) writeEncoded(e encoder) (int64, error) {
+	n, err := a.writeBinary(e)
+	if err != nil {
+		return n, err
+	}
+	return n, e.Close()
+}
+
+// 出力形式に応じた変換器を作成する
+//
+// @param out  --- 出力先
+// @param size --- 変換前のバイト数
+//
+// @return 変換器、エラー
+func (a *assembly) encoder(out io.Writer, size int64) (encoder, error) {
+
+	switch a.config.format {
+	case FormatIntelHex:
+		return newIntelHexWriter(out, a.origin, size)
+	case FormatSRecord:
+		return newSRecordWriter(out, a.origin, size)
+	case FormatGo:
+		pkg, name := a.config.goPackage, a.config.goName
+		if pkg == "" {
+			pkg = "main"
+		}
+		if name == "" {
+			name = "image"
+		}
+		return newGoWriter(out, pkg, name, a.origin, size)
+	}
+	return nil, fmt.Errorf("unsupported output format: %v", a.config.format)
+}
+
+// 1レコードのデータのバイト数
+const recordSize = 16
+
+// レコード単位にまとめて出力する変換器の共通部分
+type recordWriter struct {
+	w        *bufio.Writer
+	address  int64              // 次のレコードの先頭アドレス
+	boundary int64              // レコードが跨いではならないアドレスの境界 0なら制限しない
+	buf      []byte             // レコードにまとめる前のデータ
+	record   func([]byte) error // 1レコードを出力する
+}
+
+// 次のレコードのバイト数
+func (r *recordWriter) room() int {
+	n := int64(recordSize)
+	if r.boundary > 0 {
+		if rest := r.boundary - r.address%r.boundary; rest < n {
+			n = rest
+		}
+	}
+	return int(n)
+}
+
+func (r *recordWriter) Write(p []byte) (int, error) {
+
+	n := len(p)
+	for len(p) > 0 {
+		room := r.room()
+		c := copy(r.buf[len(r.buf):room], p)
+		r.buf = r.buf[:len(r.buf)+c]
+		p = p[c:]
+		if len(r.buf) == room {
+			if err := r.flush(); err != nil {
+				return n - len(p), err
+			}
+		}
+	}
+	return n, nil
+}
+
+// まとめたデータをレコードとして出力する
+func (r *recordWriter) flush() error {
+	if len(r.buf) == 0 {
+		return nil
+	}
+	if err := r.record(r.buf); err != nil {
+		return err
+	}
+	r.address += int64(len(r.buf))
+	r.buf = r.buf[:0]
+	return nil
+}
+
+// Intel HEX形式
+//
+//	:LLAAAATT<データ>CC
+//
+// LLはデータのバイト数、AAAAはアドレスの下位16bit、TTはレコードの種類、CCはチェックサム
+// アドレスが64KBを超える場合は拡張リニアアドレスレコード(04)で上位16bitを指定する
+type intelHexWriter struct {
+	recordWriter
+	upper int64 // 最後に指定したアドレスの上位16bit
+}
+
+// Intel HEX形式の変換器を作成する
+//
+// @param out    --- 出力先
+// @param origin --- 先頭のアドレス
+// @param size   --- 変換前のバイト数
+//
+// @return 変換器、エラー
+func newIntelHexWriter(out io.Writer, origin, size int64) (*intelHexWriter, error) {
+
+	if origin+size > 1<<32 {
+		return nil, fmt.Errorf("Intel HEXで表現できるアドレスを超えている: 0x%X", origin+size)
+	}
+
+	h := &intelHexWriter{}
+	h.recordWriter = recordWriter{
+		w:        bufio.NewWriter(out),
+		address:  origin,
+		boundary: 0x10000,
+		buf:      make([]byte, 0, recordSize),
+		record:   h.data,
+	}
+	return h, nil
+}
+
+// データレコードを出力する
+func (h *intelHexWriter) data(b []byte) error {
+
+	if upper := h.address >> 16; upper != h.upper {
+		if err := h.write(0x04, 0, []byte{byte(upper >> 8), byte(upper)}); err != nil {
+			return err
+		}
+		h.upper = upper
+	}
+	return h.write(0x00, uint16(h.address), b)
+}
+
+// 1レコードを出力する
+//
+// @param typ     --- レコードの種類
+// @param address --- アドレスの下位16bit
+// @param b       --- データ
+func (h *intelHexWriter) write(typ byte, address uint16, b []byte) error {
+
+	sum := byte(len(b)) + byte(address>>8) + byte(address) + typ
+	for _, c := range b {
+		sum += c
+	}
+	_, err := fmt.Fprintf(h.w, ":%02X%04X%02X%X%02X\n", len(b), address, typ, b, -sum)
+	return err
+}
+
+func (h *intelHexWriter) Close() error {
+	if err := h.flush(); err != nil {
+		return err
+	}
+	if err := h.write(0x01, 0, nil); err != nil {
+		return err
+	}
+	return h.w.Flush()
+}
+
+// Motorola S-record形式
+//
+//	S<種類><LL><アドレス><データ><CC>
+//
+// LLはアドレス、データ、チェックサムのバイト数、CCはチェックサム
+// 最後のアドレスに応じてS1(16bit)、S2(24bit)、S3(32bit)のいずれかのデータレコードを使う
+// 終端にはレコード数(S5、S6)と、先頭アドレスを開始アドレスとする終了レコード(S9、S8、S7)を出力する
+type sRecordWriter struct {
+	recordWriter
+	origin  int64 // 先頭のアドレス
+	width   int   // アドレスのバイト数 2, 3, 4
+	records int   // 出力したデータレコードの数
+}
+
+// S-record形式の変換器を作成する
+//
+// @param out    --- 出力先
+// @param origin --- 先頭のアドレス
+// @param size   --- 変換前のバイト数
+//
+// @return 変換器、エラー
+func newSRecordWriter(out io.Writer, origin, size int64) (*sRecordWriter, error) {
+
+	s := &sRecordWriter{origin: origin}
+	switch end := origin + size; {
+	case end <= 1<<16:
+		s.width = 2
+	case end <= 1<<24:
+		s.width = 3
+	case end <= 1<<32:
+		s.width = 4
+	default:
+		return nil, fmt.Errorf("S-recordで表現できるアドレスを超えている: 0x%X", end)
+	}
+	s.recordWriter = recordWriter{
+		w:       bufio.NewWriter(out),
+		address: origin,
+		buf:     make([]byte, 0, recordSize),
+		record:  s.data,
+	}
+
+	// ヘッダー
+	if err := s.write('0', 2, 0, nil); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// データレコードを出力する
+func (s *sRecordWriter) data(b []byte) error {
+	s.records++
+	return s.write('0'+byte(s.width-1), s.width, s.address, b)
+}
+
+// 1レコードを出力する
+//
+// @param typ     --- レコードの種類
+// @param width   --- アドレスのバイト数
+// @param address --- アドレス
+// @param b       --- データ
+func (s *sRecordWriter) write(typ byte, width int, address int64, b []byte) error {
+
+	record := make([]byte, 0, 1+width+len(b))
+	record = append(record, byte(width+len(b)+1))
+	for i := width - 1; i >= 0; i-- {
+		record = append(record, byte(address>>(8*i)))
+	}
+	record = append(record, b...)
+
+	var sum byte
+	for _, c := range record {
+		sum += c
+	}
+	_, err := fmt.Fprintf(s.w, "S%c%X%02X\n", typ, record, ^sum)
+	return err
+}
+
+func (s *sRecordWriter) Close() error {
+
+	if err := s.flush(); err != nil {
+		return err
+	}
+	switch {
+	case s.records <= 0xFFFF:
+		if err := s.write('5', 2, int64(s.records), nil); err != nil {
+			return err
+		}
+	case s.records <= 0xFFFFFF:
+		if err := s.write('6', 3, int64(s.records), nil); err != nil {
+			return err
+		}
+	}
+	if err := s.write('0'+byte(11-s.width), s.width, s.origin, nil); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+// Goのソースコード形式
+// go:generateからバイナリを埋め込むために使う
+//
+//	// Code generated by asm; DO NOT EDIT.
+//
+//	package main
+//
+//	// ORG 0x7C00, 512 bytes
+//	var image = []byte{
+//		0xEB, 0x4E, 0x90, ...
+//	}
+type goWriter struct {
+	recordWriter
+	empty bool // 空のバイナリかどうか
+}
+
+// Goのソースコード形式の変換器を作成する
+//
+// @param out    --- 出力先
+// @param pkg    --- パッケージ名
+// @param name   --- 変数名
+// @param origin --- 先頭のアドレス
+// @param size   --- 変換前のバイト数
+//
+// @return 変換器、エラー
+func newGoWriter(out io.Writer, pkg, name string, origin, size int64) (*goWriter, error) {
+
+	if !token.IsIdentifier(pkg) || pkg == "_" {
+		return nil, fmt.Errorf("パッケージ名が不正: %q", pkg)
+	}
+	if !token.IsIdentifier(name) {
+		return nil, fmt.Errorf("変数名が不正: %q", name)
+	}
+
+	g := &goWriter{empty: size == 0}
+	g.recordWriter = recordWriter{
+		w:       bufio.NewWriter(out),
+		address: origin,
+		buf:     make([]byte, 0, recordSize),
+		record:  g.data,
+	}
+
+	open := "{\n"
+	if g.empty {
+		open = "{}\n"
+	}
+	_, err := fmt.Fprintf(g.w, "// Code generated by asm; DO NOT EDIT.\n\npackage %s\n\n// ORG 0x%X, %d bytes\nvar %s = []byte%s", pkg, origin, size, name, open)
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+const hexDigits = "0123456789ABCDEF"
+
+// 1行分のデータを出力する
+func (g *goWriter) data(b []byte) error {
+
+	line := make([]byte, 0, 1+len(b)*6)
+	line = append(line, '\t')
+	for i, c := range b {
+		if i > 0 {
+			line = append(line, ' ')
+		}
+		line = append(line, '0', 'x', hexDigits[c>>4], hexDigits[c&0xF], ',')
+	}
+	line = append(line, '\n')
+
+	_, err := g.w.Write(line)
+	return err
+}
+
+func (g *goWriter) Close() error {
+	if err := g.flush(); err != nil {
+		return err
+	}
+	if !g.empty {
+		if _, err := g.w.WriteString("}\n"); err != nil {
+			return err
+		}
+	}
+	return g.w.Flush()
+}
@@ -0,0 +1,307 @@
+package assembler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Intel HEXを検証しながらデコードする
+//
+// @return 先頭のアドレス、データ
+func decodeIntelHex(t *testing.T, text []byte) (int64, []byte) {
+
+	t.Helper()
+
+	var (
+		start   int64 = -1
+		data    []byte
+		upper   int64
+		end     bool
+		scanner = bufio.NewScanner(bytes.NewReader(text))
+	)
+	for line := 1; scanner.Scan(); line++ {
+		s := scanner.Text()
+		if end {
+			t.Fatalf("%d: record after EOF: %s", line, s)
+		}
+		if len(s) < 11 || s[0] != ':' {
+			t.Fatalf("%d: invalid record: %s", line, s)
+		}
+		b, err := hex.DecodeString(s[1:])
+		if err != nil {
+			t.Fatalf("%d: %v", line, err)
+		}
+		if int(b[0]) != len(b)-5 {
+			t.Fatalf("%d: length mismatch: %s", line, s)
+		}
+		var sum byte
+		for _, c := range b {
+			sum += c
+		}
+		if sum != 0 {
+			t.Fatalf("%d: checksum mismatch: %s", line, s)
+		}
+
+		address := int64(b[1])<<8 | int64(b[2])
+		payload := b[4 : len(b)-1]
+		switch b[3] {
+		case 0x00:
+			address |= upper << 16
+			if start < 0 {
+				start = address
+			}
+			if address != start+int64(len(data)) {
+				t.Fatalf("%d: address 0x%X, want 0x%X", line, address, start+int64(len(data)))
+			}
+			if address&0xFFFF+int64(len(payload)) > 0x10000 {
+				t.Fatalf("%d: record crosses 64KB boundary: %s", line, s)
+			}
+			data = append(data, payload...)
+		case 0x01:
+			end = true
+		case 0x04:
+			upper = int64(payload[0])<<8 | int64(payload[1])
+		default:
+			t.Fatalf("%d: unexpected record type: %s", line, s)
+		}
+	}
+	if !end {
+		t.Fatal("no EOF record")
+	}
+	return start, data
+}
+
+// S-recordを検証しながらデコードする
+//
+// @return 先頭のアドレス、データ
+func decodeSRecord(t *testing.T, text []byte) (int64, []byte) {
+
+	t.Helper()
+
+	var (
+		start   int64 = -1
+		data    []byte
+		records int
+		types   string
+		scanner = bufio.NewScanner(bytes.NewReader(text))
+	)
+	for line := 1; scanner.Scan(); line++ {
+		s := scanner.Text()
+		if len(s) < 4 || s[0] != 'S' {
+			t.Fatalf("%d: invalid record: %s", line, s)
+		}
+		b, err := hex.DecodeString(s[2:])
+		if err != nil {
+			t.Fatalf("%d: %v", line, err)
+		}
+		if int(b[0]) != len(b)-1 {
+			t.Fatalf("%d: length mismatch: %s", line, s)
+		}
+		var sum byte
+		for _, c := range b[:len(b)-1] {
+			sum += c
+		}
+		if ^sum != b[len(b)-1] {
+			t.Fatalf("%d: checksum mismatch: %s", line, s)
+		}
+
+		width := map[byte]int{'0': 2, '1': 2, '2': 3, '3': 4, '5': 2, '6': 3, '7': 4, '8': 3, '9': 2}[s[1]]
+		if width == 0 {
+			t.Fatalf("%d: unexpected record type: %s", line, s)
+		}
+		var address int64
+		for _, c := range b[1 : 1+width] {
+			address = address<<8 | int64(c)
+		}
+		payload := b[1+width : len(b)-1]
+		types += s[1:2]
+
+		switch s[1] {
+		case '1', '2', '3':
+			records++
+			if start < 0 {
+				start = address
+			}
+			if address != start+int64(len(data)) {
+				t.Fatalf("%d: address 0x%X, want 0x%X", line, address, start+int64(len(data)))
+			}
+			data = append(data, payload...)
+		case '5', '6':
+			if address != int64(records) {
+				t.Fatalf("%d: record count %d, want %d", line, address, records)
+			}
+		case '7', '8', '9':
+			if records > 0 && address != start {
+				t.Fatalf("%d: start address 0x%X, want 0x%X", line, address, start)
+			}
+		}
+	}
+
+	// ヘッダー、同じ種類のデータレコード、レコード数、対応する終了レコードの順
+	if records == 0 {
+		if types != "059" {
+			t.Fatalf("unexpected record types: %s", types)
+		}
+		return start, data
+	}
+	data1, data2 := types[1], types[len(types)-1]
+	if types[0] != '0' || strings.Trim(types[1:len(types)-2], string(data1)) != "" ||
+		!strings.ContainsRune("56", rune(types[len(types)-2])) || data1+data2 != '1'+'9' && data1+data2 != '2'+'8' && data1+data2 != '3'+'7' {
+		t.Fatalf("unexpected record types: %.8s...%s", types, types[len(types)-3:])
+	}
+	return start, data
+}
+
+// Goのソースコードを解析し、変数の値をデコードする
+//
+// @return データ
+func decodeGo(t *testing.T, src []byte, pkg, name string) []byte {
+
+	t.Helper()
+
+	if formatted, err := format.Source(src); err != nil || !bytes.Equal(formatted, src) {
+		t.Fatalf("not gofmt-ed: %v", err)
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), "image.go", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name.Name != pkg {
+		t.Fatal(f.Name.Name)
+	}
+	spec := f.Scope.Lookup(name)
+	if spec == nil || spec.Kind != ast.Var {
+		t.Fatal(name, "is not declared")
+	}
+	lit := spec.Decl.(*ast.ValueSpec).Values[0].(*ast.CompositeLit)
+	if typ, ok := lit.Type.(*ast.ArrayType); !ok || typ.Len != nil || typ.Elt.(*ast.Ident).Name != "byte" {
+		t.Fatal("not []byte")
+	}
+
+	var data []byte
+	for _, e := range lit.Elts {
+		v, err := strconv.ParseUint(e.(*ast.BasicLit).Value, 0, 8)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, byte(v))
+	}
+	return data
+}
+
+// 各形式でデコードした結果がフラットバイナリと一致し、ORGのアドレスに配置されること
+func TestAssembler_Format(t *testing.T) {
+
+	helloos, err := ioutil.ReadFile("testdata/helloos.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		src    string
+		origin int64
+	}{
+		{src: string(helloos), origin: 0x7c00},
+		{src: "ORG 0xFFF8\nDB 1,2,3,4,5,6,7,8,9,10,11,12,13,14,15,16,17,18,19,20", origin: 0xFFF8},
+		{src: "ORG 0xFFFFF0\nRESB 0x20\nDB 0xFF", origin: 0xFFFFF0},
+		{src: "DB 1", origin: 0},
+		{src: "", origin: 0},
+	}
+
+	for i, tt := range testCases {
+
+		bin := new(bytes.Buffer)
+		if _, err := New().Exec(strings.NewReader(tt.src), bin); err != nil {
+			t.Fatal(i, err)
+		}
+
+		ihex := new(bytes.Buffer)
+		if _, err := New(WithOutputFormat(FormatIntelHex)).Exec(strings.NewReader(tt.src), ihex); err != nil {
+			t.Fatal(i, err)
+		}
+		if origin, data := decodeIntelHex(t, ihex.Bytes()); bin.Len() > 0 && origin != tt.origin || !bytes.Equal(data, bin.Bytes()) {
+			t.Fatalf("%d: ihex 0x%X %d bytes", i, origin, len(data))
+		}
+
+		srec := new(bytes.Buffer)
+		if _, err := New(WithOutputFormat(FormatSRecord)).Exec(strings.NewReader(tt.src), srec); err != nil {
+			t.Fatal(i, err)
+		}
+		if origin, data := decodeSRecord(t, srec.Bytes()); bin.Len() > 0 && origin != tt.origin || !bytes.Equal(data, bin.Bytes()) {
+			t.Fatalf("%d: srec 0x%X %d bytes", i, origin, len(data))
+		}
+
+		if bin.Len() > 0x10000 {
+			continue
+		}
+		gosrc := new(bytes.Buffer)
+		if _, err := New(WithOutputFormat(FormatGo), WithGoSource("boot", "bootSector")).Exec(strings.NewReader(tt.src), gosrc); err != nil {
+			t.Fatal(i, err)
+		}
+		if data := decodeGo(t, gosrc.Bytes(), "boot", "bootSector"); !bytes.Equal(data, bin.Bytes()) {
+			t.Fatalf("%d: go % x", i, data)
+		}
+	}
+}
+
+// 形式毎のレコードの例
+func TestAssembler_FormatRecord(t *testing.T) {
+
+	testCases := []struct {
+		format Format
+		want   string
+	}{
+		{FormatIntelHex, ":02000004000FEB\n:03FFF00001020308\n:00000001FF\n"},
+		{FormatSRecord, "S0030000FC\nS2070FFFF0010203F4\nS5030001FB\nS8040FFFF0FD\n"},
+		{FormatGo, "// Code generated by asm; DO NOT EDIT.\n\npackage main\n\n// ORG 0xFFFF0, 3 bytes\nvar image = []byte{\n\t0x01, 0x02, 0x03,\n}\n"},
+	}
+
+	for _, tt := range testCases {
+		b := new(bytes.Buffer)
+		if _, err := New(WithOutputFormat(tt.format)).Exec(strings.NewReader("ORG 0xFFFF0\nDB 1,2,3"), b); err != nil {
+			t.Fatal(tt.format, err)
+		}
+		if b.String() != tt.want {
+			t.Fatalf("%v: %q", tt.format, b.String())
+		}
+	}
+}
+
+func TestAssembler_FormatError(t *testing.T) {
+
+	testCases := []struct {
+		opts []Option
+		src  string
+	}{
+		{[]Option{WithOutputFormat(FormatIntelHex)}, "ORG 0xFFFFFFFF\nDB 1,2"},
+		{[]Option{WithOutputFormat(FormatSRecord)}, "ORG 0xFFFFFFFF\nDB 1,2"},
+		{[]Option{WithOutputFormat(FormatGo), WithGoSource("main", "1image")}, "DB 1"},
+		{[]Option{WithOutputFormat(FormatGo), WithGoSource("_", "image")}, "DB 1"},
+		{[]Option{WithOutputFormat(Format(-1))}, "DB 1"},
+	}
+
+	for i, tt := range testCases {
+		if _, err := New(tt.opts...).Exec(strings.NewReader(tt.src), new(bytes.Buffer)); err == nil {
+			t.Fatal(i)
+		}
+	}
+
+	for _, name := range []string{"bin", "ihex", "srec", "go"} {
+		f, err := ParseFormat(name)
+		if err != nil || f.String() != name {
+			t.Fatal(name, f, err)
+		}
+	}
+	if _, err := ParseFormat("elf"); err == nil {
+		t.Fatal("elf")
+	}
+}
@@ -1,15 +1,20 @@
 package instruction
 
 import (
+	"fmt"
 	"io"
 
-	"go.nanasi880.dev/rpn"
+	"github.com/nanasi880/til/os/tool/asm/assembler/expr"
 )
 
 // DB命令
+// DW/DD命令などのデータ定義命令も、出力するバイト列が決まった状態でこの命令として表現される
 type DB struct {
-	b   []byte
-	rpn *rpn.RPN
+	b       []byte
+	expr    *expr.Expr                    // ラベル解決後に値が確定する場合の式
+	here    int64                         // 式を評価する際の$の値
+	base    int64                         // 式を評価する際の$$の値
+	convert func(v int64) ([]byte, error) // 式の評価結果をバイト列に変換する関数
 }
 
 func NewDB(b []byte) *DB {
@@ -18,11 +23,72 @@ func NewDB(b []byte) *DB {
 	}
 }
 
+// ラベル解決後に値が確定するデータを作成する
+// Relocate()が呼ばれるまでの間はsizeバイトのゼロ値として扱われる
+//
+// @param size    --- データのバイト数
+// @param e       --- 式
+// @param here    --- 式を評価する際の$の値
+// @param base    --- 式を評価する際の$$の値
+// @param convert --- 評価結果をsizeバイトのバイト列に変換する関数
+//
+// @return 命令
+func NewDBExpr(size int64, e *expr.Expr, here int64, base int64, convert func(v int64) ([]byte, error)) *DB {
+	return &DB{
+		b:       make([]byte, size),
+		expr:    e,
+		here:    here,
+		base:    base,
+		convert: convert,
+	}
+}
+
+// データの後ろにバイト列を追加する
+// 式を持つデータ(NewDBExprで作成したもの)には追加できない
+//
+// @param b --- 追加するバイト列
+func (o *DB) Append(b []byte) {
+	if o.expr != nil {
+		panic("instruction: Append to a relocatable DB")
+	}
+	o.b = append(o.b, b...)
+}
+
 func (o *DB) Size() int64 {
 	return int64(len(o.b))
 }
 
 func (o *DB) Relocate(table map[string]int64) error {
+
+	if o.expr == nil {
+		return nil
+	}
+
+	v, err := o.expr.Eval(func(name string) (int64, error) {
+		switch name {
+		case "$":
+			return o.here, nil
+		case "$$":
+			return o.base, nil
+		}
+		if v, ok := table[name]; ok {
+			return v, nil
+		}
+		return 0, expr.UndefinedSymbol(name)
+	})
+	if err != nil {
+		return err
+	}
+
+	b, err := o.convert(v)
+	if err != nil {
+		return err
+	}
+	if len(b) != len(o.b) {
+		return fmt.Errorf("internal: size mismatch %d != %d", len(b), len(o.b))
+	}
+	copy(o.b, b)
+
 	return nil
 }
 
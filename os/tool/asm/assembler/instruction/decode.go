@@ -0,0 +1,279 @@
+package instruction
+
+import (
+	"errors"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler/expr"
+)
+
+// 逆アセンブルした命令
+type Decoded struct {
+	Form     *Form     // 命令形式
+	Operands []Operand // オペランド 即値やディスプレースメントは数値の式になる
+	Length   int       // 命令のバイト数
+	Target   int64     // 相対アドレスのオペランドを持つ場合の飛び先のアドレス
+	Relative bool      // 相対アドレスのオペランドを持つかどうか
+}
+
+// デコードできないバイト列
+var ErrUndecodable = errors.New("undecodable instruction")
+
+// 先頭のオペコードバイトをキーとした命令形式の一覧
+var formsByOpcode = func() map[byte][]*Form {
+	m := make(map[byte][]*Form)
+	for i := range forms {
+		f := &forms[i]
+		if containsSlot(f.Slots, 'o') {
+			for r := byte(0); r < 8; r++ {
+				m[f.Opcode[0]+r] = append(m[f.Opcode[0]+r], f)
+			}
+			continue
+		}
+		m[f.Opcode[0]] = append(m[f.Opcode[0]], f)
+	}
+	return m
+}()
+
+// バイト列の先頭1命令をデコードする
+// 複数の命令形式に該当する場合は表で先に書かれているものが選ばれる
+//
+// @param b    --- バイト列
+// @param bits --- 16 or 32
+// @param here --- 命令の先頭アドレス 相対アドレスの飛び先の計算に使用する
+//
+// @return 命令、エラー デコードできない場合はErrUndecodable
+func Decode(b []byte, bits int, here int64) (*Decoded, error) {
+
+	// プレフィックス
+	var (
+		pos     int
+		segment Register
+		opSize  = bits
+	)
+prefix:
+	for pos < len(b) {
+		switch c := b[pos]; c {
+		case 0x26, 0x2E, 0x36, 0x3E, 0x64, 0x65:
+			if segment != NoRegister {
+				return nil, ErrUndecodable
+			}
+			for r, p := range segmentPrefixes {
+				if p == c {
+					segment = r
+				}
+			}
+		case 0x66:
+			if opSize != bits {
+				return nil, ErrUndecodable
+			}
+			opSize = 48 - bits
+		default:
+			break prefix
+		}
+		pos++
+	}
+	if pos >= len(b) {
+		return nil, ErrUndecodable
+	}
+
+	for _, f := range formsByOpcode[b[pos]] {
+		d, ok := decodeForm(f, b, pos, bits, opSize, segment, here)
+		if ok {
+			return d, nil
+		}
+	}
+	return nil, ErrUndecodable
+}
+
+// 指定した命令形式としてデコードする
+func decodeForm(f *Form, b []byte, pos int, bits int, opSize int, segment Register, here int64) (*Decoded, bool) {
+
+	prefixed := opSize != bits
+	if f.OpSize == 0 && prefixed || f.OpSize != 0 && f.OpSize != opSize {
+		return nil, false
+	}
+
+	// オペコード
+	opcodeReg := byte(0)
+	if pos+len(f.Opcode) > len(b) {
+		return nil, false
+	}
+	for i, c := range f.Opcode {
+		got := b[pos+i]
+		if i == len(f.Opcode)-1 && containsSlot(f.Slots, 'o') {
+			opcodeReg = got & 7
+			got &^= 7
+		}
+		if got != c {
+			return nil, false
+		}
+	}
+	pos += len(f.Opcode)
+
+	// ModR/M
+	var (
+		mod, reg, rm byte
+		modrm        = f.Digit >= 0 || containsSlot(f.Slots, 'r') || containsSlot(f.Slots, 'm')
+		mem          Memory
+	)
+	if modrm {
+		if pos >= len(b) {
+			return nil, false
+		}
+		c := b[pos]
+		pos++
+		mod, reg, rm = c>>6, c>>3&7, c&7
+		if f.Digit >= 0 && reg != byte(f.Digit) {
+			return nil, false
+		}
+		if mod != 3 {
+			var ok bool
+			mem, pos, ok = decodeMemory(b, pos, bits, mod, rm)
+			if !ok {
+				return nil, false
+			}
+			mem.Segment = segment
+		}
+	}
+	if segment != NoRegister && (!modrm || mod == 3) {
+		return nil, false
+	}
+
+	d := &Decoded{
+		Form:     f,
+		Operands: make([]Operand, len(f.Operands)),
+	}
+	for i, t := range f.Operands {
+		switch f.Slots[i] {
+
+		case 'r':
+			if t == SReg {
+				if reg > 5 {
+					return nil, false
+				}
+				d.Operands[i] = ES + Register(reg)
+			} else {
+				d.Operands[i] = generalRegister(t.Size(), reg)
+			}
+
+		case 'm':
+			if mod == 3 {
+				if t == Mem {
+					return nil, false
+				}
+				d.Operands[i] = generalRegister(t.Size(), rm)
+				continue
+			}
+			m := mem
+			if t != Mem && !f.hasRegisterOperand() {
+				m.Size = t.Size()
+			}
+			d.Operands[i] = m
+
+		case 'o':
+			d.Operands[i] = generalRegister(t.Size(), opcodeReg)
+
+		case 'i', 'j':
+			size := t.Size()
+			if pos+size > len(b) {
+				return nil, false
+			}
+			v := readLittleEndian(b[pos:pos+size], t == SImm8 || f.Slots[i] == 'j')
+			pos += size
+			if f.Slots[i] == 'j' {
+				d.Relative = true
+				d.Target = here + int64(pos) + v
+				d.Operands[i] = Immediate{Value: expr.FromInt(d.Target), Distance: distanceOf(t)}
+			} else {
+				d.Operands[i] = Immediate{Value: expr.FromInt(v)}
+			}
+
+		case '-':
+			if t == One {
+				d.Operands[i] = Immediate{Value: expr.FromInt(1)}
+			} else {
+				d.Operands[i] = fixedRegisters[t]
+			}
+		}
+	}
+	d.Length = pos
+
+	return d, true
+}
+
+// ModR/Mのmodとrmが表すメモリオペランドをデコードする
+func decodeMemory(b []byte, pos int, bits int, mod byte, rm byte) (Memory, int, bool) {
+
+	var (
+		m        Memory
+		dispSize int
+	)
+	if bits == 16 {
+		if mod == 0 && rm == 6 {
+			dispSize = 2
+		} else {
+			m.Base, m.Index = registers16[rm][0], registers16[rm][1]
+		}
+	} else {
+		if rm == 4 {
+			return m, pos, false
+		}
+		if mod == 0 && rm == 5 {
+			dispSize = 4
+		} else {
+			m.Base = EAX + Register(rm)
+		}
+	}
+	switch mod {
+	case 1:
+		dispSize = 1
+	case 2:
+		dispSize = bits / 8
+	}
+
+	if pos+dispSize > len(b) {
+		return m, pos, false
+	}
+	if dispSize > 0 {
+		v := readLittleEndian(b[pos:pos+dispSize], dispSize == 1)
+		m.Disp = expr.FromInt(v)
+	}
+	return m, pos + dispSize, true
+}
+
+// 16bitアドレッシングのModR/M.rmが表すレジスタ
+var registers16 = [8][2]Register{
+	{BX, SI}, {BX, DI}, {BP, SI}, {BP, DI},
+	{NoRegister, SI}, {NoRegister, DI}, {BP, NoRegister}, {BX, NoRegister},
+}
+
+// 相対アドレスのオペランド種別に対応する距離指定
+func distanceOf(t OperandType) Distance {
+	if t == Rel8 {
+		return DistanceShort
+	}
+	return DistanceNear
+}
+
+func containsSlot(slots string, c byte) bool {
+	for i := 0; i < len(slots); i++ {
+		if slots[i] == c {
+			return true
+		}
+	}
+	return false
+}
+
+// リトルエンディアンの整数を読み込む
+func readLittleEndian(b []byte, signed bool) int64 {
+
+	var v uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	if signed {
+		shift := uint(64 - 8*len(b))
+		return int64(v<<shift) >> shift
+	}
+	return int64(v)
+}
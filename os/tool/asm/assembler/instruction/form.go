@@ -0,0 +1,104 @@
+package instruction
+
+//go:generate go run ../../internal/x86gen -table x86_table.go x86.txt
+
+// 命令形式のオペランドの種類
+type OperandType uint8
+
+const (
+	Reg8   OperandType = iota + 1 // 8bit汎用レジスタ
+	Reg16                         // 16bit汎用レジスタ
+	Reg32                         // 32bit汎用レジスタ
+	RM8                           // 8bit汎用レジスタ or メモリ
+	RM16                          // 16bit汎用レジスタ or メモリ
+	RM32                          // 32bit汎用レジスタ or メモリ
+	Mem                           // サイズを問わないメモリ (LEA)
+	SReg                          // セグメントレジスタ
+	Imm8                          // 8bit即値
+	Imm16                         // 16bit即値
+	Imm32                         // 32bit即値
+	SImm8                         // 符号拡張される8bit即値
+	One                           // 定数1 (シフト命令)
+	Rel8                          // 8bit相対アドレス
+	Rel16                         // 16bit相対アドレス
+	Rel32                         // 32bit相対アドレス
+	RegAL                         // ALレジスタ固定
+	RegAX                         // AXレジスタ固定
+	RegEAX                        // EAXレジスタ固定
+	RegCL                         // CLレジスタ固定
+	RegDX                         // DXレジスタ固定
+	RegES                         // ESレジスタ固定
+	RegCS                         // CSレジスタ固定
+	RegSS                         // SSレジスタ固定
+	RegDS                         // DSレジスタ固定
+	RegFS                         // FSレジスタ固定
+	RegGS                         // GSレジスタ固定
+)
+
+// 命令が使用可能になったCPU
+type CPU int
+
+const (
+	CPU8086 CPU = 8086
+	CPU186  CPU = 186
+	CPU386  CPU = 386
+)
+
+// CPUの世代 値が大きいほど新しい
+func (c CPU) generation() int {
+	switch c {
+	case CPU8086:
+		return 1
+	case CPU186:
+		return 2
+	case CPU386:
+		return 3
+	}
+	return 0
+}
+
+// 命令形式
+// ニーモニックとオペランドの組み合わせ1つに対するエンコード方法を表す
+type Form struct {
+	Mnemonic string        // ニーモニック
+	Operands []OperandType // オペランドの種類
+	Slots    string        // 各オペランドのエンコード先 r:ModR/M.reg m:ModR/M.rm o:オペコード+r i:即値 j:相対アドレス -:暗黙
+	Opcode   []byte        // オペコード
+	Digit    int8          // ModR/M.regに埋め込む固定値 (/digit) 無ければ-1
+	OpSize   int           // オペランドサイズ 16 or 32 指定した場合はBITSと異なるときに0x66プレフィックスが付く 0ならサイズに依存しない
+	CPU      CPU           // 命令が使用可能になったCPU
+}
+
+// 固定レジスタのオペランド種別が表すレジスタ
+var fixedRegisters = map[OperandType]Register{
+	RegAL:  AL,
+	RegAX:  AX,
+	RegEAX: EAX,
+	RegCL:  CL,
+	RegDX:  DX,
+	RegES:  ES,
+	RegCS:  CS,
+	RegSS:  SS,
+	RegDS:  DS,
+	RegFS:  FS,
+	RegGS:  GS,
+}
+
+// オペランド種別のサイズ(バイト数) サイズを持たないものは0
+func (t OperandType) Size() int {
+	switch t {
+	case Reg8, RM8, Imm8, SImm8, Rel8, RegAL, RegCL:
+		return 1
+	case Reg16, RM16, SReg, Imm16, Rel16, RegAX, RegDX, RegES, RegCS, RegSS, RegDS, RegFS, RegGS:
+		return 2
+	case Reg32, RM32, Imm32, Rel32, RegEAX:
+		return 4
+	}
+	return 0
+}
+
+// ニーモニックが機械語命令として定義されているかどうか
+func IsX86Mnemonic(mnemonic string) bool {
+	_, ok := formsByMnemonic[mnemonic]
+	return ok
+}
@@ -0,0 +1,88 @@
+package instruction
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler/expr"
+)
+
+// 命令形式に当てはまるオペランドを作成する
+// 他の形式の方が短くならないように、レジスタ/メモリにはメモリを、即値には8bitに収まらない値を使う
+func sampleOperand(t OperandType, bits int) Operand {
+
+	memory := Memory{Size: t.Size(), Base: BX, Index: SI, Disp: expr.FromInt(0x12)}
+	if bits == 32 {
+		memory = Memory{Size: t.Size(), Base: EBX, Disp: expr.FromInt(0x12)}
+	}
+
+	switch t {
+	case Reg8:
+		return DL
+	case Reg16:
+		return DX
+	case Reg32:
+		return EDX
+	case RM8, RM16, RM32, Mem:
+		return memory
+	case SReg:
+		return DS
+	case Imm8:
+		return Immediate{Value: expr.FromInt(0x12)}
+	case Imm16:
+		return Immediate{Value: expr.FromInt(0x1234)}
+	case Imm32:
+		return Immediate{Value: expr.FromInt(0x12345678)}
+	case SImm8:
+		return Immediate{Value: expr.FromInt(-2)}
+	case One:
+		return Immediate{Value: expr.FromInt(1)}
+	case Rel8:
+		return Immediate{Value: expr.FromInt(0x7c10), Distance: DistanceShort}
+	case Rel16, Rel32:
+		return Immediate{Value: expr.FromInt(0x7d00), Distance: DistanceNear}
+	}
+	return fixedRegisters[t]
+}
+
+// x86.txtの全ての行がその形式でエンコードされ、デコードすると同じバイト列に戻ること
+// 各行はその行のCPUの指定で選べること
+func TestForms_RoundTrip(t *testing.T) {
+
+	for i := range forms {
+
+		f := &forms[i]
+		bits := 16
+		if f.OpSize == 32 {
+			bits = 32
+		}
+
+		operands := make([]Operand, len(f.Operands))
+		for j, typ := range f.Operands {
+			operands[j] = sampleOperand(typ, bits)
+		}
+
+		x, err := NewX86(f.Mnemonic, operands, bits, f.CPU, 0x7c00, 0x7c00, nil)
+		if err != nil {
+			t.Fatalf("%s %v: %v", f.Mnemonic, operands, err)
+		}
+		if x.Form() != f {
+			t.Fatalf("%s %v: selected %v", f.Mnemonic, operands, x.Form())
+		}
+
+		d, err := Decode(x.b, bits, 0x7c00)
+		if err != nil {
+			t.Fatalf("%s: % x: %v", x, x.b, err)
+		}
+		if d.Length != len(x.b) {
+			t.Fatalf("%s: % x: %d", x, x.b, d.Length)
+		}
+		y, err := NewX86(d.Form.Mnemonic, d.Operands, bits, CPU386, 0x7c00, 0x7c00, nil)
+		if err != nil {
+			t.Fatalf("%s: % x: %v", x, x.b, err)
+		}
+		if bytes.Compare(y.b, x.b) != 0 {
+			t.Fatalf("%s: % x, decoded %s: % x", x, x.b, y, y.b)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package instruction
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler/expr"
+)
+
+// 機械語命令のオペランド
+// Register, Memory, Immediateのいずれか
+type Operand interface {
+	String() string
+	operand()
+}
+
+func (Register) operand()  {}
+func (Memory) operand()    {}
+func (Immediate) operand() {}
+
+// メモリオペランド [ES:BX+SI+disp] など
+type Memory struct {
+	Size    int        // アクセスサイズ BYTE/WORD/DWORDで指定する 指定が無ければ0
+	Segment Register   // セグメントオーバーライド 無ければNoRegister
+	Base    Register   // ベースレジスタ 無ければNoRegister
+	Index   Register   // インデックスレジスタ 無ければNoRegister
+	Disp    *expr.Expr // ディスプレースメント 無ければnil
+}
+
+// ジャンプ命令の距離指定
+type Distance int
+
+const (
+	DistanceAuto  Distance = iota // 指定なし
+	DistanceShort                 // SHORT 8bitの相対アドレス
+	DistanceNear                  // NEAR 16bit/32bitの相対アドレス
+)
+
+// 即値オペランド
+// ジャンプ命令の場合は飛び先のアドレスを表す
+type Immediate struct {
+	Value    *expr.Expr
+	Distance Distance
+}
+
+// サイズ指定のキーワード
+var sizeKeywords = map[string]int{
+	"BYTE":  1,
+	"WORD":  2,
+	"DWORD": 4,
+}
+
+func (m Memory) String() string {
+
+	var b strings.Builder
+	for k, v := range sizeKeywords {
+		if v == m.Size {
+			b.WriteString(k)
+			b.WriteByte(' ')
+		}
+	}
+
+	b.WriteByte('[')
+	if m.Segment != NoRegister {
+		b.WriteString(m.Segment.String())
+		b.WriteByte(':')
+	}
+	regs := 0
+	for _, r := range []Register{m.Base, m.Index} {
+		if r == NoRegister {
+			continue
+		}
+		if regs > 0 {
+			b.WriteByte('+')
+		}
+		b.WriteString(r.String())
+		regs++
+	}
+	if m.Disp != nil {
+		disp := m.Disp.String()
+		if regs > 0 && !strings.HasPrefix(disp, "-") {
+			b.WriteByte('+')
+		}
+		b.WriteString(disp)
+	}
+	b.WriteByte(']')
+
+	return b.String()
+}
+
+func (i Immediate) String() string {
+	switch i.Distance {
+	case DistanceShort:
+		return "SHORT " + i.Value.String()
+	case DistanceNear:
+		return "NEAR " + i.Value.String()
+	}
+	return i.Value.String()
+}
+
+// オペランドの文字列を解釈する
+//
+//	AX               レジスタ
+//	[BX+SI+4]        メモリ
+//	BYTE [ES:DI]     サイズ指定付きメモリ
+//	0x7c00, label+1  即値
+//	SHORT label      距離指定付きの即値(ジャンプ先)
+//
+// @param s --- オペランドの文字列
+//
+// @return オペランド、エラー
+func ParseOperand(s string) (Operand, error) {
+
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, errors.New("empty operand")
+	}
+
+	if r, ok := LookupRegister(s); ok {
+		return r, nil
+	}
+
+	keyword, rest := splitKeyword(s)
+	switch keyword {
+
+	case "SHORT", "NEAR":
+		e, err := expr.Parse(rest)
+		if err != nil {
+			return nil, err
+		}
+		distance := DistanceShort
+		if keyword == "NEAR" {
+			distance = DistanceNear
+		}
+		return Immediate{Value: e, Distance: distance}, nil
+
+	case "BYTE", "WORD", "DWORD":
+		if !strings.HasPrefix(rest, "[") {
+			return nil, fmt.Errorf("%s must be followed by a memory operand: %s", keyword, s)
+		}
+		m, err := parseMemory(rest)
+		if err != nil {
+			return nil, err
+		}
+		m.Size = sizeKeywords[keyword]
+		return m, nil
+	}
+
+	if strings.HasPrefix(s, "[") {
+		return parseMemory(s)
+	}
+
+	e, err := expr.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return Immediate{Value: e}, nil
+}
+
+// 先頭の単語がキーワードであれば、キーワードと残りの部分に分割する
+func splitKeyword(s string) (string, string) {
+
+	for _, k := range []string{"SHORT", "NEAR", "BYTE", "WORD", "DWORD"} {
+		if !strings.HasPrefix(s, k) || len(s) == len(k) {
+			continue
+		}
+		// 字句解析で空白が除かれている場合があるので、識別子の一部でなければ区切りとみなす (NEAR-0x10, BYTE[SI])
+		if c := s[len(k)]; !isIdentByte(c) {
+			return k, strings.TrimSpace(s[len(k):])
+		}
+	}
+	return "", s
+}
+
+// 識別子を構成する文字かどうか
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '.' || c == '$' || c == '@' || c == '?' ||
+		'0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// [ ] で囲まれたメモリオペランドを解釈する
+func parseMemory(s string) (Memory, error) {
+
+	var m Memory
+	if !strings.HasSuffix(s, "]") {
+		return m, fmt.Errorf("memory operand isn't closed: %s", s)
+	}
+	inner := strings.TrimSpace(s[1 : len(s)-1])
+
+	// セグメントオーバーライド
+	if i := strings.IndexByte(inner, ':'); i >= 0 {
+		r, ok := LookupRegister(strings.TrimSpace(inner[:i]))
+		if !ok || !r.IsSegment() {
+			return m, fmt.Errorf("invalid segment override: %s", s)
+		}
+		m.Segment = r
+		inner = strings.TrimSpace(inner[i+1:])
+	}
+
+	// 括弧の外にある+/-で項に分割し、レジスタとそれ以外(ディスプレースメント)に振り分ける
+	var (
+		disp  strings.Builder
+		depth int
+		start int
+	)
+	term := func(end int) error {
+		t := strings.TrimSpace(inner[start:end])
+		sign := byte('+')
+		if start > 0 {
+			sign = inner[start-1]
+		}
+		if t == "" {
+			return fmt.Errorf("invalid memory operand: %s", s)
+		}
+		r, ok := LookupRegister(t)
+		if !ok {
+			if strings.Contains(t, "*") {
+				for _, name := range registerNames {
+					if name != "" && strings.Contains(t, name) {
+						return fmt.Errorf("scaled index isn't supported: %s", s)
+					}
+				}
+			}
+			if disp.Len() > 0 || sign == '-' {
+				disp.WriteByte(sign)
+			}
+			disp.WriteString(t)
+			return nil
+		}
+		if sign == '-' || !r.IsGeneral() || r.Size() == 1 {
+			return fmt.Errorf("invalid register in memory operand: %s", s)
+		}
+		switch {
+		case r == SI || r == DI:
+			if m.Index != NoRegister {
+				return fmt.Errorf("invalid memory operand: %s", s)
+			}
+			m.Index = r
+		case m.Base == NoRegister:
+			m.Base = r
+		case m.Index == NoRegister && r.Size() == 4:
+			m.Index = r
+		default:
+			return fmt.Errorf("invalid memory operand: %s", s)
+		}
+		return nil
+	}
+	for i := 0; i < len(inner); i++ {
+		switch c := inner[i]; c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '+', '-':
+			if depth == 0 && i > start {
+				if err := term(i); err != nil {
+					return m, err
+				}
+				start = i + 1
+			}
+		}
+	}
+	if err := term(len(inner)); err != nil {
+		return m, err
+	}
+
+	if disp.Len() > 0 {
+		e, err := expr.Parse(disp.String())
+		if err != nil {
+			return m, err
+		}
+		m.Disp = e
+	}
+	if m.Base == NoRegister && m.Index == NoRegister && m.Disp == nil {
+		return m, fmt.Errorf("invalid memory operand: %s", s)
+	}
+
+	return m, nil
+}
@@ -0,0 +1,128 @@
+package instruction
+
+// レジスタ
+type Register uint8
+
+// レジスタ一覧
+// 汎用レジスタはModR/Mで使用される番号順に並べている
+const (
+	NoRegister Register = iota
+
+	AL
+	CL
+	DL
+	BL
+	AH
+	CH
+	DH
+	BH
+
+	AX
+	CX
+	DX
+	BX
+	SP
+	BP
+	SI
+	DI
+
+	EAX
+	ECX
+	EDX
+	EBX
+	ESP
+	EBP
+	ESI
+	EDI
+
+	ES
+	CS
+	SS
+	DS
+	FS
+	GS
+)
+
+var registerNames = [...]string{
+	AL: "AL", CL: "CL", DL: "DL", BL: "BL", AH: "AH", CH: "CH", DH: "DH", BH: "BH",
+	AX: "AX", CX: "CX", DX: "DX", BX: "BX", SP: "SP", BP: "BP", SI: "SI", DI: "DI",
+	EAX: "EAX", ECX: "ECX", EDX: "EDX", EBX: "EBX", ESP: "ESP", EBP: "EBP", ESI: "ESI", EDI: "EDI",
+	ES: "ES", CS: "CS", SS: "SS", DS: "DS", FS: "FS", GS: "GS",
+}
+
+// レジスタ名からレジスタを探す
+//
+// @param name --- レジスタ名 大文字のみ
+//
+// @return レジスタ、見つかったかどうか
+func LookupRegister(name string) (Register, bool) {
+
+	for r, n := range registerNames {
+		if n != "" && n == name {
+			return Register(r), true
+		}
+	}
+	return NoRegister, false
+}
+
+func (r Register) String() string {
+	if int(r) < len(registerNames) {
+		return registerNames[r]
+	}
+	return "?"
+}
+
+// 汎用レジスタかどうか
+func (r Register) IsGeneral() bool {
+	return AL <= r && r <= EDI
+}
+
+// セグメントレジスタかどうか
+func (r Register) IsSegment() bool {
+	return ES <= r && r <= GS
+}
+
+// レジスタのバイト数 セグメントレジスタは2バイト
+func (r Register) Size() int {
+	switch {
+	case AL <= r && r <= BH:
+		return 1
+	case AX <= r && r <= DI, r.IsSegment():
+		return 2
+	case EAX <= r && r <= EDI:
+		return 4
+	}
+	return 0
+}
+
+// ModR/MのregフィールドやオペコードのBに埋め込むレジスタ番号
+func (r Register) Number() byte {
+	switch {
+	case AL <= r && r <= BH:
+		return byte(r - AL)
+	case AX <= r && r <= DI:
+		return byte(r - AX)
+	case EAX <= r && r <= EDI:
+		return byte(r - EAX)
+	case r.IsSegment():
+		return byte(r - ES)
+	}
+	return 0
+}
+
+// 指定したサイズとレジスタ番号の汎用レジスタを返す
+//
+// @param size --- バイト数 1, 2, 4のいずれか
+// @param n    --- レジスタ番号 0 ~ 7
+//
+// @return レジスタ
+func generalRegister(size int, n byte) Register {
+	switch size {
+	case 1:
+		return AL + Register(n&7)
+	case 2:
+		return AX + Register(n&7)
+	default:
+		return EAX + Register(n&7)
+	}
+}
@@ -0,0 +1,559 @@
+package instruction
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler/expr"
+)
+
+// 相対アドレスの飛び先が届かない
+// 飛び先が後方のラベルで、SHORTの形式が選ばれた場合にRelocate()で発生する
+var ErrJumpOutOfRange = errors.New("jump target out of range")
+
+// 機械語命令
+// 命令形式とディスプレースメントのサイズは作成時に確定し、Relocate()で値だけが埋められる
+type X86 struct {
+	form     *Form
+	operands []Operand
+	bits     int   // BITS命令で指定されたモード 16 or 32
+	here     int64 // 命令の先頭アドレス ($の値)
+	base     int64 // $$の値
+	dispSize int   // メモリオペランドのディスプレースメントのバイト数
+	b        []byte
+}
+
+// 機械語命令を作成する
+// オペランドに該当する命令形式の中から、エンコード後のサイズが最も小さいものを選ぶ
+// 後方で定義されるラベルを参照している場合は、値が決まっていなくても収まる形式が選ばれる
+// ただしジャンプ命令の距離指定が無い場合はSHORTの形式が選ばれるので、届かない場合はNEARを指定して作り直す必要がある
+//
+// @param mnemonic --- ニーモニック 大文字
+// @param operands --- オペランド
+// @param bits     --- 16 or 32
+// @param cpu      --- 使用できる命令のCPU これより新しいCPUの命令形式は選ばない
+// @param here     --- 命令の先頭アドレス
+// @param base     --- $$の値
+// @param resolve  --- 現時点で定義済みのシンボルを解決する関数 nilでもよい
+//
+// @return 命令、エラー
+func NewX86(mnemonic string, operands []Operand, bits int, cpu CPU, here int64, base int64, resolve expr.Resolver) (*X86, error) {
+
+	if bits != 16 && bits != 32 {
+		return nil, fmt.Errorf("invalid bits: %d", bits)
+	}
+
+	forms, ok := formsByMnemonic[mnemonic]
+	if !ok {
+		return nil, fmt.Errorf("unknown mnemonic `%s`", mnemonic)
+	}
+
+	var (
+		best     *X86
+		firstErr error
+		required CPU // オペランドには該当したが、CPUの指定によって選べなかった命令形式のCPU
+	)
+	for _, f := range forms {
+
+		ok, err := f.match(operands, bits, resolve)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if f.CPU.generation() > cpu.generation() {
+			if required == 0 || f.CPU.generation() < required.generation() {
+				required = f.CPU
+			}
+			continue
+		}
+
+		x := &X86{
+			form:     f,
+			operands: operands,
+			bits:     bits,
+			here:     here,
+			base:     base,
+			dispSize: -1,
+		}
+		b, err := x.encode(resolve, false)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if best == nil || len(b) < len(best.b) {
+			x.b = b
+			best = x
+		}
+	}
+
+	if best == nil {
+		if required != 0 {
+			return nil, fmt.Errorf("%s requires CPU %d: %s", mnemonic, required, formatInstruction(mnemonic, operands))
+		}
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return nil, fmt.Errorf("invalid combination of operands: %s", formatInstruction(mnemonic, operands))
+	}
+	return best, nil
+}
+
+// 命令形式
+func (o *X86) Form() *Form {
+	return o.form
+}
+
+func (o *X86) Size() int64 {
+	return int64(len(o.b))
+}
+
+func (o *X86) Relocate(table map[string]int64) error {
+
+	b, err := o.encode(tableResolver(table, o.here, o.base), true)
+	if err != nil {
+		return err
+	}
+	if len(b) != len(o.b) {
+		return fmt.Errorf("internal: size mismatch %d != %d", len(b), len(o.b))
+	}
+	copy(o.b, b)
+
+	return nil
+}
+
+func (o *X86) Write(w io.Writer) (int64, error) {
+	n, err := w.Write(o.b)
+	return int64(n), err
+}
+
+func (o *X86) String() string {
+	return formatInstruction(o.form.Mnemonic, o.operands)
+}
+
+// ラベルテーブルを使用するリゾルバを作成する
+func tableResolver(table map[string]int64, here int64, base int64) expr.Resolver {
+
+	return func(name string) (int64, error) {
+		switch name {
+		case "$":
+			return here, nil
+		case "$$":
+			return base, nil
+		}
+		if v, ok := table[name]; ok {
+			return v, nil
+		}
+		return 0, expr.UndefinedSymbol(name)
+	}
+}
+
+// ニーモニックとオペランドを1行のテキストにする
+func formatInstruction(mnemonic string, operands []Operand) string {
+
+	s := mnemonic
+	for i, op := range operands {
+		if i == 0 {
+			s += " "
+		} else {
+			s += ", "
+		}
+		s += op.String()
+	}
+	return s
+}
+
+// 式を評価する
+// 未定義のシンボルを参照している場合はエラーとせずknown=falseを返す
+//
+// @return 値、値が決まったかどうか、エラー
+func evalOperand(e *expr.Expr, resolve expr.Resolver) (int64, bool, error) {
+
+	if resolve == nil {
+		resolve = tableResolver(nil, 0, 0)
+	}
+	v, err := e.Eval(resolve)
+
+	var undefined *expr.UndefinedSymbolError
+	if errors.As(err, &undefined) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return v, true, nil
+}
+
+// sizeバイトの符号付き整数に収まるかどうか
+func fitsSigned(v int64, size int) bool {
+	bits := uint(size * 8)
+	return -1<<(bits-1) <= v && v < 1<<(bits-1)
+}
+
+// sizeバイトの符号付き・符号なし整数のいずれかに収まるかどうか
+func fitsEither(v int64, size int) bool {
+	bits := uint(size * 8)
+	return -1<<(bits-1) <= v && v < 1<<bits
+}
+
+// オペランドの並びがこの命令形式に該当するかどうか
+func (f *Form) match(operands []Operand, bits int, resolve expr.Resolver) (bool, error) {
+
+	if len(operands) != len(f.Operands) {
+		return false, nil
+	}
+
+	// オペランドのサイズがレジスタやメモリで決まらない形式は、BITSと同じオペランドサイズのものだけを使う
+	// BITS 32でPUSH 0x1234が16bitのPUSHになったりしないようにする
+	if f.OpSize != 0 && !f.hasSizedOperand() && f.OpSize != bits {
+		return false, nil
+	}
+
+	for i, t := range f.Operands {
+		ok, err := f.matchOperand(t, operands[i], resolve)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// オペランドサイズを決めるレジスタやメモリのオペランドを持つかどうか
+func (f *Form) hasSizedOperand() bool {
+	for _, t := range f.Operands {
+		switch t {
+		case Reg8, Reg16, Reg32, RM8, RM16, RM32, RegAL, RegAX, RegEAX:
+			return true
+		}
+	}
+	return false
+}
+
+// サイズ指定の無いメモリオペランドのサイズを、他のレジスタオペランドから決められるかどうか
+func (f *Form) hasRegisterOperand() bool {
+	for _, t := range f.Operands {
+		switch t {
+		case Reg8, Reg16, Reg32, SReg:
+			return true
+		}
+		if _, ok := fixedRegisters[t]; ok && t != RegCL && t != RegDX {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Form) matchOperand(t OperandType, op Operand, resolve expr.Resolver) (bool, error) {
+
+	if r, ok := fixedRegisters[t]; ok {
+		return op == r, nil
+	}
+
+	switch op := op.(type) {
+
+	case Register:
+		switch t {
+		case Reg8, Reg16, Reg32, RM8, RM16, RM32:
+			return op.IsGeneral() && op.Size() == t.Size(), nil
+		case SReg:
+			return op.IsSegment(), nil
+		}
+		return false, nil
+
+	case Memory:
+		switch t {
+		case RM8, RM16, RM32:
+			if op.Size == 0 {
+				return f.hasRegisterOperand(), nil
+			}
+			return op.Size == t.Size(), nil
+		case Mem:
+			return true, nil
+		}
+		return false, nil
+
+	case Immediate:
+		switch t {
+		case Imm8, Imm16, Imm32, SImm8, One:
+			if op.Distance != DistanceAuto {
+				return false, nil
+			}
+		case Rel8:
+			return op.Distance != DistanceNear, nil
+		case Rel16, Rel32:
+			return op.Distance != DistanceShort, nil
+		default:
+			return false, nil
+		}
+
+		v, known, err := evalOperand(op.Value, resolve)
+		if err != nil {
+			return false, err
+		}
+		switch t {
+		case SImm8:
+			// 符号拡張して元の値に戻るものだけ
+			if !known {
+				return false, nil
+			}
+			if fitsSigned(v, 1) {
+				return true, nil
+			}
+			size := f.OpSize / 8
+			return size < 8 && 0 <= v && v < 1<<uint(size*8) && v >= 1<<uint(size*8)-0x80, nil
+		case One:
+			return known && v == 1, nil
+		}
+		return !known || fitsEither(v, t.Size()), nil
+	}
+
+	return false, nil
+}
+
+// 命令をエンコードする
+//
+// @param resolve --- シンボルを解決する関数
+// @param final   --- trueならすべての値が決まっている必要がある
+//
+// @return 機械語、エラー
+func (o *X86) encode(resolve expr.Resolver, final bool) ([]byte, error) {
+
+	f := o.form
+	b := make([]byte, 0, 16)
+
+	var (
+		reg    byte
+		modrm  bool
+		mem    *Memory
+		rmReg  Register
+		imms   []int // 即値のオペランド番号
+		rel    = -1  // 相対アドレスのオペランド番号
+		opcode = append([]byte(nil), f.Opcode...)
+	)
+	if f.Digit >= 0 {
+		reg, modrm = byte(f.Digit), true
+	}
+	for i, op := range o.operands {
+		switch f.Slots[i] {
+		case 'r':
+			reg, modrm = op.(Register).Number(), true
+		case 'm':
+			modrm = true
+			switch op := op.(type) {
+			case Register:
+				rmReg = op
+			case Memory:
+				mem = &op
+			}
+		case 'o':
+			opcode[len(opcode)-1] += op.(Register).Number()
+		case 'i':
+			imms = append(imms, i)
+		case 'j':
+			rel = i
+		}
+	}
+
+	// プレフィックス
+	if mem != nil && mem.Segment != NoRegister {
+		b = append(b, segmentPrefixes[mem.Segment])
+	}
+	if f.OpSize != 0 && f.OpSize != o.bits {
+		b = append(b, 0x66)
+	}
+	b = append(b, opcode...)
+
+	// ModR/M
+	if modrm {
+		if mem == nil {
+			b = append(b, 0xC0|reg<<3|rmReg.Number())
+		} else {
+			var err error
+			b, err = o.encodeMemory(b, reg, mem, resolve, final)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// 即値
+	for _, i := range imms {
+		t := f.Operands[i]
+		v, known, err := evalOperand(o.operands[i].(Immediate).Value, resolve)
+		if err != nil {
+			return nil, err
+		}
+		if !known && final {
+			return nil, undefinedError(o.operands[i].(Immediate).Value, resolve)
+		}
+		size := t.Size()
+		if known && !fitsEither(v, size) && !(t == SImm8 && fitsEither(v, f.OpSize/8)) {
+			return nil, fmt.Errorf("immediate value out of range: %s", o.operands[i])
+		}
+		b = appendLittleEndian(b, v, size)
+	}
+
+	// 相対アドレス
+	if rel >= 0 {
+		t := f.Operands[rel]
+		size := t.Size()
+		target, known, err := evalOperand(o.operands[rel].(Immediate).Value, resolve)
+		if err != nil {
+			return nil, err
+		}
+		if !known && final {
+			return nil, undefinedError(o.operands[rel].(Immediate).Value, resolve)
+		}
+		v := int64(0)
+		if known {
+			v = target - (o.here + int64(len(b)+size))
+			if !fitsSigned(v, size) {
+				return nil, fmt.Errorf("%w: %s (%d)", ErrJumpOutOfRange, o.operands[rel], v)
+			}
+		}
+		b = appendLittleEndian(b, v, size)
+	}
+
+	return b, nil
+}
+
+// ModR/Mとディスプレースメントをエンコードする
+func (o *X86) encodeMemory(b []byte, reg byte, m *Memory, resolve expr.Resolver, final bool) ([]byte, error) {
+
+	addrSize := o.bits
+	for _, r := range []Register{m.Base, m.Index} {
+		if r != NoRegister {
+			addrSize = r.Size() * 8
+		}
+	}
+	if addrSize != o.bits {
+		return nil, fmt.Errorf("address size override isn't supported: %s", m)
+	}
+
+	var (
+		disp  int64
+		known = true
+	)
+	if m.Disp != nil {
+		var err error
+		disp, known, err = evalOperand(m.Disp, resolve)
+		if err != nil {
+			return nil, err
+		}
+		if !known && final {
+			return nil, undefinedError(m.Disp, resolve)
+		}
+	}
+
+	var (
+		rm     byte
+		direct bool // ディスプレースメントのみ
+	)
+	if addrSize == 16 {
+		n, ok := modRM16[[2]Register{m.Base, m.Index}]
+		if !ok {
+			n, ok = modRM16[[2]Register{m.Index, m.Base}]
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid memory operand: %s", m)
+		}
+		rm = n
+		direct = m.Base == NoRegister && m.Index == NoRegister
+	} else {
+		if m.Index != NoRegister || m.Base == ESP {
+			return nil, fmt.Errorf("SIB addressing isn't supported: %s", m)
+		}
+		if m.Base == NoRegister {
+			rm, direct = 5, true
+		} else {
+			rm = m.Base.Number()
+		}
+	}
+
+	// ディスプレースメントのサイズは最初のエンコード時に決めて、以降は変えない
+	size := o.dispSize
+	if size < 0 {
+		switch {
+		case direct:
+			size = addrSize / 8
+		case known && disp == 0 && !(m.Index == NoRegister && (m.Base == BP || m.Base == EBP)):
+			size = 0
+		case known && fitsSigned(disp, 1):
+			size = 1
+		default:
+			size = addrSize / 8
+		}
+		o.dispSize = size
+	}
+	if known && !fitsDisplacement(disp, size) {
+		return nil, fmt.Errorf("displacement out of range: %s", m)
+	}
+
+	var mod byte
+	switch {
+	case direct:
+		mod = 0
+	case size == 1:
+		mod = 1
+	case size > 1:
+		mod = 2
+	}
+	b = append(b, mod<<6|reg<<3|rm)
+	return appendLittleEndian(b, disp, size), nil
+}
+
+// ディスプレースメントが指定したバイト数に収まるかどうか
+// 8bitのディスプレースメントは符号拡張されるので符号付きの範囲に収まる必要がある
+func fitsDisplacement(v int64, size int) bool {
+	switch size {
+	case 0:
+		return v == 0
+	case 1:
+		return fitsSigned(v, 1)
+	}
+	return fitsEither(v, size)
+}
+
+// 16bitアドレッシングのModR/M.rm ディスプレースメントのみの場合は6
+var modRM16 = map[[2]Register]byte{
+	{BX, SI}:                 0,
+	{BX, DI}:                 1,
+	{BP, SI}:                 2,
+	{BP, DI}:                 3,
+	{NoRegister, SI}:         4,
+	{NoRegister, DI}:         5,
+	{BP, NoRegister}:         6,
+	{BX, NoRegister}:         7,
+	{NoRegister, NoRegister}: 6,
+}
+
+// セグメントオーバーライドプレフィックス
+var segmentPrefixes = map[Register]byte{
+	ES: 0x26,
+	CS: 0x2E,
+	SS: 0x36,
+	DS: 0x3E,
+	FS: 0x64,
+	GS: 0x65,
+}
+
+// 式を評価した際のエラーを返す
+func undefinedError(e *expr.Expr, resolve expr.Resolver) error {
+	if resolve == nil {
+		resolve = tableResolver(nil, 0, 0)
+	}
+	_, err := e.Eval(resolve)
+	return err
+}
+
+// 値をsizeバイトのリトルエンディアンで追加する
+func appendLittleEndian(b []byte, v int64, size int) []byte {
+	for i := 0; i < size; i++ {
+		b = append(b, byte(v>>uint(8*i)))
+	}
+	return b
+}
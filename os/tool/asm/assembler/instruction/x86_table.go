@@ -0,0 +1,533 @@
+// Code generated by x86gen from x86.txt; DO NOT EDIT.
+
+package instruction
+
+// 命令形式の一覧 並び順の意味はx86.txtを参照
+var forms = []Form{
+	{"ADD", []OperandType{RM8, Reg8}, "mr", []byte{0x00}, -1, 0, CPU8086},      // x86.txt:19
+	{"ADD", []OperandType{RM16, Reg16}, "mr", []byte{0x01}, -1, 16, CPU8086},   // x86.txt:20
+	{"ADD", []OperandType{RM32, Reg32}, "mr", []byte{0x01}, -1, 32, CPU386},    // x86.txt:21
+	{"ADD", []OperandType{Reg8, RM8}, "rm", []byte{0x02}, -1, 0, CPU8086},      // x86.txt:22
+	{"ADD", []OperandType{Reg16, RM16}, "rm", []byte{0x03}, -1, 16, CPU8086},   // x86.txt:23
+	{"ADD", []OperandType{Reg32, RM32}, "rm", []byte{0x03}, -1, 32, CPU386},    // x86.txt:24
+	{"ADD", []OperandType{RegAL, Imm8}, "-i", []byte{0x04}, -1, 0, CPU8086},    // x86.txt:25
+	{"ADD", []OperandType{RM8, Imm8}, "mi", []byte{0x80}, 0, 0, CPU8086},       // x86.txt:26
+	{"ADD", []OperandType{RM16, SImm8}, "mi", []byte{0x83}, 0, 16, CPU8086},    // x86.txt:27
+	{"ADD", []OperandType{RM32, SImm8}, "mi", []byte{0x83}, 0, 32, CPU386},     // x86.txt:28
+	{"ADD", []OperandType{RegAX, Imm16}, "-i", []byte{0x05}, -1, 16, CPU8086},  // x86.txt:29
+	{"ADD", []OperandType{RegEAX, Imm32}, "-i", []byte{0x05}, -1, 32, CPU386},  // x86.txt:30
+	{"ADD", []OperandType{RM16, Imm16}, "mi", []byte{0x81}, 0, 16, CPU8086},    // x86.txt:31
+	{"ADD", []OperandType{RM32, Imm32}, "mi", []byte{0x81}, 0, 32, CPU386},     // x86.txt:32
+	{"OR", []OperandType{RM8, Reg8}, "mr", []byte{0x08}, -1, 0, CPU8086},       // x86.txt:34
+	{"OR", []OperandType{RM16, Reg16}, "mr", []byte{0x09}, -1, 16, CPU8086},    // x86.txt:35
+	{"OR", []OperandType{RM32, Reg32}, "mr", []byte{0x09}, -1, 32, CPU386},     // x86.txt:36
+	{"OR", []OperandType{Reg8, RM8}, "rm", []byte{0x0A}, -1, 0, CPU8086},       // x86.txt:37
+	{"OR", []OperandType{Reg16, RM16}, "rm", []byte{0x0B}, -1, 16, CPU8086},    // x86.txt:38
+	{"OR", []OperandType{Reg32, RM32}, "rm", []byte{0x0B}, -1, 32, CPU386},     // x86.txt:39
+	{"OR", []OperandType{RegAL, Imm8}, "-i", []byte{0x0C}, -1, 0, CPU8086},     // x86.txt:40
+	{"OR", []OperandType{RM8, Imm8}, "mi", []byte{0x80}, 1, 0, CPU8086},        // x86.txt:41
+	{"OR", []OperandType{RM16, SImm8}, "mi", []byte{0x83}, 1, 16, CPU8086},     // x86.txt:42
+	{"OR", []OperandType{RM32, SImm8}, "mi", []byte{0x83}, 1, 32, CPU386},      // x86.txt:43
+	{"OR", []OperandType{RegAX, Imm16}, "-i", []byte{0x0D}, -1, 16, CPU8086},   // x86.txt:44
+	{"OR", []OperandType{RegEAX, Imm32}, "-i", []byte{0x0D}, -1, 32, CPU386},   // x86.txt:45
+	{"OR", []OperandType{RM16, Imm16}, "mi", []byte{0x81}, 1, 16, CPU8086},     // x86.txt:46
+	{"OR", []OperandType{RM32, Imm32}, "mi", []byte{0x81}, 1, 32, CPU386},      // x86.txt:47
+	{"ADC", []OperandType{RM8, Reg8}, "mr", []byte{0x10}, -1, 0, CPU8086},      // x86.txt:49
+	{"ADC", []OperandType{RM16, Reg16}, "mr", []byte{0x11}, -1, 16, CPU8086},   // x86.txt:50
+	{"ADC", []OperandType{RM32, Reg32}, "mr", []byte{0x11}, -1, 32, CPU386},    // x86.txt:51
+	{"ADC", []OperandType{Reg8, RM8}, "rm", []byte{0x12}, -1, 0, CPU8086},      // x86.txt:52
+	{"ADC", []OperandType{Reg16, RM16}, "rm", []byte{0x13}, -1, 16, CPU8086},   // x86.txt:53
+	{"ADC", []OperandType{Reg32, RM32}, "rm", []byte{0x13}, -1, 32, CPU386},    // x86.txt:54
+	{"ADC", []OperandType{RegAL, Imm8}, "-i", []byte{0x14}, -1, 0, CPU8086},    // x86.txt:55
+	{"ADC", []OperandType{RM8, Imm8}, "mi", []byte{0x80}, 2, 0, CPU8086},       // x86.txt:56
+	{"ADC", []OperandType{RM16, SImm8}, "mi", []byte{0x83}, 2, 16, CPU8086},    // x86.txt:57
+	{"ADC", []OperandType{RM32, SImm8}, "mi", []byte{0x83}, 2, 32, CPU386},     // x86.txt:58
+	{"ADC", []OperandType{RegAX, Imm16}, "-i", []byte{0x15}, -1, 16, CPU8086},  // x86.txt:59
+	{"ADC", []OperandType{RegEAX, Imm32}, "-i", []byte{0x15}, -1, 32, CPU386},  // x86.txt:60
+	{"ADC", []OperandType{RM16, Imm16}, "mi", []byte{0x81}, 2, 16, CPU8086},    // x86.txt:61
+	{"ADC", []OperandType{RM32, Imm32}, "mi", []byte{0x81}, 2, 32, CPU386},     // x86.txt:62
+	{"SBB", []OperandType{RM8, Reg8}, "mr", []byte{0x18}, -1, 0, CPU8086},      // x86.txt:64
+	{"SBB", []OperandType{RM16, Reg16}, "mr", []byte{0x19}, -1, 16, CPU8086},   // x86.txt:65
+	{"SBB", []OperandType{RM32, Reg32}, "mr", []byte{0x19}, -1, 32, CPU386},    // x86.txt:66
+	{"SBB", []OperandType{Reg8, RM8}, "rm", []byte{0x1A}, -1, 0, CPU8086},      // x86.txt:67
+	{"SBB", []OperandType{Reg16, RM16}, "rm", []byte{0x1B}, -1, 16, CPU8086},   // x86.txt:68
+	{"SBB", []OperandType{Reg32, RM32}, "rm", []byte{0x1B}, -1, 32, CPU386},    // x86.txt:69
+	{"SBB", []OperandType{RegAL, Imm8}, "-i", []byte{0x1C}, -1, 0, CPU8086},    // x86.txt:70
+	{"SBB", []OperandType{RM8, Imm8}, "mi", []byte{0x80}, 3, 0, CPU8086},       // x86.txt:71
+	{"SBB", []OperandType{RM16, SImm8}, "mi", []byte{0x83}, 3, 16, CPU8086},    // x86.txt:72
+	{"SBB", []OperandType{RM32, SImm8}, "mi", []byte{0x83}, 3, 32, CPU386},     // x86.txt:73
+	{"SBB", []OperandType{RegAX, Imm16}, "-i", []byte{0x1D}, -1, 16, CPU8086},  // x86.txt:74
+	{"SBB", []OperandType{RegEAX, Imm32}, "-i", []byte{0x1D}, -1, 32, CPU386},  // x86.txt:75
+	{"SBB", []OperandType{RM16, Imm16}, "mi", []byte{0x81}, 3, 16, CPU8086},    // x86.txt:76
+	{"SBB", []OperandType{RM32, Imm32}, "mi", []byte{0x81}, 3, 32, CPU386},     // x86.txt:77
+	{"AND", []OperandType{RM8, Reg8}, "mr", []byte{0x20}, -1, 0, CPU8086},      // x86.txt:79
+	{"AND", []OperandType{RM16, Reg16}, "mr", []byte{0x21}, -1, 16, CPU8086},   // x86.txt:80
+	{"AND", []OperandType{RM32, Reg32}, "mr", []byte{0x21}, -1, 32, CPU386},    // x86.txt:81
+	{"AND", []OperandType{Reg8, RM8}, "rm", []byte{0x22}, -1, 0, CPU8086},      // x86.txt:82
+	{"AND", []OperandType{Reg16, RM16}, "rm", []byte{0x23}, -1, 16, CPU8086},   // x86.txt:83
+	{"AND", []OperandType{Reg32, RM32}, "rm", []byte{0x23}, -1, 32, CPU386},    // x86.txt:84
+	{"AND", []OperandType{RegAL, Imm8}, "-i", []byte{0x24}, -1, 0, CPU8086},    // x86.txt:85
+	{"AND", []OperandType{RM8, Imm8}, "mi", []byte{0x80}, 4, 0, CPU8086},       // x86.txt:86
+	{"AND", []OperandType{RM16, SImm8}, "mi", []byte{0x83}, 4, 16, CPU8086},    // x86.txt:87
+	{"AND", []OperandType{RM32, SImm8}, "mi", []byte{0x83}, 4, 32, CPU386},     // x86.txt:88
+	{"AND", []OperandType{RegAX, Imm16}, "-i", []byte{0x25}, -1, 16, CPU8086},  // x86.txt:89
+	{"AND", []OperandType{RegEAX, Imm32}, "-i", []byte{0x25}, -1, 32, CPU386},  // x86.txt:90
+	{"AND", []OperandType{RM16, Imm16}, "mi", []byte{0x81}, 4, 16, CPU8086},    // x86.txt:91
+	{"AND", []OperandType{RM32, Imm32}, "mi", []byte{0x81}, 4, 32, CPU386},     // x86.txt:92
+	{"SUB", []OperandType{RM8, Reg8}, "mr", []byte{0x28}, -1, 0, CPU8086},      // x86.txt:94
+	{"SUB", []OperandType{RM16, Reg16}, "mr", []byte{0x29}, -1, 16, CPU8086},   // x86.txt:95
+	{"SUB", []OperandType{RM32, Reg32}, "mr", []byte{0x29}, -1, 32, CPU386},    // x86.txt:96
+	{"SUB", []OperandType{Reg8, RM8}, "rm", []byte{0x2A}, -1, 0, CPU8086},      // x86.txt:97
+	{"SUB", []OperandType{Reg16, RM16}, "rm", []byte{0x2B}, -1, 16, CPU8086},   // x86.txt:98
+	{"SUB", []OperandType{Reg32, RM32}, "rm", []byte{0x2B}, -1, 32, CPU386},    // x86.txt:99
+	{"SUB", []OperandType{RegAL, Imm8}, "-i", []byte{0x2C}, -1, 0, CPU8086},    // x86.txt:100
+	{"SUB", []OperandType{RM8, Imm8}, "mi", []byte{0x80}, 5, 0, CPU8086},       // x86.txt:101
+	{"SUB", []OperandType{RM16, SImm8}, "mi", []byte{0x83}, 5, 16, CPU8086},    // x86.txt:102
+	{"SUB", []OperandType{RM32, SImm8}, "mi", []byte{0x83}, 5, 32, CPU386},     // x86.txt:103
+	{"SUB", []OperandType{RegAX, Imm16}, "-i", []byte{0x2D}, -1, 16, CPU8086},  // x86.txt:104
+	{"SUB", []OperandType{RegEAX, Imm32}, "-i", []byte{0x2D}, -1, 32, CPU386},  // x86.txt:105
+	{"SUB", []OperandType{RM16, Imm16}, "mi", []byte{0x81}, 5, 16, CPU8086},    // x86.txt:106
+	{"SUB", []OperandType{RM32, Imm32}, "mi", []byte{0x81}, 5, 32, CPU386},     // x86.txt:107
+	{"XOR", []OperandType{RM8, Reg8}, "mr", []byte{0x30}, -1, 0, CPU8086},      // x86.txt:109
+	{"XOR", []OperandType{RM16, Reg16}, "mr", []byte{0x31}, -1, 16, CPU8086},   // x86.txt:110
+	{"XOR", []OperandType{RM32, Reg32}, "mr", []byte{0x31}, -1, 32, CPU386},    // x86.txt:111
+	{"XOR", []OperandType{Reg8, RM8}, "rm", []byte{0x32}, -1, 0, CPU8086},      // x86.txt:112
+	{"XOR", []OperandType{Reg16, RM16}, "rm", []byte{0x33}, -1, 16, CPU8086},   // x86.txt:113
+	{"XOR", []OperandType{Reg32, RM32}, "rm", []byte{0x33}, -1, 32, CPU386},    // x86.txt:114
+	{"XOR", []OperandType{RegAL, Imm8}, "-i", []byte{0x34}, -1, 0, CPU8086},    // x86.txt:115
+	{"XOR", []OperandType{RM8, Imm8}, "mi", []byte{0x80}, 6, 0, CPU8086},       // x86.txt:116
+	{"XOR", []OperandType{RM16, SImm8}, "mi", []byte{0x83}, 6, 16, CPU8086},    // x86.txt:117
+	{"XOR", []OperandType{RM32, SImm8}, "mi", []byte{0x83}, 6, 32, CPU386},     // x86.txt:118
+	{"XOR", []OperandType{RegAX, Imm16}, "-i", []byte{0x35}, -1, 16, CPU8086},  // x86.txt:119
+	{"XOR", []OperandType{RegEAX, Imm32}, "-i", []byte{0x35}, -1, 32, CPU386},  // x86.txt:120
+	{"XOR", []OperandType{RM16, Imm16}, "mi", []byte{0x81}, 6, 16, CPU8086},    // x86.txt:121
+	{"XOR", []OperandType{RM32, Imm32}, "mi", []byte{0x81}, 6, 32, CPU386},     // x86.txt:122
+	{"CMP", []OperandType{RM8, Reg8}, "mr", []byte{0x38}, -1, 0, CPU8086},      // x86.txt:124
+	{"CMP", []OperandType{RM16, Reg16}, "mr", []byte{0x39}, -1, 16, CPU8086},   // x86.txt:125
+	{"CMP", []OperandType{RM32, Reg32}, "mr", []byte{0x39}, -1, 32, CPU386},    // x86.txt:126
+	{"CMP", []OperandType{Reg8, RM8}, "rm", []byte{0x3A}, -1, 0, CPU8086},      // x86.txt:127
+	{"CMP", []OperandType{Reg16, RM16}, "rm", []byte{0x3B}, -1, 16, CPU8086},   // x86.txt:128
+	{"CMP", []OperandType{Reg32, RM32}, "rm", []byte{0x3B}, -1, 32, CPU386},    // x86.txt:129
+	{"CMP", []OperandType{RegAL, Imm8}, "-i", []byte{0x3C}, -1, 0, CPU8086},    // x86.txt:130
+	{"CMP", []OperandType{RM8, Imm8}, "mi", []byte{0x80}, 7, 0, CPU8086},       // x86.txt:131
+	{"CMP", []OperandType{RM16, SImm8}, "mi", []byte{0x83}, 7, 16, CPU8086},    // x86.txt:132
+	{"CMP", []OperandType{RM32, SImm8}, "mi", []byte{0x83}, 7, 32, CPU386},     // x86.txt:133
+	{"CMP", []OperandType{RegAX, Imm16}, "-i", []byte{0x3D}, -1, 16, CPU8086},  // x86.txt:134
+	{"CMP", []OperandType{RegEAX, Imm32}, "-i", []byte{0x3D}, -1, 32, CPU386},  // x86.txt:135
+	{"CMP", []OperandType{RM16, Imm16}, "mi", []byte{0x81}, 7, 16, CPU8086},    // x86.txt:136
+	{"CMP", []OperandType{RM32, Imm32}, "mi", []byte{0x81}, 7, 32, CPU386},     // x86.txt:137
+	{"MOV", []OperandType{RM8, Reg8}, "mr", []byte{0x88}, -1, 0, CPU8086},      // x86.txt:139
+	{"MOV", []OperandType{RM16, Reg16}, "mr", []byte{0x89}, -1, 16, CPU8086},   // x86.txt:140
+	{"MOV", []OperandType{RM32, Reg32}, "mr", []byte{0x89}, -1, 32, CPU386},    // x86.txt:141
+	{"MOV", []OperandType{Reg8, RM8}, "rm", []byte{0x8A}, -1, 0, CPU8086},      // x86.txt:142
+	{"MOV", []OperandType{Reg16, RM16}, "rm", []byte{0x8B}, -1, 16, CPU8086},   // x86.txt:143
+	{"MOV", []OperandType{Reg32, RM32}, "rm", []byte{0x8B}, -1, 32, CPU386},    // x86.txt:144
+	{"MOV", []OperandType{RM16, SReg}, "mr", []byte{0x8C}, -1, 0, CPU8086},     // x86.txt:145
+	{"MOV", []OperandType{SReg, RM16}, "rm", []byte{0x8E}, -1, 0, CPU8086},     // x86.txt:146
+	{"MOV", []OperandType{Reg8, Imm8}, "oi", []byte{0xB0}, -1, 0, CPU8086},     // x86.txt:147
+	{"MOV", []OperandType{Reg16, Imm16}, "oi", []byte{0xB8}, -1, 16, CPU8086},  // x86.txt:148
+	{"MOV", []OperandType{Reg32, Imm32}, "oi", []byte{0xB8}, -1, 32, CPU386},   // x86.txt:149
+	{"MOV", []OperandType{RM8, Imm8}, "mi", []byte{0xC6}, 0, 0, CPU8086},       // x86.txt:150
+	{"MOV", []OperandType{RM16, Imm16}, "mi", []byte{0xC7}, 0, 16, CPU8086},    // x86.txt:151
+	{"MOV", []OperandType{RM32, Imm32}, "mi", []byte{0xC7}, 0, 32, CPU386},     // x86.txt:152
+	{"TEST", []OperandType{RM8, Reg8}, "mr", []byte{0x84}, -1, 0, CPU8086},     // x86.txt:154
+	{"TEST", []OperandType{RM16, Reg16}, "mr", []byte{0x85}, -1, 16, CPU8086},  // x86.txt:155
+	{"TEST", []OperandType{RM32, Reg32}, "mr", []byte{0x85}, -1, 32, CPU386},   // x86.txt:156
+	{"TEST", []OperandType{RegAL, Imm8}, "-i", []byte{0xA8}, -1, 0, CPU8086},   // x86.txt:157
+	{"TEST", []OperandType{RegAX, Imm16}, "-i", []byte{0xA9}, -1, 16, CPU8086}, // x86.txt:158
+	{"TEST", []OperandType{RegEAX, Imm32}, "-i", []byte{0xA9}, -1, 32, CPU386}, // x86.txt:159
+	{"TEST", []OperandType{RM8, Imm8}, "mi", []byte{0xF6}, 0, 0, CPU8086},      // x86.txt:160
+	{"TEST", []OperandType{RM16, Imm16}, "mi", []byte{0xF7}, 0, 16, CPU8086},   // x86.txt:161
+	{"TEST", []OperandType{RM32, Imm32}, "mi", []byte{0xF7}, 0, 32, CPU386},    // x86.txt:162
+	{"LEA", []OperandType{Reg16, Mem}, "rm", []byte{0x8D}, -1, 16, CPU8086},    // x86.txt:164
+	{"LEA", []OperandType{Reg32, Mem}, "rm", []byte{0x8D}, -1, 32, CPU386},     // x86.txt:165
+	{"INC", []OperandType{Reg16}, "o", []byte{0x40}, -1, 16, CPU8086},          // x86.txt:167
+	{"INC", []OperandType{Reg32}, "o", []byte{0x40}, -1, 32, CPU386},           // x86.txt:168
+	{"INC", []OperandType{RM8}, "m", []byte{0xFE}, 0, 0, CPU8086},              // x86.txt:169
+	{"INC", []OperandType{RM16}, "m", []byte{0xFF}, 0, 16, CPU8086},            // x86.txt:170
+	{"INC", []OperandType{RM32}, "m", []byte{0xFF}, 0, 32, CPU386},             // x86.txt:171
+	{"DEC", []OperandType{Reg16}, "o", []byte{0x48}, -1, 16, CPU8086},          // x86.txt:173
+	{"DEC", []OperandType{Reg32}, "o", []byte{0x48}, -1, 32, CPU386},           // x86.txt:174
+	{"DEC", []OperandType{RM8}, "m", []byte{0xFE}, 1, 0, CPU8086},              // x86.txt:175
+	{"DEC", []OperandType{RM16}, "m", []byte{0xFF}, 1, 16, CPU8086},            // x86.txt:176
+	{"DEC", []OperandType{RM32}, "m", []byte{0xFF}, 1, 32, CPU386},             // x86.txt:177
+	{"NOT", []OperandType{RM8}, "m", []byte{0xF6}, 2, 0, CPU8086},              // x86.txt:179
+	{"NOT", []OperandType{RM16}, "m", []byte{0xF7}, 2, 16, CPU8086},            // x86.txt:180
+	{"NOT", []OperandType{RM32}, "m", []byte{0xF7}, 2, 32, CPU386},             // x86.txt:181
+	{"NEG", []OperandType{RM8}, "m", []byte{0xF6}, 3, 0, CPU8086},              // x86.txt:183
+	{"NEG", []OperandType{RM16}, "m", []byte{0xF7}, 3, 16, CPU8086},            // x86.txt:184
+	{"NEG", []OperandType{RM32}, "m", []byte{0xF7}, 3, 32, CPU386},             // x86.txt:185
+	{"MUL", []OperandType{RM8}, "m", []byte{0xF6}, 4, 0, CPU8086},              // x86.txt:187
+	{"MUL", []OperandType{RM16}, "m", []byte{0xF7}, 4, 16, CPU8086},            // x86.txt:188
+	{"MUL", []OperandType{RM32}, "m", []byte{0xF7}, 4, 32, CPU386},             // x86.txt:189
+	{"IMUL", []OperandType{RM8}, "m", []byte{0xF6}, 5, 0, CPU8086},             // x86.txt:191
+	{"IMUL", []OperandType{RM16}, "m", []byte{0xF7}, 5, 16, CPU8086},           // x86.txt:192
+	{"IMUL", []OperandType{RM32}, "m", []byte{0xF7}, 5, 32, CPU386},            // x86.txt:193
+	{"DIV", []OperandType{RM8}, "m", []byte{0xF6}, 6, 0, CPU8086},              // x86.txt:195
+	{"DIV", []OperandType{RM16}, "m", []byte{0xF7}, 6, 16, CPU8086},            // x86.txt:196
+	{"DIV", []OperandType{RM32}, "m", []byte{0xF7}, 6, 32, CPU386},             // x86.txt:197
+	{"IDIV", []OperandType{RM8}, "m", []byte{0xF6}, 7, 0, CPU8086},             // x86.txt:199
+	{"IDIV", []OperandType{RM16}, "m", []byte{0xF7}, 7, 16, CPU8086},           // x86.txt:200
+	{"IDIV", []OperandType{RM32}, "m", []byte{0xF7}, 7, 32, CPU386},            // x86.txt:201
+	{"ROL", []OperandType{RM8, One}, "m-", []byte{0xD0}, 0, 0, CPU8086},        // x86.txt:203
+	{"ROL", []OperandType{RM16, One}, "m-", []byte{0xD1}, 0, 16, CPU8086},      // x86.txt:204
+	{"ROL", []OperandType{RM32, One}, "m-", []byte{0xD1}, 0, 32, CPU386},       // x86.txt:205
+	{"ROL", []OperandType{RM8, RegCL}, "m-", []byte{0xD2}, 0, 0, CPU8086},      // x86.txt:206
+	{"ROL", []OperandType{RM16, RegCL}, "m-", []byte{0xD3}, 0, 16, CPU8086},    // x86.txt:207
+	{"ROL", []OperandType{RM32, RegCL}, "m-", []byte{0xD3}, 0, 32, CPU386},     // x86.txt:208
+	{"ROL", []OperandType{RM8, Imm8}, "mi", []byte{0xC0}, 0, 0, CPU186},        // x86.txt:209
+	{"ROL", []OperandType{RM16, Imm8}, "mi", []byte{0xC1}, 0, 16, CPU186},      // x86.txt:210
+	{"ROL", []OperandType{RM32, Imm8}, "mi", []byte{0xC1}, 0, 32, CPU386},      // x86.txt:211
+	{"ROR", []OperandType{RM8, One}, "m-", []byte{0xD0}, 1, 0, CPU8086},        // x86.txt:213
+	{"ROR", []OperandType{RM16, One}, "m-", []byte{0xD1}, 1, 16, CPU8086},      // x86.txt:214
+	{"ROR", []OperandType{RM32, One}, "m-", []byte{0xD1}, 1, 32, CPU386},       // x86.txt:215
+	{"ROR", []OperandType{RM8, RegCL}, "m-", []byte{0xD2}, 1, 0, CPU8086},      // x86.txt:216
+	{"ROR", []OperandType{RM16, RegCL}, "m-", []byte{0xD3}, 1, 16, CPU8086},    // x86.txt:217
+	{"ROR", []OperandType{RM32, RegCL}, "m-", []byte{0xD3}, 1, 32, CPU386},     // x86.txt:218
+	{"ROR", []OperandType{RM8, Imm8}, "mi", []byte{0xC0}, 1, 0, CPU186},        // x86.txt:219
+	{"ROR", []OperandType{RM16, Imm8}, "mi", []byte{0xC1}, 1, 16, CPU186},      // x86.txt:220
+	{"ROR", []OperandType{RM32, Imm8}, "mi", []byte{0xC1}, 1, 32, CPU386},      // x86.txt:221
+	{"RCL", []OperandType{RM8, One}, "m-", []byte{0xD0}, 2, 0, CPU8086},        // x86.txt:223
+	{"RCL", []OperandType{RM16, One}, "m-", []byte{0xD1}, 2, 16, CPU8086},      // x86.txt:224
+	{"RCL", []OperandType{RM32, One}, "m-", []byte{0xD1}, 2, 32, CPU386},       // x86.txt:225
+	{"RCL", []OperandType{RM8, RegCL}, "m-", []byte{0xD2}, 2, 0, CPU8086},      // x86.txt:226
+	{"RCL", []OperandType{RM16, RegCL}, "m-", []byte{0xD3}, 2, 16, CPU8086},    // x86.txt:227
+	{"RCL", []OperandType{RM32, RegCL}, "m-", []byte{0xD3}, 2, 32, CPU386},     // x86.txt:228
+	{"RCL", []OperandType{RM8, Imm8}, "mi", []byte{0xC0}, 2, 0, CPU186},        // x86.txt:229
+	{"RCL", []OperandType{RM16, Imm8}, "mi", []byte{0xC1}, 2, 16, CPU186},      // x86.txt:230
+	{"RCL", []OperandType{RM32, Imm8}, "mi", []byte{0xC1}, 2, 32, CPU386},      // x86.txt:231
+	{"RCR", []OperandType{RM8, One}, "m-", []byte{0xD0}, 3, 0, CPU8086},        // x86.txt:233
+	{"RCR", []OperandType{RM16, One}, "m-", []byte{0xD1}, 3, 16, CPU8086},      // x86.txt:234
+	{"RCR", []OperandType{RM32, One}, "m-", []byte{0xD1}, 3, 32, CPU386},       // x86.txt:235
+	{"RCR", []OperandType{RM8, RegCL}, "m-", []byte{0xD2}, 3, 0, CPU8086},      // x86.txt:236
+	{"RCR", []OperandType{RM16, RegCL}, "m-", []byte{0xD3}, 3, 16, CPU8086},    // x86.txt:237
+	{"RCR", []OperandType{RM32, RegCL}, "m-", []byte{0xD3}, 3, 32, CPU386},     // x86.txt:238
+	{"RCR", []OperandType{RM8, Imm8}, "mi", []byte{0xC0}, 3, 0, CPU186},        // x86.txt:239
+	{"RCR", []OperandType{RM16, Imm8}, "mi", []byte{0xC1}, 3, 16, CPU186},      // x86.txt:240
+	{"RCR", []OperandType{RM32, Imm8}, "mi", []byte{0xC1}, 3, 32, CPU386},      // x86.txt:241
+	{"SHL", []OperandType{RM8, One}, "m-", []byte{0xD0}, 4, 0, CPU8086},        // x86.txt:243
+	{"SHL", []OperandType{RM16, One}, "m-", []byte{0xD1}, 4, 16, CPU8086},      // x86.txt:244
+	{"SHL", []OperandType{RM32, One}, "m-", []byte{0xD1}, 4, 32, CPU386},       // x86.txt:245
+	{"SHL", []OperandType{RM8, RegCL}, "m-", []byte{0xD2}, 4, 0, CPU8086},      // x86.txt:246
+	{"SHL", []OperandType{RM16, RegCL}, "m-", []byte{0xD3}, 4, 16, CPU8086},    // x86.txt:247
+	{"SHL", []OperandType{RM32, RegCL}, "m-", []byte{0xD3}, 4, 32, CPU386},     // x86.txt:248
+	{"SHL", []OperandType{RM8, Imm8}, "mi", []byte{0xC0}, 4, 0, CPU186},        // x86.txt:249
+	{"SHL", []OperandType{RM16, Imm8}, "mi", []byte{0xC1}, 4, 16, CPU186},      // x86.txt:250
+	{"SHL", []OperandType{RM32, Imm8}, "mi", []byte{0xC1}, 4, 32, CPU386},      // x86.txt:251
+	{"SHR", []OperandType{RM8, One}, "m-", []byte{0xD0}, 5, 0, CPU8086},        // x86.txt:253
+	{"SHR", []OperandType{RM16, One}, "m-", []byte{0xD1}, 5, 16, CPU8086},      // x86.txt:254
+	{"SHR", []OperandType{RM32, One}, "m-", []byte{0xD1}, 5, 32, CPU386},       // x86.txt:255
+	{"SHR", []OperandType{RM8, RegCL}, "m-", []byte{0xD2}, 5, 0, CPU8086},      // x86.txt:256
+	{"SHR", []OperandType{RM16, RegCL}, "m-", []byte{0xD3}, 5, 16, CPU8086},    // x86.txt:257
+	{"SHR", []OperandType{RM32, RegCL}, "m-", []byte{0xD3}, 5, 32, CPU386},     // x86.txt:258
+	{"SHR", []OperandType{RM8, Imm8}, "mi", []byte{0xC0}, 5, 0, CPU186},        // x86.txt:259
+	{"SHR", []OperandType{RM16, Imm8}, "mi", []byte{0xC1}, 5, 16, CPU186},      // x86.txt:260
+	{"SHR", []OperandType{RM32, Imm8}, "mi", []byte{0xC1}, 5, 32, CPU386},      // x86.txt:261
+	{"SAR", []OperandType{RM8, One}, "m-", []byte{0xD0}, 7, 0, CPU8086},        // x86.txt:263
+	{"SAR", []OperandType{RM16, One}, "m-", []byte{0xD1}, 7, 16, CPU8086},      // x86.txt:264
+	{"SAR", []OperandType{RM32, One}, "m-", []byte{0xD1}, 7, 32, CPU386},       // x86.txt:265
+	{"SAR", []OperandType{RM8, RegCL}, "m-", []byte{0xD2}, 7, 0, CPU8086},      // x86.txt:266
+	{"SAR", []OperandType{RM16, RegCL}, "m-", []byte{0xD3}, 7, 16, CPU8086},    // x86.txt:267
+	{"SAR", []OperandType{RM32, RegCL}, "m-", []byte{0xD3}, 7, 32, CPU386},     // x86.txt:268
+	{"SAR", []OperandType{RM8, Imm8}, "mi", []byte{0xC0}, 7, 0, CPU186},        // x86.txt:269
+	{"SAR", []OperandType{RM16, Imm8}, "mi", []byte{0xC1}, 7, 16, CPU186},      // x86.txt:270
+	{"SAR", []OperandType{RM32, Imm8}, "mi", []byte{0xC1}, 7, 32, CPU386},      // x86.txt:271
+	{"PUSH", []OperandType{Reg16}, "o", []byte{0x50}, -1, 16, CPU8086},         // x86.txt:273
+	{"PUSH", []OperandType{Reg32}, "o", []byte{0x50}, -1, 32, CPU386},          // x86.txt:274
+	{"PUSH", []OperandType{RegES}, "-", []byte{0x06}, -1, 0, CPU8086},          // x86.txt:275
+	{"PUSH", []OperandType{RegCS}, "-", []byte{0x0E}, -1, 0, CPU8086},          // x86.txt:276
+	{"PUSH", []OperandType{RegSS}, "-", []byte{0x16}, -1, 0, CPU8086},          // x86.txt:277
+	{"PUSH", []OperandType{RegDS}, "-", []byte{0x1E}, -1, 0, CPU8086},          // x86.txt:278
+	{"PUSH", []OperandType{RegFS}, "-", []byte{0x0F, 0xA0}, -1, 0, CPU386},     // x86.txt:279
+	{"PUSH", []OperandType{RegGS}, "-", []byte{0x0F, 0xA8}, -1, 0, CPU386},     // x86.txt:280
+	{"PUSH", []OperandType{SImm8}, "i", []byte{0x6A}, -1, 16, CPU186},          // x86.txt:281
+	{"PUSH", []OperandType{SImm8}, "i", []byte{0x6A}, -1, 32, CPU386},          // x86.txt:282
+	{"PUSH", []OperandType{Imm16}, "i", []byte{0x68}, -1, 16, CPU186},          // x86.txt:283
+	{"PUSH", []OperandType{Imm32}, "i", []byte{0x68}, -1, 32, CPU386},          // x86.txt:284
+	{"PUSH", []OperandType{RM16}, "m", []byte{0xFF}, 6, 16, CPU8086},           // x86.txt:285
+	{"PUSH", []OperandType{RM32}, "m", []byte{0xFF}, 6, 32, CPU386},            // x86.txt:286
+	{"POP", []OperandType{Reg16}, "o", []byte{0x58}, -1, 16, CPU8086},          // x86.txt:288
+	{"POP", []OperandType{Reg32}, "o", []byte{0x58}, -1, 32, CPU386},           // x86.txt:289
+	{"POP", []OperandType{RegES}, "-", []byte{0x07}, -1, 0, CPU8086},           // x86.txt:290
+	{"POP", []OperandType{RegSS}, "-", []byte{0x17}, -1, 0, CPU8086},           // x86.txt:291
+	{"POP", []OperandType{RegDS}, "-", []byte{0x1F}, -1, 0, CPU8086},           // x86.txt:292
+	{"POP", []OperandType{RegFS}, "-", []byte{0x0F, 0xA1}, -1, 0, CPU386},      // x86.txt:293
+	{"POP", []OperandType{RegGS}, "-", []byte{0x0F, 0xA9}, -1, 0, CPU386},      // x86.txt:294
+	{"POP", []OperandType{RM16}, "m", []byte{0x8F}, 0, 16, CPU8086},            // x86.txt:295
+	{"POP", []OperandType{RM32}, "m", []byte{0x8F}, 0, 32, CPU386},             // x86.txt:296
+	{"JMP", []OperandType{Rel8}, "j", []byte{0xEB}, -1, 0, CPU8086},            // x86.txt:298
+	{"JMP", []OperandType{Rel16}, "j", []byte{0xE9}, -1, 16, CPU8086},          // x86.txt:299
+	{"JMP", []OperandType{Rel32}, "j", []byte{0xE9}, -1, 32, CPU386},           // x86.txt:300
+	{"JMP", []OperandType{RM16}, "m", []byte{0xFF}, 4, 16, CPU8086},            // x86.txt:301
+	{"JMP", []OperandType{RM32}, "m", []byte{0xFF}, 4, 32, CPU386},             // x86.txt:302
+	{"CALL", []OperandType{Rel16}, "j", []byte{0xE8}, -1, 16, CPU8086},         // x86.txt:304
+	{"CALL", []OperandType{Rel32}, "j", []byte{0xE8}, -1, 32, CPU386},          // x86.txt:305
+	{"CALL", []OperandType{RM16}, "m", []byte{0xFF}, 2, 16, CPU8086},           // x86.txt:306
+	{"CALL", []OperandType{RM32}, "m", []byte{0xFF}, 2, 32, CPU386},            // x86.txt:307
+	{"JO", []OperandType{Rel8}, "j", []byte{0x70}, -1, 0, CPU8086},             // x86.txt:309
+	{"JO", []OperandType{Rel16}, "j", []byte{0x0F, 0x80}, -1, 16, CPU386},      // x86.txt:310
+	{"JO", []OperandType{Rel32}, "j", []byte{0x0F, 0x80}, -1, 32, CPU386},      // x86.txt:311
+	{"JNO", []OperandType{Rel8}, "j", []byte{0x71}, -1, 0, CPU8086},            // x86.txt:313
+	{"JNO", []OperandType{Rel16}, "j", []byte{0x0F, 0x81}, -1, 16, CPU386},     // x86.txt:314
+	{"JNO", []OperandType{Rel32}, "j", []byte{0x0F, 0x81}, -1, 32, CPU386},     // x86.txt:315
+	{"JB", []OperandType{Rel8}, "j", []byte{0x72}, -1, 0, CPU8086},             // x86.txt:317
+	{"JB", []OperandType{Rel16}, "j", []byte{0x0F, 0x82}, -1, 16, CPU386},      // x86.txt:318
+	{"JB", []OperandType{Rel32}, "j", []byte{0x0F, 0x82}, -1, 32, CPU386},      // x86.txt:319
+	{"JAE", []OperandType{Rel8}, "j", []byte{0x73}, -1, 0, CPU8086},            // x86.txt:321
+	{"JAE", []OperandType{Rel16}, "j", []byte{0x0F, 0x83}, -1, 16, CPU386},     // x86.txt:322
+	{"JAE", []OperandType{Rel32}, "j", []byte{0x0F, 0x83}, -1, 32, CPU386},     // x86.txt:323
+	{"JE", []OperandType{Rel8}, "j", []byte{0x74}, -1, 0, CPU8086},             // x86.txt:325
+	{"JE", []OperandType{Rel16}, "j", []byte{0x0F, 0x84}, -1, 16, CPU386},      // x86.txt:326
+	{"JE", []OperandType{Rel32}, "j", []byte{0x0F, 0x84}, -1, 32, CPU386},      // x86.txt:327
+	{"JNE", []OperandType{Rel8}, "j", []byte{0x75}, -1, 0, CPU8086},            // x86.txt:329
+	{"JNE", []OperandType{Rel16}, "j", []byte{0x0F, 0x85}, -1, 16, CPU386},     // x86.txt:330
+	{"JNE", []OperandType{Rel32}, "j", []byte{0x0F, 0x85}, -1, 32, CPU386},     // x86.txt:331
+	{"JBE", []OperandType{Rel8}, "j", []byte{0x76}, -1, 0, CPU8086},            // x86.txt:333
+	{"JBE", []OperandType{Rel16}, "j", []byte{0x0F, 0x86}, -1, 16, CPU386},     // x86.txt:334
+	{"JBE", []OperandType{Rel32}, "j", []byte{0x0F, 0x86}, -1, 32, CPU386},     // x86.txt:335
+	{"JA", []OperandType{Rel8}, "j", []byte{0x77}, -1, 0, CPU8086},             // x86.txt:337
+	{"JA", []OperandType{Rel16}, "j", []byte{0x0F, 0x87}, -1, 16, CPU386},      // x86.txt:338
+	{"JA", []OperandType{Rel32}, "j", []byte{0x0F, 0x87}, -1, 32, CPU386},      // x86.txt:339
+	{"JS", []OperandType{Rel8}, "j", []byte{0x78}, -1, 0, CPU8086},             // x86.txt:341
+	{"JS", []OperandType{Rel16}, "j", []byte{0x0F, 0x88}, -1, 16, CPU386},      // x86.txt:342
+	{"JS", []OperandType{Rel32}, "j", []byte{0x0F, 0x88}, -1, 32, CPU386},      // x86.txt:343
+	{"JNS", []OperandType{Rel8}, "j", []byte{0x79}, -1, 0, CPU8086},            // x86.txt:345
+	{"JNS", []OperandType{Rel16}, "j", []byte{0x0F, 0x89}, -1, 16, CPU386},     // x86.txt:346
+	{"JNS", []OperandType{Rel32}, "j", []byte{0x0F, 0x89}, -1, 32, CPU386},     // x86.txt:347
+	{"JP", []OperandType{Rel8}, "j", []byte{0x7A}, -1, 0, CPU8086},             // x86.txt:349
+	{"JP", []OperandType{Rel16}, "j", []byte{0x0F, 0x8A}, -1, 16, CPU386},      // x86.txt:350
+	{"JP", []OperandType{Rel32}, "j", []byte{0x0F, 0x8A}, -1, 32, CPU386},      // x86.txt:351
+	{"JNP", []OperandType{Rel8}, "j", []byte{0x7B}, -1, 0, CPU8086},            // x86.txt:353
+	{"JNP", []OperandType{Rel16}, "j", []byte{0x0F, 0x8B}, -1, 16, CPU386},     // x86.txt:354
+	{"JNP", []OperandType{Rel32}, "j", []byte{0x0F, 0x8B}, -1, 32, CPU386},     // x86.txt:355
+	{"JL", []OperandType{Rel8}, "j", []byte{0x7C}, -1, 0, CPU8086},             // x86.txt:357
+	{"JL", []OperandType{Rel16}, "j", []byte{0x0F, 0x8C}, -1, 16, CPU386},      // x86.txt:358
+	{"JL", []OperandType{Rel32}, "j", []byte{0x0F, 0x8C}, -1, 32, CPU386},      // x86.txt:359
+	{"JGE", []OperandType{Rel8}, "j", []byte{0x7D}, -1, 0, CPU8086},            // x86.txt:361
+	{"JGE", []OperandType{Rel16}, "j", []byte{0x0F, 0x8D}, -1, 16, CPU386},     // x86.txt:362
+	{"JGE", []OperandType{Rel32}, "j", []byte{0x0F, 0x8D}, -1, 32, CPU386},     // x86.txt:363
+	{"JLE", []OperandType{Rel8}, "j", []byte{0x7E}, -1, 0, CPU8086},            // x86.txt:365
+	{"JLE", []OperandType{Rel16}, "j", []byte{0x0F, 0x8E}, -1, 16, CPU386},     // x86.txt:366
+	{"JLE", []OperandType{Rel32}, "j", []byte{0x0F, 0x8E}, -1, 32, CPU386},     // x86.txt:367
+	{"JG", []OperandType{Rel8}, "j", []byte{0x7F}, -1, 0, CPU8086},             // x86.txt:369
+	{"JG", []OperandType{Rel16}, "j", []byte{0x0F, 0x8F}, -1, 16, CPU386},      // x86.txt:370
+	{"JG", []OperandType{Rel32}, "j", []byte{0x0F, 0x8F}, -1, 32, CPU386},      // x86.txt:371
+	{"JC", []OperandType{Rel8}, "j", []byte{0x72}, -1, 0, CPU8086},             // x86.txt:373
+	{"JC", []OperandType{Rel16}, "j", []byte{0x0F, 0x82}, -1, 16, CPU386},      // x86.txt:374
+	{"JC", []OperandType{Rel32}, "j", []byte{0x0F, 0x82}, -1, 32, CPU386},      // x86.txt:375
+	{"JNB", []OperandType{Rel8}, "j", []byte{0x73}, -1, 0, CPU8086},            // x86.txt:377
+	{"JNB", []OperandType{Rel16}, "j", []byte{0x0F, 0x83}, -1, 16, CPU386},     // x86.txt:378
+	{"JNB", []OperandType{Rel32}, "j", []byte{0x0F, 0x83}, -1, 32, CPU386},     // x86.txt:379
+	{"JZ", []OperandType{Rel8}, "j", []byte{0x74}, -1, 0, CPU8086},             // x86.txt:381
+	{"JZ", []OperandType{Rel16}, "j", []byte{0x0F, 0x84}, -1, 16, CPU386},      // x86.txt:382
+	{"JZ", []OperandType{Rel32}, "j", []byte{0x0F, 0x84}, -1, 32, CPU386},      // x86.txt:383
+	{"JNZ", []OperandType{Rel8}, "j", []byte{0x75}, -1, 0, CPU8086},            // x86.txt:385
+	{"JNZ", []OperandType{Rel16}, "j", []byte{0x0F, 0x85}, -1, 16, CPU386},     // x86.txt:386
+	{"JNZ", []OperandType{Rel32}, "j", []byte{0x0F, 0x85}, -1, 32, CPU386},     // x86.txt:387
+	{"JNA", []OperandType{Rel8}, "j", []byte{0x76}, -1, 0, CPU8086},            // x86.txt:389
+	{"JNA", []OperandType{Rel16}, "j", []byte{0x0F, 0x86}, -1, 16, CPU386},     // x86.txt:390
+	{"JNA", []OperandType{Rel32}, "j", []byte{0x0F, 0x86}, -1, 32, CPU386},     // x86.txt:391
+	{"JNBE", []OperandType{Rel8}, "j", []byte{0x77}, -1, 0, CPU8086},           // x86.txt:393
+	{"JNBE", []OperandType{Rel16}, "j", []byte{0x0F, 0x87}, -1, 16, CPU386},    // x86.txt:394
+	{"JNBE", []OperandType{Rel32}, "j", []byte{0x0F, 0x87}, -1, 32, CPU386},    // x86.txt:395
+	{"JPE", []OperandType{Rel8}, "j", []byte{0x7A}, -1, 0, CPU8086},            // x86.txt:397
+	{"JPE", []OperandType{Rel16}, "j", []byte{0x0F, 0x8A}, -1, 16, CPU386},     // x86.txt:398
+	{"JPE", []OperandType{Rel32}, "j", []byte{0x0F, 0x8A}, -1, 32, CPU386},     // x86.txt:399
+	{"JPO", []OperandType{Rel8}, "j", []byte{0x7B}, -1, 0, CPU8086},            // x86.txt:401
+	{"JPO", []OperandType{Rel16}, "j", []byte{0x0F, 0x8B}, -1, 16, CPU386},     // x86.txt:402
+	{"JPO", []OperandType{Rel32}, "j", []byte{0x0F, 0x8B}, -1, 32, CPU386},     // x86.txt:403
+	{"JNGE", []OperandType{Rel8}, "j", []byte{0x7C}, -1, 0, CPU8086},           // x86.txt:405
+	{"JNGE", []OperandType{Rel16}, "j", []byte{0x0F, 0x8C}, -1, 16, CPU386},    // x86.txt:406
+	{"JNGE", []OperandType{Rel32}, "j", []byte{0x0F, 0x8C}, -1, 32, CPU386},    // x86.txt:407
+	{"JNL", []OperandType{Rel8}, "j", []byte{0x7D}, -1, 0, CPU8086},            // x86.txt:409
+	{"JNL", []OperandType{Rel16}, "j", []byte{0x0F, 0x8D}, -1, 16, CPU386},     // x86.txt:410
+	{"JNL", []OperandType{Rel32}, "j", []byte{0x0F, 0x8D}, -1, 32, CPU386},     // x86.txt:411
+	{"JNG", []OperandType{Rel8}, "j", []byte{0x7E}, -1, 0, CPU8086},            // x86.txt:413
+	{"JNG", []OperandType{Rel16}, "j", []byte{0x0F, 0x8E}, -1, 16, CPU386},     // x86.txt:414
+	{"JNG", []OperandType{Rel32}, "j", []byte{0x0F, 0x8E}, -1, 32, CPU386},     // x86.txt:415
+	{"JNLE", []OperandType{Rel8}, "j", []byte{0x7F}, -1, 0, CPU8086},           // x86.txt:417
+	{"JNLE", []OperandType{Rel16}, "j", []byte{0x0F, 0x8F}, -1, 16, CPU386},    // x86.txt:418
+	{"JNLE", []OperandType{Rel32}, "j", []byte{0x0F, 0x8F}, -1, 32, CPU386},    // x86.txt:419
+	{"JNAE", []OperandType{Rel8}, "j", []byte{0x72}, -1, 0, CPU8086},           // x86.txt:421
+	{"JNAE", []OperandType{Rel16}, "j", []byte{0x0F, 0x82}, -1, 16, CPU386},    // x86.txt:422
+	{"JNAE", []OperandType{Rel32}, "j", []byte{0x0F, 0x82}, -1, 32, CPU386},    // x86.txt:423
+	{"JNC", []OperandType{Rel8}, "j", []byte{0x73}, -1, 0, CPU8086},            // x86.txt:425
+	{"JNC", []OperandType{Rel16}, "j", []byte{0x0F, 0x83}, -1, 16, CPU386},     // x86.txt:426
+	{"JNC", []OperandType{Rel32}, "j", []byte{0x0F, 0x83}, -1, 32, CPU386},     // x86.txt:427
+	{"LOOP", []OperandType{Rel8}, "j", []byte{0xE2}, -1, 0, CPU8086},           // x86.txt:429
+	{"LOOPE", []OperandType{Rel8}, "j", []byte{0xE1}, -1, 0, CPU8086},          // x86.txt:431
+	{"LOOPZ", []OperandType{Rel8}, "j", []byte{0xE1}, -1, 0, CPU8086},          // x86.txt:433
+	{"LOOPNE", []OperandType{Rel8}, "j", []byte{0xE0}, -1, 0, CPU8086},         // x86.txt:435
+	{"LOOPNZ", []OperandType{Rel8}, "j", []byte{0xE0}, -1, 0, CPU8086},         // x86.txt:437
+	{"JCXZ", []OperandType{Rel8}, "j", []byte{0xE3}, -1, 0, CPU8086},           // x86.txt:439
+	{"RET", []OperandType{}, "", []byte{0xC3}, -1, 0, CPU8086},                 // x86.txt:441
+	{"RET", []OperandType{Imm16}, "i", []byte{0xC2}, -1, 0, CPU8086},           // x86.txt:442
+	{"RETF", []OperandType{}, "", []byte{0xCB}, -1, 0, CPU8086},                // x86.txt:444
+	{"RETF", []OperandType{Imm16}, "i", []byte{0xCA}, -1, 0, CPU8086},          // x86.txt:445
+	{"INT3", []OperandType{}, "", []byte{0xCC}, -1, 0, CPU8086},                // x86.txt:447
+	{"INT", []OperandType{Imm8}, "i", []byte{0xCD}, -1, 0, CPU8086},            // x86.txt:449
+	{"IRET", []OperandType{}, "", []byte{0xCF}, -1, 16, CPU8086},               // x86.txt:451
+	{"IRETD", []OperandType{}, "", []byte{0xCF}, -1, 32, CPU386},               // x86.txt:453
+	{"IN", []OperandType{RegAL, Imm8}, "-i", []byte{0xE4}, -1, 0, CPU8086},     // x86.txt:455
+	{"IN", []OperandType{RegAX, Imm8}, "-i", []byte{0xE5}, -1, 16, CPU8086},    // x86.txt:456
+	{"IN", []OperandType{RegEAX, Imm8}, "-i", []byte{0xE5}, -1, 32, CPU386},    // x86.txt:457
+	{"IN", []OperandType{RegAL, RegDX}, "--", []byte{0xEC}, -1, 0, CPU8086},    // x86.txt:458
+	{"IN", []OperandType{RegAX, RegDX}, "--", []byte{0xED}, -1, 16, CPU8086},   // x86.txt:459
+	{"IN", []OperandType{RegEAX, RegDX}, "--", []byte{0xED}, -1, 32, CPU386},   // x86.txt:460
+	{"OUT", []OperandType{Imm8, RegAL}, "i-", []byte{0xE6}, -1, 0, CPU8086},    // x86.txt:462
+	{"OUT", []OperandType{Imm8, RegAX}, "i-", []byte{0xE7}, -1, 16, CPU8086},   // x86.txt:463
+	{"OUT", []OperandType{Imm8, RegEAX}, "i-", []byte{0xE7}, -1, 32, CPU386},   // x86.txt:464
+	{"OUT", []OperandType{RegDX, RegAL}, "--", []byte{0xEE}, -1, 0, CPU8086},   // x86.txt:465
+	{"OUT", []OperandType{RegDX, RegAX}, "--", []byte{0xEF}, -1, 16, CPU8086},  // x86.txt:466
+	{"OUT", []OperandType{RegDX, RegEAX}, "--", []byte{0xEF}, -1, 32, CPU386},  // x86.txt:467
+	{"NOP", []OperandType{}, "", []byte{0x90}, -1, 0, CPU8086},                 // x86.txt:469
+	{"HLT", []OperandType{}, "", []byte{0xF4}, -1, 0, CPU8086},                 // x86.txt:471
+	{"CMC", []OperandType{}, "", []byte{0xF5}, -1, 0, CPU8086},                 // x86.txt:473
+	{"CLC", []OperandType{}, "", []byte{0xF8}, -1, 0, CPU8086},                 // x86.txt:475
+	{"STC", []OperandType{}, "", []byte{0xF9}, -1, 0, CPU8086},                 // x86.txt:477
+	{"CLI", []OperandType{}, "", []byte{0xFA}, -1, 0, CPU8086},                 // x86.txt:479
+	{"STI", []OperandType{}, "", []byte{0xFB}, -1, 0, CPU8086},                 // x86.txt:481
+	{"CLD", []OperandType{}, "", []byte{0xFC}, -1, 0, CPU8086},                 // x86.txt:483
+	{"STD", []OperandType{}, "", []byte{0xFD}, -1, 0, CPU8086},                 // x86.txt:485
+	{"MOVSB", []OperandType{}, "", []byte{0xA4}, -1, 0, CPU8086},               // x86.txt:487
+	{"CMPSB", []OperandType{}, "", []byte{0xA6}, -1, 0, CPU8086},               // x86.txt:489
+	{"STOSB", []OperandType{}, "", []byte{0xAA}, -1, 0, CPU8086},               // x86.txt:491
+	{"LODSB", []OperandType{}, "", []byte{0xAC}, -1, 0, CPU8086},               // x86.txt:493
+	{"SCASB", []OperandType{}, "", []byte{0xAE}, -1, 0, CPU8086},               // x86.txt:495
+	{"MOVSW", []OperandType{}, "", []byte{0xA5}, -1, 16, CPU8086},              // x86.txt:497
+	{"MOVSD", []OperandType{}, "", []byte{0xA5}, -1, 32, CPU386},               // x86.txt:499
+	{"CMPSW", []OperandType{}, "", []byte{0xA7}, -1, 16, CPU8086},              // x86.txt:501
+	{"CMPSD", []OperandType{}, "", []byte{0xA7}, -1, 32, CPU386},               // x86.txt:503
+	{"STOSW", []OperandType{}, "", []byte{0xAB}, -1, 16, CPU8086},              // x86.txt:505
+	{"STOSD", []OperandType{}, "", []byte{0xAB}, -1, 32, CPU386},               // x86.txt:507
+	{"LODSW", []OperandType{}, "", []byte{0xAD}, -1, 16, CPU8086},              // x86.txt:509
+	{"LODSD", []OperandType{}, "", []byte{0xAD}, -1, 32, CPU386},               // x86.txt:511
+	{"SCASW", []OperandType{}, "", []byte{0xAF}, -1, 16, CPU8086},              // x86.txt:513
+	{"SCASD", []OperandType{}, "", []byte{0xAF}, -1, 32, CPU386},               // x86.txt:515
+	{"PUSHF", []OperandType{}, "", []byte{0x9C}, -1, 16, CPU8086},              // x86.txt:517
+	{"PUSHFD", []OperandType{}, "", []byte{0x9C}, -1, 32, CPU386},              // x86.txt:519
+	{"POPF", []OperandType{}, "", []byte{0x9D}, -1, 16, CPU8086},               // x86.txt:521
+	{"POPFD", []OperandType{}, "", []byte{0x9D}, -1, 32, CPU386},               // x86.txt:523
+	{"PUSHA", []OperandType{}, "", []byte{0x60}, -1, 16, CPU186},               // x86.txt:525
+	{"PUSHAD", []OperandType{}, "", []byte{0x60}, -1, 32, CPU386},              // x86.txt:527
+	{"POPA", []OperandType{}, "", []byte{0x61}, -1, 16, CPU186},                // x86.txt:529
+	{"POPAD", []OperandType{}, "", []byte{0x61}, -1, 32, CPU386},               // x86.txt:531
+	{"CBW", []OperandType{}, "", []byte{0x98}, -1, 16, CPU8086},                // x86.txt:533
+	{"CWDE", []OperandType{}, "", []byte{0x98}, -1, 32, CPU386},                // x86.txt:535
+	{"CWD", []OperandType{}, "", []byte{0x99}, -1, 16, CPU8086},                // x86.txt:537
+	{"CDQ", []OperandType{}, "", []byte{0x99}, -1, 32, CPU386},                 // x86.txt:539
+}
+
+// ニーモニックをキーとした命令形式の一覧
+var formsByMnemonic = map[string][]*Form{
+	"ADD":    {&forms[0], &forms[1], &forms[2], &forms[3], &forms[4], &forms[5], &forms[6], &forms[7], &forms[8], &forms[9], &forms[10], &forms[11], &forms[12], &forms[13]},
+	"OR":     {&forms[14], &forms[15], &forms[16], &forms[17], &forms[18], &forms[19], &forms[20], &forms[21], &forms[22], &forms[23], &forms[24], &forms[25], &forms[26], &forms[27]},
+	"ADC":    {&forms[28], &forms[29], &forms[30], &forms[31], &forms[32], &forms[33], &forms[34], &forms[35], &forms[36], &forms[37], &forms[38], &forms[39], &forms[40], &forms[41]},
+	"SBB":    {&forms[42], &forms[43], &forms[44], &forms[45], &forms[46], &forms[47], &forms[48], &forms[49], &forms[50], &forms[51], &forms[52], &forms[53], &forms[54], &forms[55]},
+	"AND":    {&forms[56], &forms[57], &forms[58], &forms[59], &forms[60], &forms[61], &forms[62], &forms[63], &forms[64], &forms[65], &forms[66], &forms[67], &forms[68], &forms[69]},
+	"SUB":    {&forms[70], &forms[71], &forms[72], &forms[73], &forms[74], &forms[75], &forms[76], &forms[77], &forms[78], &forms[79], &forms[80], &forms[81], &forms[82], &forms[83]},
+	"XOR":    {&forms[84], &forms[85], &forms[86], &forms[87], &forms[88], &forms[89], &forms[90], &forms[91], &forms[92], &forms[93], &forms[94], &forms[95], &forms[96], &forms[97]},
+	"CMP":    {&forms[98], &forms[99], &forms[100], &forms[101], &forms[102], &forms[103], &forms[104], &forms[105], &forms[106], &forms[107], &forms[108], &forms[109], &forms[110], &forms[111]},
+	"MOV":    {&forms[112], &forms[113], &forms[114], &forms[115], &forms[116], &forms[117], &forms[118], &forms[119], &forms[120], &forms[121], &forms[122], &forms[123], &forms[124], &forms[125]},
+	"TEST":   {&forms[126], &forms[127], &forms[128], &forms[129], &forms[130], &forms[131], &forms[132], &forms[133], &forms[134]},
+	"LEA":    {&forms[135], &forms[136]},
+	"INC":    {&forms[137], &forms[138], &forms[139], &forms[140], &forms[141]},
+	"DEC":    {&forms[142], &forms[143], &forms[144], &forms[145], &forms[146]},
+	"NOT":    {&forms[147], &forms[148], &forms[149]},
+	"NEG":    {&forms[150], &forms[151], &forms[152]},
+	"MUL":    {&forms[153], &forms[154], &forms[155]},
+	"IMUL":   {&forms[156], &forms[157], &forms[158]},
+	"DIV":    {&forms[159], &forms[160], &forms[161]},
+	"IDIV":   {&forms[162], &forms[163], &forms[164]},
+	"ROL":    {&forms[165], &forms[166], &forms[167], &forms[168], &forms[169], &forms[170], &forms[171], &forms[172], &forms[173]},
+	"ROR":    {&forms[174], &forms[175], &forms[176], &forms[177], &forms[178], &forms[179], &forms[180], &forms[181], &forms[182]},
+	"RCL":    {&forms[183], &forms[184], &forms[185], &forms[186], &forms[187], &forms[188], &forms[189], &forms[190], &forms[191]},
+	"RCR":    {&forms[192], &forms[193], &forms[194], &forms[195], &forms[196], &forms[197], &forms[198], &forms[199], &forms[200]},
+	"SHL":    {&forms[201], &forms[202], &forms[203], &forms[204], &forms[205], &forms[206], &forms[207], &forms[208], &forms[209]},
+	"SHR":    {&forms[210], &forms[211], &forms[212], &forms[213], &forms[214], &forms[215], &forms[216], &forms[217], &forms[218]},
+	"SAR":    {&forms[219], &forms[220], &forms[221], &forms[222], &forms[223], &forms[224], &forms[225], &forms[226], &forms[227]},
+	"PUSH":   {&forms[228], &forms[229], &forms[230], &forms[231], &forms[232], &forms[233], &forms[234], &forms[235], &forms[236], &forms[237], &forms[238], &forms[239], &forms[240], &forms[241]},
+	"POP":    {&forms[242], &forms[243], &forms[244], &forms[245], &forms[246], &forms[247], &forms[248], &forms[249], &forms[250]},
+	"JMP":    {&forms[251], &forms[252], &forms[253], &forms[254], &forms[255]},
+	"CALL":   {&forms[256], &forms[257], &forms[258], &forms[259]},
+	"JO":     {&forms[260], &forms[261], &forms[262]},
+	"JNO":    {&forms[263], &forms[264], &forms[265]},
+	"JB":     {&forms[266], &forms[267], &forms[268]},
+	"JAE":    {&forms[269], &forms[270], &forms[271]},
+	"JE":     {&forms[272], &forms[273], &forms[274]},
+	"JNE":    {&forms[275], &forms[276], &forms[277]},
+	"JBE":    {&forms[278], &forms[279], &forms[280]},
+	"JA":     {&forms[281], &forms[282], &forms[283]},
+	"JS":     {&forms[284], &forms[285], &forms[286]},
+	"JNS":    {&forms[287], &forms[288], &forms[289]},
+	"JP":     {&forms[290], &forms[291], &forms[292]},
+	"JNP":    {&forms[293], &forms[294], &forms[295]},
+	"JL":     {&forms[296], &forms[297], &forms[298]},
+	"JGE":    {&forms[299], &forms[300], &forms[301]},
+	"JLE":    {&forms[302], &forms[303], &forms[304]},
+	"JG":     {&forms[305], &forms[306], &forms[307]},
+	"JC":     {&forms[308], &forms[309], &forms[310]},
+	"JNB":    {&forms[311], &forms[312], &forms[313]},
+	"JZ":     {&forms[314], &forms[315], &forms[316]},
+	"JNZ":    {&forms[317], &forms[318], &forms[319]},
+	"JNA":    {&forms[320], &forms[321], &forms[322]},
+	"JNBE":   {&forms[323], &forms[324], &forms[325]},
+	"JPE":    {&forms[326], &forms[327], &forms[328]},
+	"JPO":    {&forms[329], &forms[330], &forms[331]},
+	"JNGE":   {&forms[332], &forms[333], &forms[334]},
+	"JNL":    {&forms[335], &forms[336], &forms[337]},
+	"JNG":    {&forms[338], &forms[339], &forms[340]},
+	"JNLE":   {&forms[341], &forms[342], &forms[343]},
+	"JNAE":   {&forms[344], &forms[345], &forms[346]},
+	"JNC":    {&forms[347], &forms[348], &forms[349]},
+	"LOOP":   {&forms[350]},
+	"LOOPE":  {&forms[351]},
+	"LOOPZ":  {&forms[352]},
+	"LOOPNE": {&forms[353]},
+	"LOOPNZ": {&forms[354]},
+	"JCXZ":   {&forms[355]},
+	"RET":    {&forms[356], &forms[357]},
+	"RETF":   {&forms[358], &forms[359]},
+	"INT3":   {&forms[360]},
+	"INT":    {&forms[361]},
+	"IRET":   {&forms[362]},
+	"IRETD":  {&forms[363]},
+	"IN":     {&forms[364], &forms[365], &forms[366], &forms[367], &forms[368], &forms[369]},
+	"OUT":    {&forms[370], &forms[371], &forms[372], &forms[373], &forms[374], &forms[375]},
+	"NOP":    {&forms[376]},
+	"HLT":    {&forms[377]},
+	"CMC":    {&forms[378]},
+	"CLC":    {&forms[379]},
+	"STC":    {&forms[380]},
+	"CLI":    {&forms[381]},
+	"STI":    {&forms[382]},
+	"CLD":    {&forms[383]},
+	"STD":    {&forms[384]},
+	"MOVSB":  {&forms[385]},
+	"CMPSB":  {&forms[386]},
+	"STOSB":  {&forms[387]},
+	"LODSB":  {&forms[388]},
+	"SCASB":  {&forms[389]},
+	"MOVSW":  {&forms[390]},
+	"MOVSD":  {&forms[391]},
+	"CMPSW":  {&forms[392]},
+	"CMPSD":  {&forms[393]},
+	"STOSW":  {&forms[394]},
+	"STOSD":  {&forms[395]},
+	"LODSW":  {&forms[396]},
+	"LODSD":  {&forms[397]},
+	"SCASW":  {&forms[398]},
+	"SCASD":  {&forms[399]},
+	"PUSHF":  {&forms[400]},
+	"PUSHFD": {&forms[401]},
+	"POPF":   {&forms[402]},
+	"POPFD":  {&forms[403]},
+	"PUSHA":  {&forms[404]},
+	"PUSHAD": {&forms[405]},
+	"POPA":   {&forms[406]},
+	"POPAD":  {&forms[407]},
+	"CBW":    {&forms[408]},
+	"CWDE":   {&forms[409]},
+	"CWD":    {&forms[410]},
+	"CDQ":    {&forms[411]},
+}
@@ -0,0 +1,175 @@
+package instruction
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler/expr"
+)
+
+func TestNewX86(t *testing.T) {
+
+	testCases := []struct {
+		bits     int
+		mnemonic string
+		operands []string
+		want     []byte
+	}{
+		{16, "MOV", []string{"AX", "0"}, []byte{0xb8, 0x00, 0x00}},
+		{16, "MOV", []string{"SS", "AX"}, []byte{0x8e, 0xd0}},
+		{16, "MOV", []string{"AL", "[SI]"}, []byte{0x8a, 0x04}},
+		{16, "MOV", []string{"AX", "BX"}, []byte{0x89, 0xd8}},
+		{16, "MOV", []string{"[BP]", "AL"}, []byte{0x88, 0x46, 0x00}},
+		{16, "MOV", []string{"[BX+SI+0x10]", "DX"}, []byte{0x89, 0x50, 0x10}},
+		{16, "ADD", []string{"[BP+SI]", "AL"}, []byte{0x00, 0x02}},
+		{16, "MOV", []string{"[BX-2]", "DX"}, []byte{0x89, 0x57, 0xfe}},
+		{16, "MOV", []string{"[DI+0x1234]", "CX"}, []byte{0x89, 0x8d, 0x34, 0x12}},
+		{16, "MOV", []string{"AX", "[0x7c00]"}, []byte{0x8b, 0x06, 0x00, 0x7c}},
+		{16, "MOV", []string{"BYTE [ES:DI]", "0x41"}, []byte{0x26, 0xc6, 0x05, 0x41}},
+		{16, "MOV", []string{"WORD [BX]", "0x1234"}, []byte{0xc7, 0x07, 0x34, 0x12}},
+		{16, "MOV", []string{"EAX", "1"}, []byte{0x66, 0xb8, 0x01, 0x00, 0x00, 0x00}},
+		{16, "ADD", []string{"SI", "1"}, []byte{0x83, 0xc6, 0x01}},
+		{16, "ADD", []string{"AX", "0x1234"}, []byte{0x05, 0x34, 0x12}},
+		{16, "ADD", []string{"BX", "0xFFFF"}, []byte{0x83, 0xc3, 0xff}},
+		{16, "CMP", []string{"AL", "0"}, []byte{0x3c, 0x00}},
+		{16, "SHL", []string{"AX", "1"}, []byte{0xd1, 0xe0}},
+		{16, "SHL", []string{"AX", "4"}, []byte{0xc1, 0xe0, 0x04}},
+		{16, "SHR", []string{"BYTE [SI]", "CL"}, []byte{0xd2, 0x2c}},
+		{16, "INC", []string{"CX"}, []byte{0x41}},
+		{16, "INC", []string{"WORD [DI]"}, []byte{0xff, 0x05}},
+		{16, "PUSH", []string{"ES"}, []byte{0x06}},
+		{16, "PUSH", []string{"-1"}, []byte{0x6a, 0xff}},
+		{16, "LEA", []string{"SI", "[BX+DI+3]"}, []byte{0x8d, 0x71, 0x03}},
+		{16, "IN", []string{"AL", "0x60"}, []byte{0xe4, 0x60}},
+		{16, "OUT", []string{"DX", "AL"}, []byte{0xee}},
+		{16, "INT", []string{"0x10"}, []byte{0xcd, 0x10}},
+		{16, "HLT", nil, []byte{0xf4}},
+		{16, "JMP", []string{"0x7c00"}, []byte{0xeb, 0xfe}},
+		{16, "JMP", []string{"NEAR 0x7c00"}, []byte{0xe9, 0xfd, 0xff}},
+		{16, "CALL", []string{"0x7c10"}, []byte{0xe8, 0x0d, 0x00}},
+		{16, "JNZ", []string{"0x7d00"}, []byte{0x0f, 0x85, 0xfc, 0x00}},
+		{32, "MOV", []string{"EAX", "[EBX+4]"}, []byte{0x8b, 0x43, 0x04}},
+		{32, "MOV", []string{"AX", "1"}, []byte{0x66, 0xb8, 0x01, 0x00}},
+		{32, "MOV", []string{"[EBP]", "ECX"}, []byte{0x89, 0x4d, 0x00}},
+		{32, "PUSH", []string{"0x1234"}, []byte{0x68, 0x34, 0x12, 0x00, 0x00}},
+		{32, "JMP", []string{"NEAR 0x7c00"}, []byte{0xe9, 0xfb, 0xff, 0xff, 0xff}},
+	}
+
+	for i, tt := range testCases {
+
+		operands := make([]Operand, len(tt.operands))
+		for j, s := range tt.operands {
+			op, err := ParseOperand(s)
+			if err != nil {
+				t.Fatal(i, err)
+			}
+			operands[j] = op
+		}
+
+		x, err := NewX86(tt.mnemonic, operands, tt.bits, CPU386, 0x7c00, 0x7c00, nil)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if err := x.Relocate(nil); err != nil {
+			t.Fatal(i, err)
+		}
+		b := new(bytes.Buffer)
+		if _, err := x.Write(b); err != nil {
+			t.Fatal(i, err)
+		}
+		if bytes.Compare(b.Bytes(), tt.want) != 0 {
+			t.Fatalf("%d: %s % x", i, x, b.Bytes())
+		}
+
+		// 逆アセンブルして同じ命令に戻ること
+		d, err := Decode(tt.want, tt.bits, 0x7c00)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if d.Length != len(tt.want) {
+			t.Fatal(i, d.Length)
+		}
+		y, err := NewX86(d.Form.Mnemonic, d.Operands, tt.bits, CPU386, 0x7c00, 0x7c00, nil)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if bytes.Compare(y.b, tt.want) != 0 {
+			t.Fatalf("%d: %s % x", i, y, y.b)
+		}
+	}
+}
+
+func TestNewX86_Error(t *testing.T) {
+
+	testCases := []struct {
+		mnemonic string
+		operands []Operand
+	}{
+		{"MOV", []Operand{AX, BL}},
+		{"MOV", []Operand{Memory{Base: BX}, Immediate{Value: expr.FromInt(1)}}},
+		{"MOV", []Operand{AL, Immediate{Value: expr.FromInt(0x100)}}},
+		{"MOV", []Operand{Memory{Base: EBX}, AX}},
+		{"JMP", []Operand{Immediate{Value: expr.FromInt(0x8000), Distance: DistanceShort}}},
+		{"LEA", []Operand{AX, BX}},
+	}
+
+	for i, tt := range testCases {
+		if _, err := NewX86(tt.mnemonic, tt.operands, 16, CPU386, 0, 0, nil); err == nil {
+			t.Fatal(i)
+		}
+	}
+}
+
+func TestNewX86_CPU(t *testing.T) {
+
+	imm := func(v int64) Operand { return Immediate{Value: expr.FromInt(v)} }
+	testCases := []struct {
+		cpu      CPU
+		mnemonic string
+		operands []Operand
+		want     []byte
+		err      string
+	}{
+		{CPU186, "PUSH", []Operand{imm(1)}, []byte{0x6a, 0x01}, ""},
+		{CPU8086, "PUSH", []Operand{imm(1)}, nil, "PUSH requires CPU 186"},
+		{CPU8086, "SHL", []Operand{AX, imm(1)}, []byte{0xd1, 0xe0}, ""},
+		{CPU8086, "SHL", []Operand{AX, imm(2)}, nil, "SHL requires CPU 186"},
+		{CPU8086, "JNZ", []Operand{Immediate{Value: expr.FromInt(0x100), Distance: DistanceNear}}, nil, "JNZ requires CPU 386"},
+		{CPU8086, "MOV", []Operand{EAX, imm(1)}, nil, "MOV requires CPU 386"},
+	}
+
+	for i, tt := range testCases {
+		x, err := NewX86(tt.mnemonic, tt.operands, 16, tt.cpu, 0, 0, nil)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatal(i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if bytes.Compare(x.b, tt.want) != 0 {
+			t.Fatalf("%d: % x", i, x.b)
+		}
+	}
+}
+
+func TestDecode_Undecodable(t *testing.T) {
+
+	testCases := [][]byte{
+		{0x0f, 0x0b},       // UD2は未対応
+		{0xb8, 0x00},       // 途中で終わっている
+		{0x26, 0x90},       // メモリオペランドの無い命令へのセグメントオーバーライド
+		{0x8d, 0xc0},       // LEAのオペランドがレジスタ
+		{0x8e, 0xf0},       // 存在しないセグメントレジスタ
+		{0x66, 0x66, 0x90}, // 重複したプレフィックス
+	}
+
+	for i, b := range testCases {
+		if d, err := Decode(b, 16, 0); err != ErrUndecodable {
+			t.Fatal(i, d, err)
+		}
+	}
+}
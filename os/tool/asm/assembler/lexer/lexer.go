@@ -2,10 +2,10 @@
 package lexer
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"unsafe"
 
 	"github.com/nanasi880/til/os/tool/asm/internal/runes"
@@ -32,62 +32,24 @@ func (t Token) Last() uint8 {
 //
 // @return 字句解析後のソースコード、エラー
 func Analyze(src io.Reader) (File, error) {
-
-	reader := bufio.NewReader(src)
-
-	result := make(File, 0)
-
-	// 適当なサイズで1行分のデータを確保するためのバッファを作成
-	line := make([]byte, 0, 1024)
-	for {
-		// データリセット
-		line = line[:0]
-
-	again:
-		// 読めるところまで読む
-		l, isPrefix, err := reader.ReadLine()
-		if err == io.EOF {
-			return result, nil
-		}
-		if err != nil {
-			return nil, err
-		}
-
-		// 今回の読み込みで取得出来たデータは次回のReadLine()呼び出しの時点でスライスが書き換わるのでディープコーピーした上で
-		// 後続データがあるなら引き続き読み込み
-		line = append(line, l...)
-		if isPrefix {
-			goto again
-		}
-
-		// 1行分のデータを解析
-		if line, err := analyzeLine(bytesToRunes(line)); err == nil {
-			if len(line) > 0 {
-				result = append(result, line)
-			}
-		} else {
-			return nil, err
-		}
-	}
+	file, _, err := AnalyzeWithLineNumbers(src)
+	return file, err
 }
 
-// 行単位での字句解析実行
-func analyzeLine(line []rune) (Line, error) {
-
-	// TAB文字は面倒なので空白に置換する
-	line = Clean(line)
-
-	// 空行は無視
-	if IsEmptyLine(line) {
-		return nil, nil
-	}
+// 字句解析を実行し、空行を取り除いた各行の元の行番号も返す
+//
+// @param src --- ソースコード
+//
+// @return 字句解析後のソースコード、各行の行番号(1始まり)、エラー
+func AnalyzeWithLineNumbers(src io.Reader) (File, []int, error) {
 
-	tokens, err := SplitToken(line)
+	// 全体を読み込んでから解析する トークンは読み込んだバッファと領域を共有する
+	b, err := ioutil.ReadAll(src)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return Line(tokens), nil
+	return Scan(b)
 }
 
 // Lexerが取り扱えるように行データをクリーンにする
@@ -108,6 +70,8 @@ func Clean(line []rune) []rune {
 // 文字列をカンマ区切りのトークン列だと仮定して分割する
 // ただし、最初のトークンは空白文字で区切られていると仮定される
 // カンマから次のトークンまでの余分な空白は無視される
+// トークン内の空白も無視されるが、単語同士を区切っている空白は1つの空白として残す (SHORT label など)
+// クォートされた部分はエスケープシーケンスも含めて元の表記のままトークンに含まれる
 //
 // この関数に渡す文字列はClean()でクリーニング済みである必要がある
 //
@@ -135,73 +99,45 @@ func SplitToken(s []rune) ([]Token, error) {
 
 	// ２つ目以降のトークンはカンマで区切られているはず
 	var (
-		quotation bool
-		token     = make([]rune, 0)
-		prev      rune
+		state quoteState
+		token = make([]rune, 0)
+		space bool // 直前がクォート外の空白だったかどうか
 	)
-	escape := func() bool {
-		return prev == '\\'
-	}
 	for i, c := range s {
 
-		switch c {
-
-		case '\\':
-			if !quotation {
-				token = append(token, c)
-				goto next
-			}
-
-			if escape() {
-				token = append(token, c)
-				c = 0
-			}
-
-		case '"':
-			if !escape() {
-				quotation = !quotation
-			}
-
+		if state.escape && !isEscapeChar(c) {
+			return nil, fmt.Errorf("invalid escape: %d", i)
+		}
+		if state.next(c) {
 			token = append(token, c)
+			space = false
+			continue
+		}
+		if space && c != ' ' && c != ',' && len(token) > 0 && isWordChar(token[len(token)-1]) && isWordChar(c) {
+			token = append(token, ' ')
+		}
+		space = c == ' '
+
+		switch c {
 
 		case ',':
-			if escape() {
-				return nil, fmt.Errorf("invalid escape: %d", i)
-			}
-			if quotation {
-				token = append(token, c)
-				goto next
-			} else {
-				if len(token) == 0 {
-					return nil, fmt.Errorf("empty token: %d", i)
-				}
-				result = append(result, Token(token))
-				quotation = false
-				token = token[:0]
+			if len(token) == 0 {
+				return nil, fmt.Errorf("empty token: %d", i)
 			}
+			result = append(result, Token(token))
+			token = token[:0]
 
 		case ' ':
-			if escape() {
-				return nil, fmt.Errorf("invalid escape: %d", i)
-			}
-			if quotation {
-				token = append(token, c)
-			}
+			// クォート外の空白は無視する
 
 		default:
-			if escape() {
-				return nil, fmt.Errorf("invalid escape: %d", i)
-			}
 			token = append(token, c)
 		}
-
-	next:
-		prev = c
 	}
 
 	// 最後まで読み切ったデータがあるならトークンとして処理する
 	if len(token) > 0 {
-		if quotation {
+		if state.quoted() {
 			// クォートが閉じられていない
 			return nil, errors.New("quotation isn't closed")
 		}
@@ -211,6 +147,12 @@ func SplitToken(s []rune) ([]Token, error) {
 	return result, nil
 }
 
+// 単語を構成する文字かどうか
+func isWordChar(c rune) bool {
+	return c == '_' || c == '.' || c == '$' || c == '@' || c == '?' ||
+		'0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
 // タブ文字を空白に置換する
 // ただし、クォートされている部分はスキップする
 //
@@ -220,43 +162,15 @@ func SplitToken(s []rune) ([]Token, error) {
 func ReplaceTab(line []rune) []rune {
 
 	var (
-		quotation bool
-		result    = make([]rune, 0, len(line))
-		prev      rune
+		state  quoteState
+		result = make([]rune, 0, len(line))
 	)
-	escape := func() bool {
-		return prev == '\\'
-	}
 	for _, c := range line {
 
-		switch c {
-
-		case '\\':
-			if !quotation {
-				goto next
-			}
-
-			if escape() {
-				result = append(result, c)
-				c = 0
-				goto next
-			}
-
-		case '"':
-			if !escape() {
-				quotation = !quotation
-			}
-
-		case '\t':
-			if !quotation {
-				c = ' '
-			}
+		if !state.next(c) && c == '\t' {
+			c = ' '
 		}
-
 		result = append(result, c)
-
-	next:
-		prev = c
 	}
 
 	return result
@@ -269,48 +183,15 @@ func ReplaceTab(line []rune) []rune {
 // @return コメントを除去した結果のデータ
 func TrimComment(line []rune) []rune {
 
-	var (
-		quotation bool
-		index     = -1
-		prev      rune
-	)
-	escape := func() bool {
-		return prev == '\\'
-	}
+	var state quoteState
 	for i, c := range line {
 
-		switch c {
-
-		case '\\':
-			if !quotation {
-				goto next
-			}
-
-			if escape() {
-				c = 0
-				goto next
-			}
-
-		case '"':
-			if !escape() {
-				quotation = !quotation
-			}
-
-		case ';':
-			if !quotation {
-				index = i
-				break
-			}
+		if !state.next(c) && c == ';' {
+			return line[:i]
 		}
-
-	next:
-		prev = c
 	}
 
-	if index < 0 {
-		return line
-	}
-	return line[:index]
+	return line
 }
 
 // 事実上空行とみなせるかどうかを調べる
@@ -342,9 +223,3 @@ func bytesAsString(b []byte) string {
 	}
 	return *(*string)(unsafe.Pointer(&b))
 }
-
-// バイトスライスを一旦文字列として読み替えた後
-// ルーンのスライスとしてallocし直して返却する
-func bytesToRunes(b []byte) []rune {
-	return []rune(bytesAsString(b))
-}
@@ -28,6 +28,26 @@ func TestSplitToken(t *testing.T) {
 			s:     `tok "Invalid Token\\\" \ \ "`,
 			wants: nil,
 		},
+		{
+			s:     "DB 'it''s', `a,\\`b`, \"x;y\"",
+			wants: []Token{"DB", `'it''s'`, "`a,\\`b`", `"x;y"`},
+		},
+		{
+			s:     `DB 'A' + 1, 1\2`,
+			wants: []Token{"DB", `'A'+1`, `1\2`},
+		},
+		{
+			s:     `JMP  SHORT   label + 2`,
+			wants: []Token{"JMP", "SHORT label+2"},
+		},
+		{
+			s:     `MOV WORD [ES: BX + 4], 0`,
+			wants: []Token{"MOV", "WORD[ES:BX+4]", "0"},
+		},
+		{
+			s:     `DB "unclosed`,
+			wants: nil,
+		},
 	}
 
 	for i, tt := range testCases {
@@ -84,3 +104,81 @@ func TestAnalyze(t *testing.T) {
 		t.Fatal(file)
 	}
 }
+
+func TestAnalyzeWithLineNumbers(t *testing.T) {
+
+	src := xtesting.MustOpen(t, "testdata/asm.txt")
+	defer xtesting.MustClose(t, src)
+
+	file, numbers, err := AnalyzeWithLineNumbers(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(file) != 2 || len(numbers) != 2 {
+		t.Fatal(file, numbers)
+	}
+	if numbers[0] != 3 || numbers[1] != 5 {
+		t.Fatal(numbers)
+	}
+}
+
+func TestReplaceTabAndTrimComment(t *testing.T) {
+
+	testCases := []struct {
+		s    string
+		want string
+	}{
+		{s: "DB\t1\t; comment", want: "DB 1 "},
+		{s: "DB\t\"\t;\\\"\"\t; comment", want: "DB \"\t;\\\"\" "},
+		{s: "DB\t'\t\"'\t; comment", want: "DB '\t\"' "},
+		{s: "DB\t1\\2 ; backslash", want: "DB 1\\2 "},
+	}
+
+	for i, tt := range testCases {
+		got := string(Clean([]rune(tt.s)))
+		if got != tt.want {
+			t.Fatalf("%d: %q", i, got)
+		}
+	}
+}
+
+func TestUnquote(t *testing.T) {
+
+	testCases := []struct {
+		tok   Token
+		wants []byte
+	}{
+		{tok: `"hello"`, wants: []byte("hello")},
+		{tok: `'A'`, wants: []byte("A")},
+		{tok: "`a\\n`", wants: []byte("a\n")},
+		{tok: `"\n\t\r\0\\\"\'"`, wants: []byte{'\n', '\t', '\r', 0, '\\', '"', '\''}},
+		{tok: `"\x41\xff\x7"`, wants: []byte{0x41, 0xFF, 0x07}},
+		{tok: `"\101\0123"`, wants: []byte{0x41, 0x0A, '3'}},
+		{tok: `"\u3042"`, wants: []byte("あ")},
+		{tok: `"\U0001F600"`, wants: []byte("\U0001F600")},
+		{tok: `"\x"`, wants: nil},
+		{tok: `"\u30"`, wants: nil},
+		{tok: `"\q"`, wants: nil},
+		{tok: `"\400"`, wants: nil},
+		{tok: `'A'+'B'`, wants: nil},
+		{tok: `A`, wants: nil},
+	}
+
+	for i, tt := range testCases {
+
+		b, err := Unquote(tt.tok)
+		if tt.wants == nil {
+			if err == nil {
+				t.Fatal(i, " ", b)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(i, " ", err)
+		}
+		if string(b) != string(tt.wants) {
+			t.Fatalf("%d: % X", i, b)
+		}
+	}
+}
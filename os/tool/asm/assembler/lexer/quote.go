@@ -0,0 +1,249 @@
+package lexer
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+)
+
+// クォートの状態を1文字ずつ追跡する
+// シングルクォート、ダブルクォート、バッククォートのいずれでも文字列を開始でき、
+// 開始したのと同じクォート文字でのみ閉じられる
+// クォート内のバックスラッシュは次の1文字をエスケープする
+// クォート外のバックスラッシュは通常の文字として扱われる
+type quoteState struct {
+	quote  rune // 現在開いているクォート文字 クォート外なら0
+	escape bool // 直前の文字がクォート内のエスケープ開始を表すバックスラッシュかどうか
+}
+
+// 状態を1文字分進める
+//
+// @param c --- 次の文字
+//
+// @return その文字がクォートされた文字列の一部かどうか 開始と終了のクォート文字自体もtrueとなる
+func (s *quoteState) next(c rune) bool {
+
+	if s.quote == 0 {
+		if isQuote(c) {
+			s.quote = c
+			return true
+		}
+		return false
+	}
+
+	switch {
+	case s.escape:
+		s.escape = false
+	case c == '\\':
+		s.escape = true
+	case c == s.quote:
+		s.quote = 0
+	}
+	return true
+}
+
+// クォートが開いたままかどうか
+func (s *quoteState) quoted() bool {
+	return s.quote != 0
+}
+
+// 文字列の開始/終了に使用できるクォート文字かどうか
+func isQuote(c rune) bool {
+	return c == '"' || c == '\'' || c == '`'
+}
+
+// バックスラッシュの直後に置くことができる文字かどうか
+func isEscapeChar(c rune) bool {
+	switch c {
+	case '\\', '"', '\'', '`', '?', 'a', 'b', 'e', 'f', 'n', 'r', 't', 'v', 'x', 'u', 'U':
+		return true
+	}
+	return '0' <= c && c <= '7'
+}
+
+// トークン全体が1つのクォートされた文字列かどうかを返す
+// 'A'+'B' のように複数の文字列を含む式はfalseとなる
+func (t Token) Quoted() bool {
+
+	if len(t) < 2 || !isQuote(rune(t[0])) {
+		return false
+	}
+
+	var state quoteState
+	for i, c := range t {
+		state.next(c)
+		if !state.quoted() {
+			return i == len(t)-1
+		}
+	}
+	return false
+}
+
+// クォートされた文字列トークンのエスケープシーケンスを解釈し、バイト列に変換する
+// 通常の文字と\u, \Uで指定された文字はUTF-8で、\x及び8進数で指定された値はそのままのバイトとして出力される
+//
+// 使用可能なエスケープシーケンス
+//
+//	\\ \" \' \` \?         その文字自体
+//	\a \b \e \f \n \r \t \v 制御文字
+//	\0 ~ \377              1~3桁の8進数
+//	\xNN                   1~2桁の16進数
+//	\uNNNN \UNNNNNNNN      Unicodeコードポイント
+//
+// @param t --- クォートされたトークン
+//
+// @return デコード後のバイト列、エラー
+func Unquote(t Token) ([]byte, error) {
+	return unquote(t, func(dst []byte, r rune) ([]byte, error) {
+		var buf [utf8.UTFMax]byte
+		n := utf8.EncodeRune(buf[:], r)
+		return append(dst, buf[:n]...), nil
+	})
+}
+
+// Unquoteと同様にエスケープシーケンスを解釈し、文字を指定したエンコーダーで変換したバイト列を返す
+// \x及び8進数で指定された値は変換されずにそのままのバイトとして出力される
+//
+// @param t   --- クォートされたトークン
+// @param enc --- エンコーダー
+//
+// @return デコード後のバイト列、エラー 指定した文字コードで表現できない文字が含まれている場合もエラーとなる
+func UnquoteWith(t Token, enc *encoding.Encoder) ([]byte, error) {
+	return unquote(t, func(dst []byte, r rune) ([]byte, error) {
+		b, err := enc.Bytes([]byte(string(r)))
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", r, err)
+		}
+		return append(dst, b...), nil
+	})
+}
+
+// Unquoteの実装
+//
+// @param t      --- クォートされたトークン
+// @param encode --- 文字をバイト列としてdstに追加する関数
+//
+// @return デコード後のバイト列、エラー
+func unquote(t Token, encode func(dst []byte, r rune) ([]byte, error)) ([]byte, error) {
+
+	if !t.Quoted() {
+		return nil, fmt.Errorf("not a quoted string: %s", t)
+	}
+
+	var (
+		s      = []rune(string(t[1 : len(t)-1]))
+		result = make([]byte, 0, len(s))
+		err    error
+	)
+	for i := 0; i < len(s); i++ {
+
+		c := s[i]
+		if c != '\\' {
+			if result, err = encode(result, c); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		i++
+		if i >= len(s) {
+			return nil, errors.New("invalid escape: trailing backslash")
+		}
+
+		switch c = s[i]; c {
+
+		case '\\', '"', '\'', '`', '?':
+			result = append(result, byte(c))
+		case 'a':
+			result = append(result, 0x07)
+		case 'b':
+			result = append(result, 0x08)
+		case 'e':
+			result = append(result, 0x1B)
+		case 'f':
+			result = append(result, 0x0C)
+		case 'n':
+			result = append(result, 0x0A)
+		case 'r':
+			result = append(result, 0x0D)
+		case 't':
+			result = append(result, 0x09)
+		case 'v':
+			result = append(result, 0x0B)
+
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			v, n := parseDigits(s[i:], 8, 3)
+			if v > 0xFF {
+				return nil, fmt.Errorf("invalid escape: \\%s out of range", string(s[i:i+n]))
+			}
+			result = append(result, byte(v))
+			i += n - 1
+
+		case 'x':
+			v, n := parseDigits(s[i+1:], 16, 2)
+			if n == 0 {
+				return nil, errors.New("invalid escape: \\x requires hex digits")
+			}
+			result = append(result, byte(v))
+			i += n
+
+		case 'u', 'U':
+			digits := 4
+			if c == 'U' {
+				digits = 8
+			}
+			v, n := parseDigits(s[i+1:], 16, digits)
+			if n != digits {
+				return nil, fmt.Errorf("invalid escape: \\%c requires %d hex digits", c, digits)
+			}
+			if v > utf8.MaxRune || (0xD800 <= v && v <= 0xDFFF) {
+				return nil, fmt.Errorf("invalid escape: \\%c%0*X isn't a valid code point", c, digits, v)
+			}
+			if result, err = encode(result, rune(v)); err != nil {
+				return nil, err
+			}
+			i += n
+
+		default:
+			return nil, fmt.Errorf("invalid escape: \\%c", c)
+		}
+	}
+
+	return result, nil
+}
+
+// 先頭から最大max桁の数字を読み取る
+//
+// @param s    --- 対象文字列
+// @param base --- 基数 8 or 16
+// @param max  --- 最大桁数
+//
+// @return 値、読み取った桁数
+func parseDigits(s []rune, base int, max int) (uint32, int) {
+
+	var v uint32
+	n := 0
+	for ; n < max && n < len(s); n++ {
+		d := digitValue(s[n])
+		if d < 0 || d >= base {
+			break
+		}
+		v = v*uint32(base) + uint32(d)
+	}
+	return v, n
+}
+
+// 数字1文字の値を返す 数字でなければ-1を返す
+func digitValue(c rune) int {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c - '0')
+	case 'a' <= c && c <= 'f':
+		return int(c-'a') + 10
+	case 'A' <= c && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return -1
+}
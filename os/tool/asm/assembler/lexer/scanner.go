@@ -0,0 +1,339 @@
+package lexer
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 置換文字(U+FFFD)のUTF-8表現 UTF-8として不正なバイトはこれに置き換えられる
+var replacementChar = []byte(string(utf8.RuneError))
+
+// クォート外のタブを置き換えた空白
+var spaceChar = []byte{' '}
+
+// ソースコード全体を1パスで字句解析する
+// ReplaceTab(), TrimComment(), IsEmptyLine(), SplitToken()を順に適用した場合と同じ結果になるが、
+// 行毎に[]runeへ変換せず、バイト列のまま処理する
+// ASCII以外の文字が現れた箇所だけUTF-8としてデコードする
+//
+// 返却されるトークンは可能な限りsrcと領域を共有する文字列となるため、
+// この関数を呼び出した後にsrcを書き換えてはならない
+//
+// @param src --- ソースコード
+//
+// @return 字句解析後のソースコード、各行の行番号(1始まり)、エラー
+func Scan(src []byte) (File, []int, error) {
+
+	var (
+		s       = scanner{src: src, text: bytesAsString(src)}
+		result  = make(File, 0)
+		numbers []int
+		number  = 0
+	)
+	for start := 0; start < len(src); {
+
+		// bufio.Reader.ReadLine()と同様に "\n" と "\r\n" を行末とする
+		end := start
+		for end < len(src) && src[end] != '\n' {
+			end++
+		}
+		next := end + 1
+		if end < len(src) && end > start && src[end-1] == '\r' {
+			end--
+		}
+		number++
+
+		line, err := s.line(start, end)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(line) > 0 {
+			result = append(result, line)
+			numbers = append(numbers, number)
+		}
+		start = next
+	}
+
+	return result, numbers, nil
+}
+
+// 字句解析の作業領域
+type scanner struct {
+	src  []byte
+	text string // srcと領域を共有する文字列
+	tok  tokenBuilder
+}
+
+// 1行分の字句解析を行う
+//
+// @param start --- 行の先頭のインデックス
+// @param end   --- 行末のインデックス 改行文字は含まない
+//
+// @return トークンの一覧 空行の場合はnil、エラー
+func (s *scanner) line(start, end int) (Line, error) {
+
+	const (
+		leading = iota // 行頭の空白
+		first          // 1つ目のトークン
+		rest           // 2つ目以降のトークン
+	)
+
+	var (
+		src      = s.src
+		tok      = &s.tok
+		phase    = leading
+		nonBlank = false // 空白以外の文字があったかどうか
+		clean    quoteState
+		state    quoteState
+		space    bool // 直前がクォート外の空白だったかどうか
+		split    int  // 1つ目のトークンの後の空白のインデックス
+		result   Line
+	)
+	tok.reset()
+
+	for i := start; i < end; {
+
+		c, size := rune(src[i]), 1
+		if c >= utf8.RuneSelf {
+			c, size = utf8.DecodeRune(src[i:end])
+		}
+		var subst []byte
+		if c == utf8.RuneError && size == 1 {
+			subst = replacementChar
+		}
+
+		// Clean()相当 クォートの状態は行頭から追跡する
+		before := clean
+		if !clean.next(c) {
+			if c == ';' {
+				break
+			}
+			if c == '\t' {
+				c, subst = ' ', spaceChar
+			}
+		}
+		if c != ' ' {
+			nonBlank = true
+		}
+
+		switch phase {
+
+		case leading:
+			if isSpace(c) {
+				i += size
+				continue
+			}
+			phase = first
+			fallthrough
+
+		case first:
+			if c != ' ' {
+				tok.add(src, i, size, subst, !isSpace(c))
+				i += size
+				continue
+			}
+			// １つ目のトークンは空白で区切られているはず
+			result = append(result, tok.token(s.text))
+			tok.reset()
+			phase, split = rest, i
+		}
+
+		// ２つ目以降のトークンはカンマで区切られているはず
+		if state.escape && !isEscapeChar(c) {
+			// 行末の空白の中であればSplitToken()に渡る前に取り除かれている
+			if blankToEnd(src[i:end], before) {
+				break
+			}
+			return nil, fmt.Errorf("invalid escape: %d", utf8.RuneCount(src[split:i]))
+		}
+		if state.next(c) {
+			tok.add(src, i, size, subst, !isSpace(c))
+			space = false
+			i += size
+			continue
+		}
+		if space && c != ' ' && c != ',' && tok.len() > 0 && isWordChar(rune(tok.last(src))) && isWordChar(c) {
+			if src[i-1] == ' ' {
+				tok.add(src, i-1, 1, nil, false)
+			} else {
+				tok.add(src, i-1, 1, spaceChar, false)
+			}
+		}
+		space = c == ' '
+
+		switch c {
+
+		case ',':
+			if tok.len() == 0 {
+				return nil, fmt.Errorf("empty token: %d", utf8.RuneCount(src[split:i]))
+			}
+			result = append(result, tok.token(s.text))
+			tok.reset()
+
+		case ' ':
+			// クォート外の空白は無視する
+
+		default:
+			tok.add(src, i, size, subst, !isSpace(c))
+		}
+		i += size
+	}
+
+	// 空行は無視
+	if !nonBlank {
+		return nil, nil
+	}
+
+	// 行末の空白は捨てる
+	tok.trim()
+
+	switch phase {
+
+	case leading:
+		// 空白文字だけの行 SplitToken()は空のトークンを1つ返す
+		return Line{""}, nil
+
+	case first:
+		return append(result, tok.token(s.text)), nil
+	}
+
+	if tok.len() > 0 {
+		if state.quoted() {
+			// クォートが閉じられていない
+			return nil, errors.New("quotation isn't closed")
+		}
+		return append(result, tok.token(s.text)), nil
+	}
+	if len(result) == 1 {
+		// 1つ目のトークンの後が空白だけなら、1つ目のトークンの末尾の空白も取り除かれる
+		result[0] = trimRightSpace(result[0])
+	}
+	return result, nil
+}
+
+// 行の残りが全て空白(コメントを含む)かどうか
+//
+// @param b     --- 行の残り
+// @param clean --- 行頭からのクォートの状態
+func blankToEnd(b []byte, clean quoteState) bool {
+
+	for _, c := range bytesAsString(b) {
+		if !clean.next(c) && c == ';' {
+			return true
+		}
+		if !isSpace(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// 空白文字かどうか runes.TrimSpace()と同じ判定を行う
+func isSpace(c rune) bool {
+	if c < utf8.RuneSelf {
+		return c == ' ' || '\t' <= c && c <= '\r'
+	}
+	return unicode.IsSpace(c)
+}
+
+// トークンの末尾の空白文字を取り除く
+func trimRightSpace(t Token) Token {
+
+	end := len(t)
+	for end > 0 {
+		c, size := utf8.DecodeLastRuneInString(string(t[:end]))
+		if !isSpace(c) {
+			break
+		}
+		end -= size
+	}
+	return t[:end]
+}
+
+// トークンを組み立てる
+// ソースコード上で連続している間は範囲だけを記録し、空白の除去などで連続しなくなった時点でバッファへコピーする
+type tokenBuilder struct {
+	start, end int    // ソースコード上の範囲
+	buf        []byte // 連続しなくなった後のトークン
+	copied     bool   // bufを使用しているかどうか
+	keep       int    // 末尾の空白を除いた長さ
+}
+
+// 空のトークンに戻す
+func (t *tokenBuilder) reset() {
+	t.start, t.end = 0, 0
+	t.buf = t.buf[:0]
+	t.copied = false
+	t.keep = 0
+}
+
+// トークンの長さ
+func (t *tokenBuilder) len() int {
+	if t.copied {
+		return len(t.buf)
+	}
+	return t.end - t.start
+}
+
+// トークンの末尾のバイト
+//
+// @param src --- ソースコード
+func (t *tokenBuilder) last(src []byte) byte {
+	if t.copied {
+		return t.buf[len(t.buf)-1]
+	}
+	return src[t.end-1]
+}
+
+// 1文字追加する
+//
+// @param src     --- ソースコード
+// @param pos     --- 文字のインデックス
+// @param size    --- 文字のバイト数
+// @param subst   --- ソースコードと異なる文字を追加する場合はその表現 そのままならnil
+// @param visible --- 空白文字以外かどうか
+func (t *tokenBuilder) add(src []byte, pos, size int, subst []byte, visible bool) {
+
+	if !t.copied {
+		switch {
+		case subst == nil && t.start == t.end:
+			t.start, t.end = pos, pos+size
+		case subst == nil && t.end == pos:
+			t.end += size
+		default:
+			t.buf = append(t.buf[:0], src[t.start:t.end]...)
+			t.copied = true
+		}
+	}
+	if t.copied {
+		if subst == nil {
+			subst = src[pos : pos+size]
+		}
+		t.buf = append(t.buf, subst...)
+	}
+	if visible {
+		t.keep = t.len()
+	}
+}
+
+// 末尾の空白を取り除く
+func (t *tokenBuilder) trim() {
+	if t.copied {
+		t.buf = t.buf[:t.keep]
+	} else {
+		t.end = t.start + t.keep
+	}
+}
+
+// トークンを返す
+// ソースコード上で連続していればtextの部分文字列を返すため、アロケーションは発生しない
+//
+// @param text --- ソースコード
+func (t *tokenBuilder) token(text string) Token {
+	if t.copied {
+		return Token(t.buf)
+	}
+	return Token(text[t.start:t.end])
+}
@@ -0,0 +1,206 @@
+package lexer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// 1パスのScan()に置き換える前の実装
+// ReadLine()で読み込んだ行を[]runeに変換し、Clean(), IsEmptyLine(), SplitToken()を順に適用する
+func analyzeReference(src io.Reader) (File, []int, error) {
+
+	reader := bufio.NewReader(src)
+
+	var (
+		result  = make(File, 0)
+		numbers []int
+		number  = 0
+	)
+
+	line := make([]byte, 0, 1024)
+	for {
+		line = line[:0]
+
+	again:
+		l, isPrefix, err := reader.ReadLine()
+		if err == io.EOF {
+			return result, numbers, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		line = append(line, l...)
+		if isPrefix {
+			goto again
+		}
+		number++
+
+		cleaned := Clean([]rune(string(line)))
+		if IsEmptyLine(cleaned) {
+			continue
+		}
+		tokens, err := SplitToken(cleaned)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(tokens) > 0 {
+			result = append(result, tokens)
+			numbers = append(numbers, number)
+		}
+	}
+}
+
+// Scan()とanalyzeReference()の結果が一致することを確認する
+func compareScan(t *testing.T, src []byte) {
+
+	t.Helper()
+
+	wantFile, wantNumbers, wantErr := analyzeReference(bytes.NewReader(src))
+	gotFile, gotNumbers, gotErr := Scan(append([]byte(nil), src...))
+
+	if fmt.Sprint(wantErr) != fmt.Sprint(gotErr) {
+		t.Fatalf("%q: error %v, want %v", src, gotErr, wantErr)
+	}
+	if !reflect.DeepEqual(wantFile, gotFile) || !reflect.DeepEqual(wantNumbers, gotNumbers) {
+		t.Fatalf("%q:\n got  %q %v\n want %q %v", src, gotFile, gotNumbers, wantFile, wantNumbers)
+	}
+}
+
+// ランダムな変異で見つかった入力のうち、境界条件を突いたものを集めたコーパス
+// 1行に1つ、strconv.Quote()した形式で記述する
+func TestScan_Corpus(t *testing.T) {
+
+	b, err := ioutil.ReadFile("testdata/scan_corpus.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, line := range strings.Split(string(b), "\n") {
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		src, err := strconv.Unquote(line)
+		if err != nil {
+			t.Fatalf("scan_corpus.txt:%d: %v", i+1, err)
+		}
+		compareScan(t, []byte(src))
+	}
+}
+
+// アセンブラのテストデータを変異させた入力で、Scan()とanalyzeReference()の結果が一致すること
+func TestScan_Random(t *testing.T) {
+
+	seeds := scanSeeds(t)
+
+	// トークンの区切りやクォート、UTF-8の境界に関わる文字を多めに混ぜる
+	alphabet := []string{
+		" ", "\t", ",", ";", "\"", "'", "`", "\\", "\r", "\n", "\r\n", "\v",
+		"a", "Z", "1", "_", ".", "$", "+", "[", "]", ":", "%",
+		"\x80", "\xc2", "\xc2\xa0", "\xe3\x81\x82", "\xe3\x80\x80", "\xef\xbf\xbd", "\xff",
+	}
+
+	n := 20000
+	if testing.Short() {
+		n = 2000
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < n; i++ {
+
+		src := []byte(seeds[rnd.Intn(len(seeds))])
+		for m := rnd.Intn(8); m >= 0; m-- {
+			pos := 0
+			if len(src) > 0 {
+				pos = rnd.Intn(len(src))
+			}
+			switch rnd.Intn(3) {
+			case 0:
+				s := alphabet[rnd.Intn(len(alphabet))]
+				src = append(src[:pos], append([]byte(s), src[pos:]...)...)
+			case 1:
+				if len(src) > 0 {
+					src = append(src[:pos], src[pos+1:]...)
+				}
+			case 2:
+				if len(src) > 0 {
+					src[pos] = byte(rnd.Intn(256))
+				}
+			}
+		}
+		compareScan(t, src)
+	}
+}
+
+// 変異の元となる入力 テストデータの各行と、数行ずつのまとまり
+func scanSeeds(t testing.TB) []string {
+
+	files, err := filepath.Glob("../testdata/*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, "testdata/asm.txt")
+
+	var seeds []string
+	for _, name := range files {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.SplitAfter(string(b), "\n")
+		seeds = append(seeds, lines...)
+		for i := 0; i+3 <= len(lines); i += 3 {
+			seeds = append(seeds, strings.Join(lines[i:i+3], ""))
+		}
+	}
+	return seeds
+}
+
+// ベンチマーク用の大きなソースコード
+func benchmarkSource(b *testing.B) []byte {
+
+	var src bytes.Buffer
+	for _, s := range scanSeeds(b) {
+		src.WriteString(s)
+	}
+	for src.Len() < 1<<20 {
+		src.Write(src.Bytes())
+	}
+	return src.Bytes()
+}
+
+func BenchmarkAnalyze(b *testing.B) {
+
+	src := benchmarkSource(b)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Analyze(bytes.NewReader(src)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAnalyze_Reference(b *testing.B) {
+
+	src := benchmarkSource(b)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, _, err := analyzeReference(bytes.NewReader(src)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package assembler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler/lexer"
+)
+
+// マクロ
+//
+//	%macro PUTS 1
+//	        MOV     SI, %1
+//	%%loop:
+//	        LODSB
+//	        ...
+//	        JMP     %%loop
+//	%endmacro
+//
+// 本体の %1, %2... は呼び出し時のパラメーターに、%%で始まるラベルは展開毎に異なる名前に置換される
+type macro struct {
+	name       string        // マクロ名
+	parameters int           // パラメーターの数
+	body       *analyzedFile // 本体 行番号は定義されたファイル上のもの
+}
+
+// マクロの呼び出し
+// ソースマップで、展開された行がどこから呼び出されたかを示すために使う
+type macroCall struct {
+	name   string     // マクロ名
+	pos    position   // 呼び出した位置
+	parent *macroCall // 外側のマクロの呼び出し 展開中でなければnil
+}
+
+// 既定のマクロ展開のネストの深さの上限
+const defaultMaxMacroDepth = 64
+
+// 行がマクロの定義の一部であれば処理する
+//
+// @param line --- 1行分のデータ
+//
+// @return 処理したかどうか、エラー
+func (a *assembly) defineMacro(line lexer.Line) (bool, error) {
+
+	directive := strings.ToLower(string(line[0]))
+
+	if a.defining == nil {
+		switch directive {
+		case "%macro":
+		case "%endmacro":
+			return true, a.error(fmt.Errorf("%%endmacroに対応する%%macroが無い"))
+		default:
+			return false, nil
+		}
+
+		var fields []string
+		if len(line) == 2 {
+			fields = strings.Fields(string(line[1]))
+		}
+		if len(fields) != 2 {
+			return true, a.error(fmt.Errorf("%%macroにはマクロ名とパラメーターの数が必要"))
+		}
+		n, err := strconv.Atoi(fields[1])
+		if err != nil || n < 0 {
+			return true, a.error(fmt.Errorf("マクロのパラメーターの数が不正: %s", fields[1]))
+		}
+		if _, ok := a.macros[fields[0]]; ok {
+			return true, a.error(fmt.Errorf("マクロ %s は既に定義されています", fields[0]))
+		}
+
+		a.defining = &macro{
+			name:       fields[0],
+			parameters: n,
+			body:       &analyzedFile{name: a.fileName},
+		}
+		a.definedAt = position{file: a.fileName, line: a.sourceLineNumber}
+		return true, nil
+	}
+
+	switch directive {
+	case "%macro":
+		return true, a.error(fmt.Errorf("マクロの定義の中でマクロは定義できない"))
+	case "%endmacro":
+		if a.macros == nil {
+			a.macros = make(map[string]*macro)
+		}
+		a.macros[a.defining.name] = a.defining
+		a.defining = nil
+		return true, nil
+	}
+
+	a.defining.body.lines = append(a.defining.body.lines, line)
+	a.defining.body.numbers = append(a.defining.body.numbers, a.sourceLineNumber)
+	return true, nil
+}
+
+// マクロを展開してアセンブルする
+//
+// @param m          --- マクロ
+// @param parameters --- パラメーター
+//
+// @return エラー
+func (a *assembly) expandMacro(m *macro, parameters []lexer.Token) error {
+
+	if len(parameters) != m.parameters {
+		return fmt.Errorf("マクロ %s には%d個のパラメーターが必要", m.name, m.parameters)
+	}
+
+	limit := a.config.limits.MaxMacroDepth
+	if limit <= 0 {
+		limit = defaultMaxMacroDepth
+	}
+	if a.macroDepth >= limit {
+		return fmt.Errorf("%w: %s (limit %d)", ErrMacroDepthLimit, m.name, limit)
+	}
+
+	a.expansions++
+	local := fmt.Sprintf("..@%d.", a.expansions)
+
+	expanded := &analyzedFile{
+		name:    m.body.name,
+		lines:   make(lexer.File, len(m.body.lines)),
+		numbers: m.body.numbers,
+	}
+	for i, line := range m.body.lines {
+		tokens := make(lexer.Line, len(line))
+		for j, tok := range line {
+			tokens[j] = substitute(tok, parameters, local)
+		}
+		expanded.lines[i] = tokens
+	}
+
+	a.macroDepth++
+	a.expansion = &macroCall{name: m.name, pos: position{file: a.fileName, line: a.sourceLineNumber}, parent: a.expansion}
+	defer func() {
+		a.macroDepth--
+		a.expansion = a.expansion.parent
+	}()
+	return a.source(expanded)
+}
+
+// マクロ本体のトークンのパラメーターとローカルラベルを置換する
+// クォートされた文字列の中は置換しない
+// %%の後にシンボル名の先頭の文字が続かない場合は、ローカルラベルではなく符号付き剰余の演算子とみなす
+//
+// @param tok        --- トークン
+// @param parameters --- パラメーター
+// @param local      --- ローカルラベルの接頭辞
+//
+// @return 置換後のトークン
+func substitute(tok lexer.Token, parameters []lexer.Token, local string) lexer.Token {
+
+	s := string(tok)
+	if !strings.Contains(s, "%") {
+		return tok
+	}
+
+	var (
+		b     strings.Builder
+		quote byte
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && i+1 < len(s) {
+				b.WriteByte(c)
+				i++
+				c = s[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '%' && i+2 < len(s) && s[i+1] == '%' && isLocalLabelStart(s[i+2]):
+			b.WriteString(local)
+			i++
+			continue
+		case c == '%' && i+1 < len(s) && '1' <= s[i+1] && s[i+1] <= '9':
+			end := i + 1
+			for end < len(s) && '0' <= s[end] && s[end] <= '9' {
+				end++
+			}
+			if n, _ := strconv.Atoi(s[i+1 : end]); n <= len(parameters) {
+				b.WriteString(string(parameters[n-1]))
+				i = end - 1
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+	return lexer.Token(b.String())
+}
+
+// ローカルラベルの%%に続く、シンボル名の先頭に使用できる文字かどうか
+// $は%%$のように演算子と$の組み合わせと区別できないので含めない
+func isLocalLabelStart(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_' || c == '.' || c == '?' || c == '@'
+}
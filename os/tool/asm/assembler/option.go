@@ -0,0 +1,192 @@
+package assembler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/text/encoding"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler/instruction"
+)
+
+// アセンブラのオプション
+type Option func(a *Assembler)
+
+// 命令配置基準位置の初期値を指定する ソースコードのORG命令で上書きされる
+func WithOrigin(origin int64) Option {
+	return func(a *Assembler) {
+		a.origin = origin
+	}
+}
+
+// 機械語命令のモードの初期値を指定する ソースコードのBITS命令で上書きされる
+//
+// @param bits --- 16 or 32
+func WithBits(bits int) Option {
+	return func(a *Assembler) {
+		a.bits = bits
+	}
+}
+
+// 使用できる命令のCPUの初期値を指定する ソースコードのCPU命令で上書きされる
+// 指定したCPUより新しいCPUの命令はエラーになる
+//
+// @param cpu --- instruction.CPU8086, CPU186 or CPU386
+func WithCPU(cpu instruction.CPU) Option {
+	return func(a *Assembler) {
+		a.cpu = cpu
+	}
+}
+
+// 式の中で参照できるシンボルを事前に定義する
+//
+// @param name  --- シンボル名
+// @param value --- 値
+func WithDefine(name string, value int64) Option {
+	return func(a *Assembler) {
+		if a.defines == nil {
+			a.defines = make(map[string]int64)
+		}
+		a.defines[name] = value
+	}
+}
+
+// INCLUDE命令でファイルを読み込むファイルシステムを指定する
+func WithIncludeFS(fs FileSystem) Option {
+	return func(a *Assembler) {
+		a.includeFS = fs
+	}
+}
+
+// 出力形式を指定する
+func WithOutputFormat(format Format) Option {
+	return func(a *Assembler) {
+		a.format = format
+	}
+}
+
+// FormatGoで出力するパッケージ名と変数名を指定する
+// 指定しない場合はpackage mainのimageとなる
+//
+// @param pkg  --- パッケージ名
+// @param name --- []byte型の変数名
+func WithGoSource(pkg, name string) Option {
+	return func(a *Assembler) {
+		a.goPackage = pkg
+		a.goName = name
+	}
+}
+
+// 出力するイメージの検査を指定する
+// 見つかった問題は警告として診断メッセージに追加される
+func WithChecks(checks Check) Option {
+	return func(a *Assembler) {
+		a.checks = checks
+	}
+}
+
+// フラットバイナリの出力先がシーク可能な場合に、大きなRESBの領域を書き込まずにシークで飛ばす
+// 出力先の末尾より後ろの領域だけを飛ばすので、既存の内容は書き換えた範囲以外そのまま残る
+// 出力のために新しく作成したファイルに対して使用する
+func WithSparseOutput() Option {
+	return func(a *Assembler) {
+		a.sparse = true
+	}
+}
+
+// アセンブルの制限を指定する
+func WithLimits(limits Limits) Option {
+	return func(a *Assembler) {
+		a.limits = limits
+	}
+}
+
+// ソースコードの文字コードを指定する
+//
+// @param e --- 文字コード nilならUTF-8
+func WithInputEncoding(e encoding.Encoding) Option {
+	return func(a *Assembler) {
+		a.inputEncoding = e
+	}
+}
+
+// DB命令の文字列リテラルをバイナリに出力する際の文字コードを指定する
+//
+// @param e --- 文字コード nilならUTF-8
+func WithStringEncoding(e encoding.Encoding) Option {
+	return func(a *Assembler) {
+		a.stringEncoding = e
+	}
+}
+
+// 出力形式
+type Format int
+
+const (
+	FormatBinary   Format = iota // フラットバイナリ
+	FormatIntelHex               // Intel HEX ORGで指定したアドレスに配置する
+	FormatSRecord                // Motorola S-record ORGで指定したアドレスに配置する
+	FormatGo                     // []byte型の変数を宣言するGoのソースコード
+)
+
+// 出力形式の名前 String()とParseFormat()で使用する
+var formatNames = []string{
+	FormatBinary:   "bin",
+	FormatIntelHex: "ihex",
+	FormatSRecord:  "srec",
+	FormatGo:       "go",
+}
+
+func (f Format) String() string {
+	if 0 <= f && int(f) < len(formatNames) {
+		return formatNames[f]
+	}
+	return "unknown"
+}
+
+// 名前から出力形式を取得する
+//
+// @param name --- bin, ihex, srec, go のいずれか
+//
+// @return 出力形式、エラー
+func ParseFormat(name string) (Format, error) {
+	for f, s := range formatNames {
+		if s == name {
+			return Format(f), nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported output format: %s", name)
+}
+
+// アセンブルの制限
+// MaxMacroDepth以外は0なら制限しない
+type Limits struct {
+	MaxOutputSize int64 // 出力するバイト数の上限 超えた場合はErrOutputSizeLimit
+	MaxLines      int   // アセンブルする行数の上限 INCLUDEしたファイルや展開したマクロの行も含む 超えた場合はErrLineLimit
+	MaxMacroDepth int   // マクロ展開のネストの深さの上限 0なら64 超えた場合はErrMacroDepthLimit
+}
+
+// アセンブルの制限を超えた場合のエラー
+var (
+	ErrOutputSizeLimit = errors.New("output size limit exceeded")
+	ErrLineLimit       = errors.New("line limit exceeded")
+	ErrMacroDepthLimit = errors.New("macro expansion depth limit exceeded")
+)
+
+// INCLUDE命令でファイルを読み込むためのファイルシステム
+type FileSystem interface {
+	// ファイルを開く
+	//
+	// @param name --- INCLUDE命令で指定されたファイル名 区切り文字は/
+	Open(name string) (io.ReadCloser, error)
+}
+
+// ディレクトリをルートとしたファイルシステム
+type DirFS string
+
+func (d DirFS) Open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(d), filepath.FromSlash(name)))
+}
@@ -0,0 +1,77 @@
+package assembler
+
+import "fmt"
+
+// アセンブル結果
+type Result struct {
+	Size        int64            // 出力したバイト数
+	Symbols     map[string]int64 // ラベルの名前:アドレスの対応表 EQUの定数も含む
+	Constants   map[string]bool  // Symbolsのうち、EQUで定義した定数の名前
+	Diagnostics []Diagnostic     // 診断メッセージ
+	Sources     []SourceRange    // 出力の各範囲を出力したソースコード上の位置 オフセット順
+	SourceMap   *SourceMap       // アドレスとソースコード上の位置の対応表 アセンブルに失敗した場合はnil
+}
+
+// 出力の範囲とソースコード上の位置の対応
+// 範囲の終わりは次の要素のOffset、最後の要素なら出力の末尾
+type SourceRange struct {
+	Offset int64  // 範囲の開始位置 出力の先頭からのオフセット
+	File   string // ファイル名 メインのソースコードなら空
+	Line   int    // 行番号
+}
+
+// 診断メッセージの重大度
+type Severity int
+
+const (
+	SeverityError   Severity = iota + 1 // エラー
+	SeverityWarning                     // 警告
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return "unknown"
+}
+
+// 診断メッセージ
+type Diagnostic struct {
+	File     string   // ファイル名 メインのソースコードなら空
+	Line     int      // 行番号 位置を持たない場合は0
+	Severity Severity // 重大度
+	Message  string   // メッセージ
+}
+
+func (d Diagnostic) String() string {
+	switch {
+	case d.Line == 0:
+		return fmt.Sprintf("%s: %s", d.Severity, d.Message)
+	case d.File == "":
+		return fmt.Sprintf("%s:%d %s", d.Severity, d.Line, d.Message)
+	}
+	return fmt.Sprintf("%s:%s:%d %s", d.Severity, d.File, d.Line, d.Message)
+}
+
+// ソースコード上の位置を持つエラー
+type Error struct {
+	File string // ファイル名 メインのソースコードなら空
+	Line int    // 行番号
+	Err  error  // 原因
+}
+
+func (e *Error) Error() string {
+	return e.Diagnostic().String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// 診断メッセージに変換する
+func (e *Error) Diagnostic() Diagnostic {
+	return Diagnostic{File: e.File, Line: e.Line, Severity: SeverityError, Message: e.Err.Error()}
+}
@@ -0,0 +1,165 @@
+package assembler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// アドレスとソースコード上の位置の対応表
+// エミュレーターやデバッガーで、実行中のアドレスがどの行から出力されたかを調べるために使う
+type SourceMap struct {
+	Origin  int64            `json:"origin"`  // 先頭のアドレス
+	Size    int64            `json:"size"`    // 出力のバイト数
+	Entries []SourceMapEntry `json:"entries"` // アドレス順の各範囲
+}
+
+// ソースマップの1つの範囲
+// 出力した命令毎に、命令の中で出力した行が変わる位置で分割したもの
+type SourceMapEntry struct {
+	Address   int64       `json:"address"`             // 開始アドレス
+	Size      int64       `json:"size"`                // バイト数
+	File      string      `json:"file"`                // ファイル名 メインのソースコードなら空
+	Line      int         `json:"line"`                // 行番号 マクロの本体なら定義された位置
+	Expansion []MacroCall `json:"expansion,omitempty"` // 範囲を出力したマクロの呼び出し 内側から順
+}
+
+// ソースマップに記録するマクロの呼び出し
+type MacroCall struct {
+	Macro string `json:"macro"` // マクロ名
+	File  string `json:"file"`  // 呼び出したファイル名 メインのソースコードなら空
+	Line  int    `json:"line"`  // 呼び出した行番号
+}
+
+// ソースマップの出力形式
+type SourceMapFormat int
+
+const (
+	SourceMapJSON SourceMapFormat = iota // JSON
+	SourceMapText                        // 1行に1つの範囲を "アドレス ファイル名:行番号" で表したテキスト
+)
+
+// ソースマップの出力形式の名前 String()とParseSourceMapFormat()で使用する
+var sourceMapFormatNames = []string{
+	SourceMapJSON: "json",
+	SourceMapText: "text",
+}
+
+func (f SourceMapFormat) String() string {
+	if 0 <= f && int(f) < len(sourceMapFormatNames) {
+		return sourceMapFormatNames[f]
+	}
+	return "unknown"
+}
+
+// 名前からソースマップの出力形式を取得する
+//
+// @param name --- json, text のいずれか
+//
+// @return 出力形式、エラー
+func ParseSourceMapFormat(name string) (SourceMapFormat, error) {
+	for f, s := range sourceMapFormatNames {
+		if s == name {
+			return SourceMapFormat(f), nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported source map format: %s", name)
+}
+
+// ソースマップを出力する
+//
+// @param w      --- 出力先
+// @param format --- 出力形式
+//
+// @return エラー
+func (m *SourceMap) Write(w io.Writer, format SourceMapFormat) error {
+
+	switch format {
+	case SourceMapJSON:
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return e.Encode(m)
+	case SourceMapText:
+		return m.writeText(w)
+	}
+	return fmt.Errorf("unsupported source map format: %v", format)
+}
+
+// テキスト形式で出力する
+//
+//	00007C50 helloos.asm:22
+//	00007C62 macro.inc:4 PUTS@helloos.asm:30
+//
+// マクロの展開であれば、呼び出したマクロと位置を内側から順に続ける
+func (m *SourceMap) writeText(w io.Writer) error {
+
+	bw := bufio.NewWriter(w)
+	for _, e := range m.Entries {
+		fmt.Fprintf(bw, "%08X %s:%d", e.Address, e.File, e.Line)
+		for _, c := range e.Expansion {
+			fmt.Fprintf(bw, " %s@%s:%d", c.Macro, c.File, c.Line)
+		}
+		if err := bw.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// アドレスを含む範囲を探す
+//
+// @param address --- アドレス
+//
+// @return 範囲、見つかったかどうか
+func (m *SourceMap) Lookup(address int64) (SourceMapEntry, bool) {
+
+	i := sort.Search(len(m.Entries), func(i int) bool {
+		return m.Entries[i].Address+m.Entries[i].Size > address
+	})
+	if i < len(m.Entries) && m.Entries[i].Address <= address {
+		return m.Entries[i], true
+	}
+	return SourceMapEntry{}, false
+}
+
+// ソースマップを作成する
+// 命令の範囲を、出力した行の範囲の境界でさらに分割する
+//
+// @return ソースマップ
+func (a *assembly) sourceMap() *SourceMap {
+
+	var (
+		result = &SourceMap{Origin: a.origin}
+		next   int // 次に切り替わるsourcesの位置
+		start  int64
+	)
+	for _, m := range a.mnemonics {
+
+		end := start + m.Size()
+		for start < end {
+			for next < len(a.sources) && a.sources[next].offset <= start {
+				next++
+			}
+			size := end - start
+			if next < len(a.sources) && a.sources[next].offset < end {
+				size = a.sources[next].offset - start
+			}
+
+			entry := SourceMapEntry{Address: a.origin + start, Size: size}
+			if next > 0 {
+				r := a.sources[next-1]
+				entry.File, entry.Line = r.pos.file, r.pos.line
+				for c := r.expansion; c != nil; c = c.parent {
+					entry.Expansion = append(entry.Expansion, MacroCall{Macro: c.name, File: c.pos.file, Line: c.pos.line})
+				}
+			}
+			result.Entries = append(result.Entries, entry)
+			start += size
+		}
+	}
+	result.Size = start
+
+	return result
+}
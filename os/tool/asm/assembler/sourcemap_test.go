@@ -0,0 +1,129 @@
+package assembler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.nanasi880.dev/xtesting"
+)
+
+// ソースマップの範囲が隙間なく出力全体を覆っていること
+func checkSourceMap(t *testing.T, m *SourceMap) {
+
+	t.Helper()
+
+	address := m.Origin
+	for _, e := range m.Entries {
+		if e.Address != address || e.Size <= 0 {
+			t.Fatalf("%+v: want address 0x%X", e, address)
+		}
+		address += e.Size
+	}
+	if address != m.Origin+m.Size {
+		t.Fatalf("0x%X, want 0x%X", address, m.Origin+m.Size)
+	}
+}
+
+func TestAssembler_SourceMap(t *testing.T) {
+
+	asmFile := xtesting.MustOpen(t, "testdata/macro.txt")
+	defer xtesting.MustClose(t, asmFile)
+
+	result, err := New().Exec(asmFile, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSourceMap(t, result.SourceMap)
+
+	// マクロの本体の行は、呼び出した位置と共に記録される
+	text := new(bytes.Buffer)
+	if err := result.SourceMap.Write(text, SourceMapText); err != nil {
+		t.Fatal(err)
+	}
+	want := "00000000 :4 PUTS@:19\n00000003 :5 PUTS@:19\n00000006 :15 TWICE@:20\n00000007 :16 TWICE@:20\n" +
+		"00000008 :10 WAIT@:21\n00000009 :11 WAIT@:21\n0000000B :10 WAIT@:22\n0000000C :11 WAIT@:22\n" +
+		"0000000E :24\n0000000F :26\n"
+	if text.String() != want {
+		t.Fatal(text.String())
+	}
+
+	// JSONから読み戻せること
+	b := new(bytes.Buffer)
+	if err := result.SourceMap.Write(b, SourceMapJSON); err != nil {
+		t.Fatal(err)
+	}
+	var decoded SourceMap
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&decoded, result.SourceMap) {
+		t.Fatal(b.String())
+	}
+}
+
+func TestAssembler_SourceMapInclude(t *testing.T) {
+
+	asmFile := xtesting.MustOpen(t, "testdata/include.txt")
+	defer xtesting.MustClose(t, asmFile)
+
+	result, err := New(WithIncludeFS(DirFS("testdata"))).Exec(asmFile, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := result.SourceMap
+	checkSourceMap(t, m)
+
+	// INCLUDEしたファイルの行はそのファイル名で記録される
+	files := make(map[string]bool)
+	for _, e := range m.Entries {
+		files[e.File] = true
+	}
+	if !files[""] || !files["include/bpb.inc"] || !files["include/putloop.inc"] {
+		t.Fatal(files)
+	}
+
+	e, ok := m.Lookup(m.Origin + 1)
+	if !ok || e.Address != m.Origin || e.File != "include/bpb.inc" || e.Line != 2 {
+		t.Fatal(e, ok)
+	}
+	if _, ok := m.Lookup(m.Origin - 1); ok {
+		t.Fatal(m.Origin - 1)
+	}
+	if _, ok := m.Lookup(m.Origin + m.Size); ok {
+		t.Fatal(m.Origin + m.Size)
+	}
+}
+
+// 1つにまとめられたデータやRESBも、行毎に分割して記録される
+func TestAssembler_SourceMapData(t *testing.T) {
+
+	src := "ORG 0x100\nDB 1, 2\nDW 3\nlabel:\nRESB 4\nDB label-$$"
+	result, err := New().Exec(strings.NewReader(src), ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSourceMap(t, result.SourceMap)
+
+	var s []string
+	for _, e := range result.SourceMap.Entries {
+		s = append(s, fmt.Sprintf("%X+%d:%d", e.Address, e.Size, e.Line))
+	}
+	if strings.Join(s, " ") != "100+2:2 102+2:3 104+4:5 108+1:6" {
+		t.Fatal(s)
+	}
+
+	for _, name := range []string{"json", "text"} {
+		f, err := ParseSourceMapFormat(name)
+		if err != nil || f.String() != name {
+			t.Fatal(name, f, err)
+		}
+	}
+	if _, err := ParseSourceMapFormat("yaml"); err == nil {
+		t.Fatal("yaml")
+	}
+}
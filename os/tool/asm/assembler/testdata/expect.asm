@@ -0,0 +1,19 @@
+; asm test で確認する期待値のコーパス
+; 各行のコメントに expect: に続けて、その行が出力するバイト列を16進数で記述する
+
+        ORG     0x7c00
+        INCLUDE "include/bpb.inc"   ; INCLUDEした行はINCLUDEした側の行に含まれない
+        DB      1, 2, 3             ; expect: 01 02 03
+        DB      "AB", 0             ; expect: 41 42 00
+        DW      512                 ; expect: 00 02
+        DD      0x12345678          ; expect: 78 56 34 12
+        DQ      1                   ; expect: 01 00 00 00 00 00 00 00
+        RESB    2                   ; expect: 00 00
+entry:                              ; expect:
+        MOV     AX, 0               ; expect: B8 00 00
+        MOV     SS, AX              ; expect: 8E D0
+        MOV     SI, msg             ; expect: BE 25 7C
+        INT     0x10                ; expect: CD 10
+        JMP     entry               ; expect: EB F4
+msg:
+        DB      "hi", 0x0a, 0       ; expect: 68 69 0A 00
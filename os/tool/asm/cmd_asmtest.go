@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/nanasi880/til/os/tool/asm/asmtest"
+)
+
+// asm test [-update] [-v] [-ext .asm,.txt] [-input-encoding name] [-string-encoding name] [path ...]
+// ディレクトリ内の.asmファイル(-extで変更できる)、または指定したファイルをアセンブルし、
+// 行のコメントに埋め込まれた期待値 (; expect: 00 02) とゴールデンファイル (.bin) を確認する
+// 全て成功すれば0、失敗があれば1で終了する
+func asmTestMain(args []string) int {
+
+	var (
+		fs        = flag.NewFlagSet("test", flag.ContinueOnError)
+		update    = fs.Bool("update", false, "write the assembled output to the .bin golden files instead of comparing")
+		verbose   = fs.Bool("v", false, "print every file, not only the failed ones")
+		extText   = fs.String("ext", asmtest.SourceExt, "comma-separated extensions of the source files searched for in directories")
+		encodings encodingFlags
+	)
+	encodings.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	options, err := encodings.options()
+	if err != nil {
+		errorln(err)
+		return 2
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := asmtest.Files(paths, strings.Split(*extText, ",")...)
+	if err != nil {
+		errorln(err)
+		return 2
+	}
+
+	failed := 0
+	for _, name := range files {
+		result, err := asmtest.Run(name, *update, options...)
+		if err != nil {
+			errorln(err)
+			return 2
+		}
+		if !result.OK() {
+			failed++
+			fmt.Print(result)
+			fmt.Printf("FAIL\t%s\n", name)
+			continue
+		}
+		if *verbose || result.Updated {
+			fmt.Printf("ok\t%s\t%s\n", name, summary(result))
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("FAIL\t%d of %d files\n", failed, len(files))
+		return 1
+	}
+	fmt.Printf("ok\t%d files\n", len(files))
+	return 0
+}
+
+// 成功したテストの内容
+func summary(r *asmtest.Result) string {
+	switch {
+	case r.Updated:
+		return fmt.Sprintf("%d expectations, golden updated", r.Expectations)
+	case r.Golden:
+		return fmt.Sprintf("%d expectations, golden", r.Expectations)
+	}
+	return fmt.Sprintf("%d expectations", r.Expectations)
+}
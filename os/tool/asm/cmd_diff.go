@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler"
+)
+
+// asm diff [-f source] -ref image [-max n] [-input-encoding name] [-string-encoding name]
+// ソースコードをアセンブルした結果をリファレンスのイメージと比較し、異なる範囲とそれを出力した行を表示する
+// 一致すれば0、異なれば1、アセンブルに失敗した場合などは2で終了する
+func diffMain(args []string) int {
+
+	var (
+		fs         = flag.NewFlagSet("diff", flag.ContinueOnError)
+		sourceName = fs.String("f", "", "source file name or path (stdin by default)")
+		refName    = fs.String("ref", "", "reference image file name or path")
+		maxRanges  = fs.Int("max", 20, "maximum number of differing ranges to print (0 for all)")
+		encodings  encodingFlags
+	)
+	encodings.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *refName == "" {
+		errorln("-ref is required")
+		return 2
+	}
+	options, err := encodings.options()
+	if err != nil {
+		errorln(err)
+		return 2
+	}
+
+	want, err := ioutil.ReadFile(*refName)
+	if err != nil {
+		errorln(err)
+		return 2
+	}
+
+	source := os.Stdin
+	includeDir := "."
+	if *sourceName != "" {
+		f, err := os.Open(*sourceName)
+		if err != nil {
+			errorln(err)
+			return 2
+		}
+		source = f
+		includeDir = filepath.Dir(*sourceName)
+		defer fclose(f)
+	}
+
+	got := new(bytes.Buffer)
+	options = append(options, assembler.WithIncludeFS(assembler.DirFS(includeDir)))
+	result, err := assembler.New(options...).Exec(source, got)
+	if err != nil {
+		errorln(err)
+		return 2
+	}
+
+	diffs := assembler.Diff(got.Bytes(), want, result.Sources)
+	if len(diffs) == 0 {
+		return 0
+	}
+
+	// メインのソースコードの行はファイル名を付けて表示する
+	for i := range diffs {
+		if diffs[i].File == "" && diffs[i].Line > 0 && *sourceName != "" {
+			diffs[i].File = *sourceName
+		}
+	}
+	if got.Len() != len(want) {
+		fmt.Printf("size: got %d bytes, want %d bytes\n", got.Len(), len(want))
+	}
+	fmt.Print(assembler.FormatDiff(diffs, *maxRanges))
+
+	return 1
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler/expr"
+	"github.com/nanasi880/til/os/tool/asm/disasm"
+)
+
+// asm disasm [-f image] [-o source] [-org 0x7c00] [-bits 16|32]
+// フラットバイナリを逆アセンブルし、アセンブルし直せるソースコードを出力する
+func disasmMain(args []string) int {
+
+	var (
+		fs         = flag.NewFlagSet("disasm", flag.ContinueOnError)
+		inputName  = fs.String("f", "", "binary file name or path (stdin by default)")
+		outputName = fs.String("o", "", "output source file name or path (stdout by default)")
+		orgText    = fs.String("org", "0", "address where the binary is loaded (ORG)")
+		bits       = fs.Int("bits", 16, "16 or 32")
+	)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	org, err := expr.ParseNumber(*orgText)
+	if err != nil {
+		errorln(err)
+		return 2
+	}
+
+	input := os.Stdin
+	if *inputName != "" {
+		f, err := os.Open(*inputName)
+		if err != nil {
+			errorln(err)
+			return 1
+		}
+		input = f
+		defer fclose(f)
+	}
+	b, err := ioutil.ReadAll(input)
+	if err != nil {
+		errorln(err)
+		return 1
+	}
+
+	output := os.Stdout
+	if *outputName != "" {
+		f, err := os.Create(*outputName)
+		if err != nil {
+			errorln(err)
+			return 1
+		}
+		output = f
+		defer fclose(f)
+	}
+
+	if err := disasm.Disassemble(b, org, *bits, output); err != nil {
+		errorln(err)
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler"
+	"github.com/nanasi880/til/os/tool/asm/assembler/expr"
+	"github.com/nanasi880/til/os/tool/asm/internal/patch"
+)
+
+// asm patch -image image -at offset [-f source] [-org 0x7c00] [-grow] [-input-encoding name] [-string-encoding name]
+// ソースコードをアセンブルし、既存のイメージの指定した位置をその場で書き換える
+// ソースコードの$は書き換える位置のアドレス(-org + -at)から始まる
+// 書き換える前後の内容を標準出力に書き出す
+func patchMain(args []string) int {
+
+	var (
+		fs         = flag.NewFlagSet("patch", flag.ContinueOnError)
+		imageName  = fs.String("image", "", "image file name or path to patch in place")
+		atText     = fs.String("at", "", "offset in the image to write the assembled bytes at")
+		sourceName = fs.String("f", "", "source file name or path (stdin by default)")
+		orgText    = fs.String("org", "0", "address where the image is loaded (ORG of the image)")
+		grow       = fs.Bool("grow", false, "allow the patch to extend the image beyond its end")
+		encodings  encodingFlags
+	)
+	encodings.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *imageName == "" || *atText == "" {
+		errorln("-image and -at are required")
+		return 2
+	}
+	at, err := expr.ParseNumber(*atText)
+	if err != nil {
+		errorln(err)
+		return 2
+	}
+	org, err := expr.ParseNumber(*orgText)
+	if err != nil {
+		errorln(err)
+		return 2
+	}
+	options, err := encodings.options()
+	if err != nil {
+		errorln(err)
+		return 2
+	}
+
+	source := os.Stdin
+	includeDir := "."
+	if *sourceName != "" {
+		f, err := os.Open(*sourceName)
+		if err != nil {
+			errorln(err)
+			return 1
+		}
+		source = f
+		includeDir = filepath.Dir(*sourceName)
+		defer fclose(f)
+	}
+
+	b, err := assemblePatch(source, org+at, includeDir, options)
+	if err != nil {
+		errorln(err)
+		return 1
+	}
+
+	image, err := os.OpenFile(*imageName, os.O_RDWR, 0)
+	if err != nil {
+		errorln(err)
+		return 1
+	}
+	defer fclose(image)
+
+	result, err := patch.Apply(image, at, b, *grow)
+	if err != nil {
+		errorln(err)
+		return 1
+	}
+	if err := image.Sync(); err != nil {
+		errorln(err)
+		return 1
+	}
+	if err := result.Dump(os.Stdout); err != nil {
+		errorln(err)
+		return 1
+	}
+
+	return 0
+}
+
+// 書き換える内容をアセンブルする
+//
+// @param source     --- ソースコード
+// @param address    --- 書き換える位置のアドレス
+// @param includeDir --- INCLUDE命令のファイル名の基準となるディレクトリ
+// @param options    --- 文字コードなど、配置位置とINCLUDE命令のファイルシステム以外のアセンブラのオプション
+//
+// @return 書き換える内容、エラー
+func assemblePatch(source *os.File, address int64, includeDir string, options []assembler.Option) ([]byte, error) {
+
+	b := new(bytes.Buffer)
+	options = append(options,
+		assembler.WithOrigin(address),
+		assembler.WithIncludeFS(assembler.DirFS(includeDir)),
+	)
+	a := assembler.New(options...)
+	result, err := a.Exec(source, b)
+	for _, d := range result.Diagnostics {
+		if d.Severity != assembler.SeverityError {
+			errorln(d)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// ORGで配置位置を変えると$が書き換える位置と一致しなくなる
+	if result.SourceMap.Origin != address {
+		return nil, errors.New("ORG can't be used in a patch; the address is given by -org and -at")
+	}
+	if b.Len() == 0 {
+		return nil, errors.New("the patch source produced no bytes")
+	}
+	return b.Bytes(), nil
+}
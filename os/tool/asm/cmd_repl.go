@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler"
+	"github.com/nanasi880/til/os/tool/asm/assembler/expr"
+	"github.com/nanasi880/til/os/tool/asm/internal/repl"
+)
+
+// asm repl [-org 0x7c00] [-bits 16|32] [-o file]
+// 1行ずつアセンブルし、出力したバイト列と次のアドレスを表示する
+// 終了時に-oが指定されていれば、蓄積したバイト列をそのファイルに書き出す
+func replMain(args []string) int {
+
+	var (
+		fs         = flag.NewFlagSet("repl", flag.ContinueOnError)
+		orgText    = fs.String("org", "0", "initial origin (ORG)")
+		bits       = fs.Int("bits", 16, "initial mode, 16 or 32")
+		outputName = fs.String("o", "", "write the accumulated bytes to this file on exit")
+	)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	org, err := expr.ParseNumber(*orgText)
+	if err != nil {
+		errorln(err)
+		return 2
+	}
+
+	// 端末から入力している場合だけプロンプトを表示する
+	prompt := false
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		prompt = true
+		fmt.Println("asm repl: type .help for commands, .quit or Ctrl+D to exit")
+	}
+
+	s := repl.New(org, *bits, assembler.DirFS("."))
+	if err := s.Run(os.Stdin, os.Stdout, prompt); err != nil {
+		errorln(err)
+		return 1
+	}
+	if prompt {
+		fmt.Println()
+	}
+
+	if *outputName != "" {
+		if err := ioutil.WriteFile(*outputName, s.Bytes(), 0644); err != nil {
+			errorln(err)
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+
+	"github.com/nanasi880/til/os/tool/asm/emulator"
+)
+
+// asm run [-f image] [-steps n]
+// ブートイメージを0x7c00に読み込んでエミュレーターで実行し、INT 10hで表示された文字を標準出力に書き出す
+func runMain(args []string) int {
+
+	var (
+		fs        = flag.NewFlagSet("run", flag.ContinueOnError)
+		inputName = fs.String("f", "", "boot image file name or path (stdin by default)")
+		maxSteps  = fs.Int64("steps", 1000000, "maximum number of instructions to execute")
+	)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	input := os.Stdin
+	if *inputName != "" {
+		f, err := os.Open(*inputName)
+		if err != nil {
+			errorln(err)
+			return 1
+		}
+		input = f
+		defer fclose(f)
+	}
+	image, err := ioutil.ReadAll(input)
+	if err != nil {
+		errorln(err)
+		return 1
+	}
+
+	result, err := emulator.Run(image, *maxSteps)
+	if result != nil {
+		_, _ = os.Stdout.Write(result.Output)
+	}
+	if err != nil {
+		errorln(err)
+		return 1
+	}
+	if result.Reason == emulator.StopBudget {
+		errorln("stopped:", result.Reason, "after", result.Steps, "instructions")
+	}
+
+	return 0
+}
@@ -0,0 +1,217 @@
+// Package disasm : フラットバイナリの逆アセンブル処理
+package disasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler/expr"
+	"github.com/nanasi880/til/os/tool/asm/assembler/instruction"
+)
+
+// 逆アセンブルの単位
+type item struct {
+	addr   int64                // 先頭アドレス
+	length int                  // バイト数
+	kind   itemKind             // 種類
+	inst   *instruction.Decoded // 命令の場合のデコード結果
+}
+
+type itemKind int
+
+const (
+	itemInstruction itemKind = iota // 機械語命令
+	itemByte                        // デコードできなかった1バイト DBで出力する
+	itemZero                        // 連続したゼロ RESBで出力する
+)
+
+// 連続したゼロをRESBとして出力する最小のバイト数
+const minZeroRun = 16
+
+// DB命令1行に出力する最大のバイト数
+const bytesPerLine = 16
+
+// フラットバイナリを逆アセンブルし、アセンブラのソースコードとして出力する
+// 出力したソースコードをアセンブルすると元のバイナリと同じものが得られる
+// デコードできないバイト列や、アセンブルし直すと同じバイト列にならない命令はDB命令として出力する
+// 相対ジャンプの飛び先が命令の先頭であればラベルを出力する
+//
+// @param b    --- バイナリ
+// @param org  --- バイナリが配置されるアドレス (ORG)
+// @param bits --- 16 or 32
+// @param w    --- 出力先
+//
+// @return エラー
+func Disassemble(b []byte, org int64, bits int, w io.Writer) error {
+
+	if bits != 16 && bits != 32 {
+		return fmt.Errorf("invalid bits: %d", bits)
+	}
+
+	// 飛び先が命令の途中にならないように、飛び先が増えなくなるまで繰り返しデコードする
+	targets := make(map[int64]bool)
+	var items []item
+	for {
+		items = sweep(b, org, bits, targets)
+
+		added := false
+		for _, it := range items {
+			if it.kind != itemInstruction || !it.inst.Relative {
+				continue
+			}
+			t := it.inst.Target
+			if org <= t && t < org+int64(len(b)) && !targets[t] {
+				targets[t] = true
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+
+	return write(w, b, org, bits, items, targets)
+}
+
+// 先頭から順にデコードする
+// 飛び先のアドレスをまたぐ命令はデコードせず、1バイトずつDBとして扱う
+func sweep(b []byte, org int64, bits int, targets map[int64]bool) []item {
+
+	var items []item
+	for pos := 0; pos < len(b); {
+
+		addr := org + int64(pos)
+
+		// 連続したゼロ
+		n := 0
+		for pos+n < len(b) && b[pos+n] == 0 && (n == 0 || !targets[addr+int64(n)]) {
+			n++
+		}
+		if n >= minZeroRun {
+			items = append(items, item{addr: addr, length: n, kind: itemZero})
+			pos += n
+			continue
+		}
+
+		if d, ok := decode(b[pos:], addr, org, bits, targets); ok {
+			items = append(items, item{addr: addr, length: d.Length, kind: itemInstruction, inst: d})
+			pos += d.Length
+			continue
+		}
+
+		items = append(items, item{addr: addr, length: 1, kind: itemByte})
+		pos++
+	}
+
+	return items
+}
+
+// 1命令をデコードし、アセンブルし直して同じバイト列になることを確認する
+func decode(b []byte, addr int64, org int64, bits int, targets map[int64]bool) (*instruction.Decoded, bool) {
+
+	d, err := instruction.Decode(b, bits, addr)
+	if err != nil {
+		return nil, false
+	}
+	for i := 1; i < d.Length; i++ {
+		if targets[addr+int64(i)] {
+			return nil, false
+		}
+	}
+
+	x, err := instruction.NewX86(d.Form.Mnemonic, d.Operands, bits, instruction.CPU386, addr, org, nil)
+	if err != nil {
+		return nil, false
+	}
+	if err := x.Relocate(nil); err != nil {
+		return nil, false
+	}
+	var buf strings.Builder
+	if _, err := x.Write(&buf); err != nil {
+		return nil, false
+	}
+	if buf.String() != string(b[:d.Length]) {
+		return nil, false
+	}
+
+	return d, true
+}
+
+// ソースコードを出力する
+func write(w io.Writer, b []byte, org int64, bits int, items []item, targets map[int64]bool) error {
+
+	bw := bufio.NewWriter(w)
+
+	if org != 0 {
+		fmt.Fprintf(bw, "    ORG   0x%X\n", org)
+	}
+	if bits != 16 {
+		fmt.Fprintf(bw, "    BITS  %d\n", bits)
+	}
+
+	for i := 0; i < len(items); i++ {
+
+		it := items[i]
+		if targets[it.addr] {
+			fmt.Fprintf(bw, "%s:\n", label(it.addr))
+		}
+
+		switch it.kind {
+
+		case itemInstruction:
+			text := it.inst.Form.Mnemonic
+			for j, op := range it.inst.Operands {
+				if j == 0 {
+					text += " "
+				} else {
+					text += ", "
+				}
+				if imm, ok := op.(instruction.Immediate); ok && it.inst.Relative && targets[it.inst.Target] {
+					e, err := expr.Parse(label(it.inst.Target))
+					if err != nil {
+						return err
+					}
+					op = instruction.Immediate{Value: e, Distance: imm.Distance}
+				}
+				text += op.String()
+			}
+			writeLine(bw, text, it.addr, b[it.addr-org:it.addr-org+int64(it.length)])
+
+		case itemZero:
+			writeLine(bw, fmt.Sprintf("RESB  %d", it.length), it.addr, nil)
+
+		case itemByte:
+			// ラベルが無い範囲の連続したバイトを1行にまとめる
+			j := i + 1
+			for j < len(items) && j-i < bytesPerLine && items[j].kind == itemByte && !targets[items[j].addr] {
+				j++
+			}
+			data := b[it.addr-org : items[j-1].addr-org+1]
+			values := make([]string, len(data))
+			for k, c := range data {
+				values[k] = fmt.Sprintf("0x%02X", c)
+			}
+			writeLine(bw, "DB    "+strings.Join(values, ", "), it.addr, nil)
+			i = j - 1
+		}
+	}
+
+	return bw.Flush()
+}
+
+// 1行を出力する アドレスと機械語をコメントとして付ける
+func writeLine(w *bufio.Writer, text string, addr int64, code []byte) {
+
+	comment := fmt.Sprintf("%08X", addr)
+	for _, c := range code {
+		comment += fmt.Sprintf(" %02X", c)
+	}
+	fmt.Fprintf(w, "    %-40s ; %s\n", text, comment)
+}
+
+// 飛び先のアドレスに付けるラベル名
+func label(addr int64) string {
+	return fmt.Sprintf("L%04X", addr)
+}
@@ -0,0 +1,81 @@
+package disasm
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"go.nanasi880.dev/xtesting"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler"
+)
+
+// 逆アセンブルした結果をアセンブルし直して元のバイナリと一致することを確認する
+func roundTrip(t *testing.T, b []byte, org int64, bits int) string {
+	t.Helper()
+
+	src := new(bytes.Buffer)
+	if err := Disassemble(b, org, bits, src); err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(bytes.Buffer)
+	if _, err := assembler.New().Exec(bytes.NewReader(src.Bytes()), out); err != nil {
+		t.Fatal(err, "\n", src.String())
+	}
+	if bytes.Compare(out.Bytes(), b) != 0 {
+		t.Fatal("round trip mismatch\n", src.String())
+	}
+
+	return src.String()
+}
+
+func TestDisassemble_Hello(t *testing.T) {
+
+	asmFile := xtesting.MustOpen(t, "../assembler/testdata/helloos.txt")
+	defer xtesting.MustClose(t, asmFile)
+
+	image := new(bytes.Buffer)
+	if _, err := assembler.New().Exec(asmFile, image); err != nil {
+		t.Fatal(err)
+	}
+
+	src := roundTrip(t, image.Bytes(), 0x7c00, 16)
+
+	for _, want := range []string{
+		"ORG   0x7C00",
+		"JMP SHORT L7C50",
+		"L7C50:\n    MOV AX, 0x0 ",
+		"MOV SI, 0x7C74",
+		"L7C5F:\n    MOV AL, [SI]",
+		"JE SHORT L7C71",
+		"INT 0x10",
+		"L7C71:\n    HLT",
+		"RESB  ",
+	} {
+		if !strings.Contains(src, want) {
+			t.Fatal(want, "\n", src[:4096])
+		}
+	}
+}
+
+func TestDisassemble_Random(t *testing.T) {
+
+	r := rand.New(rand.NewSource(1))
+	for _, bits := range []int{16, 32} {
+		for i := 0; i < 20; i++ {
+			b := make([]byte, 256)
+			r.Read(b)
+			roundTrip(t, b, 0x7c00, bits)
+		}
+	}
+}
+
+func TestDisassemble_Undecodable(t *testing.T) {
+
+	src := roundTrip(t, []byte{0x0f, 0x0b, 0xf4}, 0, 16)
+	if !strings.Contains(src, "DB    0x0F, 0x0B") || !strings.Contains(src, "HLT") {
+		t.Fatal(src)
+	}
+}
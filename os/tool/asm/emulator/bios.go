@@ -0,0 +1,133 @@
+package emulator
+
+import (
+	"fmt"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler/instruction"
+)
+
+// フロッピーディスク(1.44MB)のジオメトリ
+const (
+	sectorsPerTrack = 18
+	heads           = 2
+	cylinders       = 80
+)
+
+// 割り込みを処理する
+// プログラムが割り込みベクタを書き換えていればそのハンドラを呼び出し、そうでなければBIOSの機能をエミュレートする
+//
+// @param n --- 割り込み番号
+//
+// @return エラー
+func (m *Machine) interrupt(n byte) error {
+
+	vector := m.load(uint32(n)*4, 4)
+	if vector != 0 {
+		m.push(m.flags&0xFFFF, 2)
+		m.push(uint32(m.sregs[1]), 2)
+		m.push(uint32(m.ip), 2)
+		m.setFlag(flagIF, false)
+		m.setFlag(flagTF, false)
+		m.ip = uint16(vector)
+		m.sregs[1] = uint16(vector >> 16)
+		return nil
+	}
+
+	switch n {
+	case 0x10:
+		return m.video()
+	case 0x12:
+		// 使用可能なメモリのサイズ(KB)
+		m.setReg(instruction.AX, 640)
+		return nil
+	case 0x13:
+		return m.disk13()
+	}
+	return fmt.Errorf("unsupported interrupt: INT 0x%02X (AH=0x%02X)", n, m.reg(instruction.AH))
+}
+
+// INT 10h ビデオサービス
+// 文字の出力だけを扱い、それ以外の機能は何もしない
+func (m *Machine) video() error {
+
+	switch m.reg(instruction.AH) {
+
+	// テレタイプ出力
+	case 0x0E:
+		m.output = append(m.output, byte(m.reg(instruction.AL)))
+
+	// 文字列の出力 ES:BPの文字列をCX文字 ALのbit1が立っていれば文字と属性が交互に並んでいる
+	case 0x13:
+		var (
+			addr   = m.linear(m.sregs[0], m.reg(instruction.BP))
+			count  = m.reg(instruction.CX)
+			stride = uint32(1)
+		)
+		if m.reg(instruction.AL)&2 != 0 {
+			stride = 2
+		}
+		for i := uint32(0); i < count; i++ {
+			m.output = append(m.output, byte(m.load(addr+i*stride, 1)))
+		}
+	}
+
+	return nil
+}
+
+// INT 13h ディスクサービス
+// ディスクイメージからの読み込みだけを扱う
+func (m *Machine) disk13() error {
+
+	switch m.reg(instruction.AH) {
+
+	// リセット
+	case 0x00:
+		m.diskStatus(0)
+
+	// セクタの読み込み
+	case 0x02:
+		var (
+			count    = m.reg(instruction.AL)
+			cl       = m.reg(instruction.CL)
+			sector   = cl & 0x3F
+			cylinder = m.reg(instruction.CH) | (cl&0xC0)<<2
+			head     = m.reg(instruction.DH)
+			dst      = m.linear(m.sregs[0], m.reg(instruction.BX))
+		)
+		if m.reg(instruction.DL) != 0 || sector == 0 || sector > sectorsPerTrack || head >= heads || cylinder >= cylinders {
+			m.diskStatus(0x01)
+			return nil
+		}
+		lba := (cylinder*heads+head)*sectorsPerTrack + sector - 1
+		start, end := int(lba)*SectorSize, int(lba+count)*SectorSize
+		if count == 0 || end > len(m.disk) {
+			m.diskStatus(0x04)
+			return nil
+		}
+		for i, c := range m.disk[start:end] {
+			m.store(dst+uint32(i), 1, uint32(c))
+		}
+		m.diskStatus(0)
+		m.setReg(instruction.AL, count)
+
+	// ドライブパラメーターの取得
+	case 0x08:
+		m.diskStatus(0)
+		m.setReg(instruction.BL, 0x04)
+		m.setReg(instruction.CH, (cylinders-1)&0xFF)
+		m.setReg(instruction.CL, sectorsPerTrack|((cylinders-1)>>2)&0xC0)
+		m.setReg(instruction.DH, heads-1)
+		m.setReg(instruction.DL, 1)
+
+	default:
+		m.diskStatus(0x01)
+	}
+
+	return nil
+}
+
+// INT 13hの結果をAHとCFに設定する
+func (m *Machine) diskStatus(status uint32) {
+	m.setReg(instruction.AH, status)
+	m.setFlag(flagCF, status != 0)
+}
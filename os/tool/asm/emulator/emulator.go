@@ -0,0 +1,350 @@
+// Package emulator : ブートイメージを実行するためのリアルモードx86エミュレーター
+//
+// 画面やキーボードを持たず、BIOSのINT 10h(テレタイプ出力)とINT 13h(ディスク読み込み)だけを
+// エミュレートする テストでブートイメージが期待通りの文字を表示するかを確認するために使用する
+package emulator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler/instruction"
+)
+
+const (
+	// ブートセクタが読み込まれるアドレス
+	LoadAddress = 0x7c00
+
+	// ブートセクタのサイズ
+	SectorSize = 512
+
+	// エミュレートするメモリのサイズ A20は無効なので1MBでラップアラウンドする
+	memorySize = 0x100000
+)
+
+// 停止した理由
+type StopReason int
+
+const (
+	StopHalt   StopReason = iota + 1 // HLT命令を実行した
+	StopBudget                       // 命令数の上限に達した
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopHalt:
+		return "halt"
+	case StopBudget:
+		return "instruction budget exhausted"
+	}
+	return "unknown"
+}
+
+// 実行結果
+type Result struct {
+	Output []byte     // INT 10hで画面に出力された文字
+	Steps  int64      // 実行した命令数
+	Reason StopReason // 停止した理由
+}
+
+// エミュレートしている命令の実行に失敗した
+type ExecError struct {
+	CS  uint16 // 命令のセグメント
+	IP  uint16 // 命令のオフセット
+	Err error  // 原因
+}
+
+func (e *ExecError) Error() string {
+	return fmt.Sprintf("%04X:%04X: %s", e.CS, e.IP, e.Err.Error())
+}
+
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// デコードできない命令を実行しようとした
+var ErrInvalidOpcode = errors.New("invalid or unsupported opcode")
+
+// フラグレジスタのビット
+const (
+	flagCF = 1 << 0
+	flagPF = 1 << 2
+	flagAF = 1 << 4
+	flagZF = 1 << 6
+	flagSF = 1 << 7
+	flagTF = 1 << 8
+	flagIF = 1 << 9
+	flagDF = 1 << 10
+	flagOF = 1 << 11
+)
+
+// 仮想マシン
+type Machine struct {
+	mem    []byte                // メインメモリ
+	disk   []byte                // ディスクイメージ INT 13hで読み込まれる
+	regs   [8]uint32             // 汎用レジスタ EAX, ECX, EDX, EBX, ESP, EBP, ESI, EDIの順
+	sregs  [6]uint16             // セグメントレジスタ ES, CS, SS, DS, FS, GSの順
+	ip     uint16                // 命令ポインタ
+	flags  uint32                // フラグレジスタ
+	output []byte                // 画面に出力された文字
+	steps  int64                 // 実行した命令数
+	halted bool                  // HLT命令で停止しているかどうか
+	cache  map[cacheKey]*decoded // デコード済みの命令
+}
+
+// デコード済みの命令のキー
+// 相対ジャンプの飛び先はオフセットで保持しているので、リニアアドレスが同じでもIPが異なれば別の命令として扱う
+type cacheKey struct {
+	addr uint32 // リニアアドレス
+	ip   uint16 // オフセット
+}
+
+// デコード済みの命令
+type decoded struct {
+	code []byte               // 命令のバイト列 書き換えられていないかの確認に使う
+	inst *instruction.Decoded // デコード結果
+	rep  byte                 // REPプレフィックス 無ければ0
+}
+
+// 仮想マシンを作成する
+// ディスクイメージの先頭1セクタを0x7c00に読み込み、CS:IP=0000:7C00、DL=0(ブートドライブ)の状態にする
+//
+// @param image --- ディスクイメージ
+//
+// @return 仮想マシン
+func New(image []byte) *Machine {
+
+	m := &Machine{
+		mem:   make([]byte, memorySize),
+		disk:  image,
+		flags: 0x0002,
+		ip:    LoadAddress,
+		cache: make(map[cacheKey]*decoded),
+	}
+	copy(m.mem[LoadAddress:LoadAddress+SectorSize], image)
+	m.regs[4] = LoadAddress
+
+	return m
+}
+
+// ブートイメージを実行する
+//
+// @param image    --- ディスクイメージ
+// @param maxSteps --- 実行する命令数の上限
+//
+// @return 実行結果、エラー
+func Run(image []byte, maxSteps int64) (*Result, error) {
+	return New(image).Run(maxSteps)
+}
+
+// HLT命令を実行するか、命令数の上限に達するまで実行する
+// エラーで停止した場合もそれまでの実行結果を返す
+//
+// @param maxSteps --- 実行する命令数の上限
+//
+// @return 実行結果、エラー
+func (m *Machine) Run(maxSteps int64) (*Result, error) {
+
+	var err error
+	for limit := m.steps + maxSteps; !m.halted && m.steps < limit; {
+		if err = m.Step(); err != nil {
+			break
+		}
+	}
+
+	result := &Result{
+		Output: append([]byte(nil), m.output...),
+		Steps:  m.steps,
+		Reason: StopBudget,
+	}
+	if m.halted {
+		result.Reason = StopHalt
+	}
+	return result, err
+}
+
+// 画面に出力された文字
+func (m *Machine) Output() []byte {
+	return m.output
+}
+
+// メインメモリ
+func (m *Machine) Memory() []byte {
+	return m.mem
+}
+
+// レジスタの値を返す
+//
+// @param r --- 汎用レジスタまたはセグメントレジスタ
+//
+// @return 値
+func (m *Machine) Register(r instruction.Register) uint32 {
+	return m.reg(r)
+}
+
+// CS:IP
+func (m *Machine) IP() (uint16, uint16) {
+	return m.sregs[1], m.ip
+}
+
+// 1命令を実行する
+func (m *Machine) Step() error {
+
+	cs, ip := m.sregs[1], m.ip
+	if err := m.step(); err != nil {
+		return &ExecError{CS: cs, IP: ip, Err: err}
+	}
+	m.steps++
+	return nil
+}
+
+func (m *Machine) step() error {
+
+	d, err := m.fetch()
+	if err != nil {
+		return err
+	}
+	m.ip += uint16(len(d.code))
+
+	return m.exec(d.inst, d.rep)
+}
+
+// CS:IPの命令を取り出してデコードする
+func (m *Machine) fetch() (*decoded, error) {
+
+	addr := m.linear(m.sregs[1], uint32(m.ip))
+
+	var code [16]byte
+	for i := range code {
+		code[i] = m.mem[(addr+uint32(i))%memorySize]
+	}
+
+	key := cacheKey{addr: addr, ip: m.ip}
+	if d, ok := m.cache[key]; ok && string(d.code) == string(code[:len(d.code)]) {
+		return d, nil
+	}
+
+	// REPプレフィックスはデコーダーが扱わないので取り除く
+	var (
+		rep  byte
+		body = make([]byte, 0, len(code))
+		n    int
+	)
+	for n = 0; n < len(code); n++ {
+		c := code[n]
+		if c == 0xF2 || c == 0xF3 {
+			rep = c
+			continue
+		}
+		if c != 0x26 && c != 0x2E && c != 0x36 && c != 0x3E && c != 0x64 && c != 0x65 && c != 0x66 {
+			break
+		}
+		body = append(body, c)
+	}
+	skipped := n - len(body)
+	body = append(body, code[n:]...)
+
+	inst, err := instruction.Decode(body, 16, int64(m.ip)+int64(skipped))
+	if err != nil {
+		return nil, ErrInvalidOpcode
+	}
+
+	d := &decoded{
+		code: append([]byte(nil), code[:inst.Length+skipped]...),
+		inst: inst,
+		rep:  rep,
+	}
+	m.cache[key] = d
+	return d, nil
+}
+
+// セグメントとオフセットからリニアアドレスを求める
+func (m *Machine) linear(seg uint16, offset uint32) uint32 {
+	return (uint32(seg)<<4 + offset) % memorySize
+}
+
+// メモリから読み込む
+func (m *Machine) load(addr uint32, size int) uint32 {
+	var v uint32
+	for i := size - 1; i >= 0; i-- {
+		v = v<<8 | uint32(m.mem[(addr+uint32(i))%memorySize])
+	}
+	return v
+}
+
+// メモリに書き込む
+func (m *Machine) store(addr uint32, size int, v uint32) {
+	for i := 0; i < size; i++ {
+		m.mem[(addr+uint32(i))%memorySize] = byte(v >> uint(8*i))
+	}
+}
+
+// レジスタを読み込む
+func (m *Machine) reg(r instruction.Register) uint32 {
+
+	if r.IsSegment() {
+		return uint32(m.sregs[r.Number()])
+	}
+
+	n := r.Number()
+	switch r.Size() {
+	case 1:
+		if n < 4 {
+			return m.regs[n] & 0xFF
+		}
+		return m.regs[n-4] >> 8 & 0xFF
+	case 2:
+		return m.regs[n] & 0xFFFF
+	}
+	return m.regs[n]
+}
+
+// レジスタに書き込む
+func (m *Machine) setReg(r instruction.Register, v uint32) {
+
+	if r.IsSegment() {
+		m.sregs[r.Number()] = uint16(v)
+		return
+	}
+
+	n := r.Number()
+	switch r.Size() {
+	case 1:
+		if n < 4 {
+			m.regs[n] = m.regs[n]&^0xFF | v&0xFF
+		} else {
+			m.regs[n-4] = m.regs[n-4]&^0xFF00 | (v&0xFF)<<8
+		}
+	case 2:
+		m.regs[n] = m.regs[n]&^0xFFFF | v&0xFFFF
+	default:
+		m.regs[n] = v
+	}
+}
+
+func (m *Machine) flag(f uint32) bool {
+	return m.flags&f != 0
+}
+
+func (m *Machine) setFlag(f uint32, on bool) {
+	if on {
+		m.flags |= f
+	} else {
+		m.flags &^= f
+	}
+}
+
+// スタックに積む
+func (m *Machine) push(v uint32, size int) {
+	sp := uint16(m.regs[4]) - uint16(size)
+	m.setReg(instruction.SP, uint32(sp))
+	m.store(m.linear(m.sregs[2], uint32(sp)), size, v)
+}
+
+// スタックから取り出す
+func (m *Machine) pop(size int) uint32 {
+	sp := uint16(m.regs[4])
+	v := m.load(m.linear(m.sregs[2], uint32(sp)), size)
+	m.setReg(instruction.SP, uint32(sp+uint16(size)))
+	return v
+}
@@ -0,0 +1,143 @@
+package emulator
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.nanasi880.dev/xtesting"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler"
+	"github.com/nanasi880/til/os/tool/asm/assembler/instruction"
+)
+
+// ソースコードをアセンブルしてディスクイメージを作成する
+func assemble(t *testing.T, src string) []byte {
+	t.Helper()
+
+	b := new(bytes.Buffer)
+	if _, err := assembler.New().Exec(strings.NewReader(src), b); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// テストデータのソースコードをアセンブルしてディスクイメージを作成する
+func assembleFile(t *testing.T, name string) []byte {
+	t.Helper()
+
+	f := xtesting.MustOpen(t, name)
+	defer xtesting.MustClose(t, f)
+
+	b := new(bytes.Buffer)
+	if _, err := assembler.New().Exec(f, b); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestRun_Hello(t *testing.T) {
+
+	image := assembleFile(t, "../assembler/testdata/helloos.txt")
+
+	result, err := Run(image, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Reason != StopHalt {
+		t.Fatal(result.Reason)
+	}
+	if string(result.Output) != "\n\nhello, world\n" {
+		t.Fatalf("%q", result.Output)
+	}
+}
+
+func TestRun_Disk(t *testing.T) {
+
+	image := assembleFile(t, "testdata/disk.txt")
+
+	result, err := Run(image, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result.Output) != "loaded from sector 2" {
+		t.Fatalf("%q", result.Output)
+	}
+}
+
+func TestRun_Budget(t *testing.T) {
+
+	image := assemble(t, "ORG 0x7c00\nJMP $")
+
+	result, err := Run(image, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Reason != StopBudget || result.Steps != 1000 {
+		t.Fatal(result.Reason, result.Steps)
+	}
+}
+
+func TestRun_InvalidOpcode(t *testing.T) {
+
+	image := assemble(t, "ORG 0x7c00\nNOP\nDB 0x0f, 0x0b")
+
+	_, err := Run(image, 1000)
+	var execErr *ExecError
+	if !errors.As(err, &execErr) || !errors.Is(err, ErrInvalidOpcode) {
+		t.Fatal(err)
+	}
+	if execErr.CS != 0 || execErr.IP != 0x7c01 {
+		t.Fatal(execErr)
+	}
+}
+
+func TestMachine_Instructions(t *testing.T) {
+
+	testCases := []struct {
+		src   string
+		reg   instruction.Register
+		value uint32
+	}{
+		{src: "MOV AX, 0x1234\nADD AX, 0x10", reg: instruction.AX, value: 0x1244},
+		{src: "MOV AL, 0xFF\nADD AL, 1\nMOV AL, 0\nADC AL, 0", reg: instruction.AL, value: 1},
+		{src: "MOV AX, 5\nSUB AX, 7", reg: instruction.AX, value: 0xFFFE},
+		{src: "MOV AX, 3\nMOV BX, 7\nMUL BX", reg: instruction.AX, value: 21},
+		{src: "MOV AX, 0xFFFF\nMOV BX, 2\nMUL BX", reg: instruction.DX, value: 1},
+		{src: "MOV DX, 0\nMOV AX, 100\nMOV CX, 7\nDIV CX", reg: instruction.DX, value: 2},
+		{src: "MOV AX, -100\nCWD\nMOV CX, 7\nIDIV CX", reg: instruction.AX, value: 0xFFF2},
+		{src: "MOV AL, -3\nMOV BL, 5\nIMUL BL", reg: instruction.AX, value: 0xFFF1},
+		{src: "MOV AX, 0x8001\nSHL AX, 1", reg: instruction.AX, value: 0x0002},
+		{src: "MOV AX, 0x8000\nSAR AX, 3", reg: instruction.AX, value: 0xF000},
+		{src: "MOV AL, 0x81\nROL AL, 1", reg: instruction.AL, value: 0x03},
+		{src: "STC\nMOV AL, 0\nRCR AL, 1", reg: instruction.AL, value: 0x80},
+		{src: "MOV CX, 10\nMOV AX, 0\nloop:\nADD AX, CX\nLOOP loop", reg: instruction.AX, value: 55},
+		{src: "MOV BX, 0x9000\nMOV WORD [BX+2], 0xBEEF\nMOV SI, 2\nMOV AX, [BX+SI]", reg: instruction.AX, value: 0xBEEF},
+		{src: "MOV AX, 1\nPUSH AX\nMOV AX, 2\nPOP BX", reg: instruction.BX, value: 1},
+		{src: "CALL sub\nHLT\nsub:\nMOV AX, 7\nRET", reg: instruction.AX, value: 7},
+		{src: "MOV AX, 1\nCMP AX, 2\nJL less\nMOV AX, 0\nless:", reg: instruction.AX, value: 1},
+		{src: "MOV AX, 3\nCMP AX, 2\nJA above\nMOV AX, 0\nabove:", reg: instruction.AX, value: 3},
+		{src: "MOV DI, 0x9000\nMOV AL, 'x'\nMOV CX, 4\nREP STOSB\nMOV SI, 0x9000\nLODSW", reg: instruction.AX, value: 0x7878},
+		{src: "MOV EAX, 0x12345678\nROR EAX, 16", reg: instruction.EAX, value: 0x56781234},
+		{src: "LEA BX, [BP+SI+4]", reg: instruction.BX, value: 4},
+		{src: "MOV AX, 0x1000\nMOV ES, AX\nMOV BYTE [ES:0x10], 9\nMOV AL, [ES:0x10]", reg: instruction.AL, value: 9},
+	}
+
+	for i, tt := range testCases {
+
+		image := assemble(t, "ORG 0x7c00\nMOV BP, 0\nMOV SI, 0\n"+tt.src+"\nHLT")
+
+		m := New(image)
+		result, err := m.Run(1000)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if result.Reason != StopHalt {
+			t.Fatal(i, result.Reason)
+		}
+		if v := m.Register(tt.reg); v != tt.value {
+			t.Fatalf("%d: %s = 0x%X", i, tt.reg, v)
+		}
+	}
+}
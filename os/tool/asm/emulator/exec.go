@@ -0,0 +1,695 @@
+package emulator
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler/instruction"
+)
+
+// 0除算、または商がオーバーフローした
+var ErrDivide = errors.New("divide error")
+
+// 命令を実行する
+//
+// @param d   --- デコード済みの命令
+// @param rep --- REPプレフィックス 無ければ0
+//
+// @return エラー
+func (m *Machine) exec(d *instruction.Decoded, rep byte) error {
+
+	f := d.Form
+	ops := d.Operands
+	size := operandSize(f)
+	name := f.Mnemonic
+
+	switch name {
+
+	case "NOP":
+
+	case "HLT":
+		m.halted = true
+
+	case "MOV":
+		v, err := m.read(ops[1], size)
+		if err != nil {
+			return err
+		}
+		return m.write(ops[0], size, v)
+
+	case "LEA":
+		m.setReg(ops[0].(instruction.Register), m.offset(ops[1].(instruction.Memory)))
+
+	case "ADD", "ADC", "SUB", "SBB", "CMP", "AND", "OR", "XOR", "TEST":
+		a, err := m.read(ops[0], size)
+		if err != nil {
+			return err
+		}
+		b, err := m.read(ops[1], size)
+		if err != nil {
+			return err
+		}
+		v := m.arith(name, a, b, size)
+		if name != "CMP" && name != "TEST" {
+			return m.write(ops[0], size, v)
+		}
+
+	case "INC", "DEC", "NEG", "NOT":
+		a, err := m.read(ops[0], size)
+		if err != nil {
+			return err
+		}
+		var v uint32
+		switch name {
+		case "INC", "DEC":
+			cf := m.flag(flagCF)
+			op := "ADD"
+			if name == "DEC" {
+				op = "SUB"
+			}
+			v = m.arith(op, a, 1, size)
+			m.setFlag(flagCF, cf)
+		case "NEG":
+			v = m.arith("SUB", 0, a, size)
+		case "NOT":
+			v = ^a & mask(size)
+		}
+		return m.write(ops[0], size, v)
+
+	case "SHL", "SHR", "SAR", "ROL", "ROR", "RCL", "RCR":
+		a, err := m.read(ops[0], size)
+		if err != nil {
+			return err
+		}
+		n, err := m.read(ops[1], 1)
+		if err != nil {
+			return err
+		}
+		return m.write(ops[0], size, m.shift(name, a, n&0x1F, size))
+
+	case "MUL", "IMUL", "DIV", "IDIV":
+		v, err := m.read(ops[0], size)
+		if err != nil {
+			return err
+		}
+		if name == "MUL" || name == "IMUL" {
+			m.multiply(name == "IMUL", v, size)
+			return nil
+		}
+		return m.divide(name == "IDIV", v, size)
+
+	case "CBW":
+		m.setReg(instruction.AX, signExtend(m.reg(instruction.AL), 1))
+	case "CWDE":
+		m.setReg(instruction.EAX, signExtend(m.reg(instruction.AX), 2))
+	case "CWD":
+		m.setReg(instruction.DX, signExtend(m.reg(instruction.AX), 2)>>16)
+	case "CDQ":
+		m.setReg(instruction.EDX, uint32(int32(m.reg(instruction.EAX))>>31))
+
+	case "CLC", "STC", "CMC", "CLD", "STD", "CLI", "STI":
+		switch name {
+		case "CLC", "STC":
+			m.setFlag(flagCF, name == "STC")
+		case "CMC":
+			m.setFlag(flagCF, !m.flag(flagCF))
+		case "CLD", "STD":
+			m.setFlag(flagDF, name == "STD")
+		case "CLI", "STI":
+			m.setFlag(flagIF, name == "STI")
+		}
+
+	case "PUSH":
+		v, err := m.read(ops[0], size)
+		if err != nil {
+			return err
+		}
+		m.push(v, size)
+
+	case "POP":
+		return m.write(ops[0], size, m.pop(size))
+
+	case "PUSHA", "PUSHAD":
+		sp := m.regs[4]
+		for i, r := range m.regs {
+			if i == 4 {
+				r = sp
+			}
+			m.push(r, size)
+		}
+
+	case "POPA", "POPAD":
+		for i := 7; i >= 0; i-- {
+			v := m.pop(size)
+			if i != 4 {
+				m.setReg(generalRegister(size, i), v)
+			}
+		}
+
+	case "PUSHF", "PUSHFD":
+		m.push(m.flags, size)
+
+	case "POPF", "POPFD":
+		m.flags = m.flags&^mask(size) | m.pop(size)&mask(size) | 0x0002
+
+	case "JMP", "CALL":
+		target := uint32(d.Target)
+		if !d.Relative {
+			v, err := m.read(ops[0], size)
+			if err != nil {
+				return err
+			}
+			target = v
+		}
+		if name == "CALL" {
+			m.push(uint32(m.ip), size)
+		}
+		m.ip = uint16(target)
+
+	case "RET", "RETF":
+		m.ip = uint16(m.pop(2))
+		if name == "RETF" {
+			m.sregs[1] = uint16(m.pop(2))
+		}
+		if len(ops) > 0 {
+			n, err := m.read(ops[0], 2)
+			if err != nil {
+				return err
+			}
+			m.setReg(instruction.SP, m.reg(instruction.SP)+n)
+		}
+
+	case "IRET":
+		m.ip = uint16(m.pop(2))
+		m.sregs[1] = uint16(m.pop(2))
+		m.flags = m.flags&^0xFFFF | m.pop(2) | 0x0002
+
+	case "INT", "INT3":
+		n := uint32(3)
+		if name == "INT" {
+			v, err := m.read(ops[0], 1)
+			if err != nil {
+				return err
+			}
+			n = v
+		}
+		return m.interrupt(byte(n))
+
+	case "LOOP", "LOOPE", "LOOPZ", "LOOPNE", "LOOPNZ", "JCXZ":
+		cx := m.reg(instruction.CX)
+		jump := cx == 0
+		if name != "JCXZ" {
+			cx = (cx - 1) & 0xFFFF
+			m.setReg(instruction.CX, cx)
+			jump = cx != 0
+			switch name {
+			case "LOOPE", "LOOPZ":
+				jump = jump && m.flag(flagZF)
+			case "LOOPNE", "LOOPNZ":
+				jump = jump && !m.flag(flagZF)
+			}
+		}
+		if jump {
+			m.ip = uint16(d.Target)
+		}
+
+	case "IN":
+		// 接続されているデバイスは無い
+		return m.write(ops[0], ops[0].(instruction.Register).Size(), 0xFFFFFFFF)
+
+	case "OUT":
+
+	case "MOVSB", "MOVSW", "MOVSD", "STOSB", "STOSW", "STOSD", "LODSB", "LODSW", "LODSD",
+		"CMPSB", "CMPSW", "CMPSD", "SCASB", "SCASW", "SCASD":
+		m.stringOp(name, rep)
+
+	default:
+		if strings.HasPrefix(name, "J") {
+			jump, ok := m.condition(name[1:])
+			if !ok {
+				return fmt.Errorf("unsupported instruction: %s", name)
+			}
+			if jump {
+				m.ip = uint16(d.Target)
+			}
+			return nil
+		}
+		return fmt.Errorf("unsupported instruction: %s", name)
+	}
+
+	return nil
+}
+
+// 命令のオペランドサイズ(バイト数)
+func operandSize(f *instruction.Form) int {
+
+	if len(f.Operands) > 0 {
+		switch f.Operands[0] {
+		case instruction.Imm8, instruction.Imm16, instruction.Imm32, instruction.SImm8,
+			instruction.Rel8, instruction.Rel16, instruction.Rel32:
+		default:
+			if n := f.Operands[0].Size(); n > 0 {
+				return n
+			}
+		}
+	}
+	if f.OpSize != 0 {
+		return f.OpSize / 8
+	}
+
+	// 文字列命令はニーモニックの末尾でサイズが決まる
+	switch f.Mnemonic[len(f.Mnemonic)-1] {
+	case 'B':
+		return 1
+	case 'D':
+		return 4
+	}
+	return 2
+}
+
+// オペランドを読み込む
+func (m *Machine) read(op instruction.Operand, size int) (uint32, error) {
+
+	switch op := op.(type) {
+
+	case instruction.Register:
+		return m.reg(op), nil
+
+	case instruction.Memory:
+		return m.load(m.address(op), size), nil
+
+	case instruction.Immediate:
+		v, ok := op.Value.Constant()
+		if !ok {
+			return 0, fmt.Errorf("internal: immediate isn't constant: %s", op)
+		}
+		return uint32(v) & mask(size), nil
+	}
+
+	return 0, fmt.Errorf("internal: %#v", op)
+}
+
+// オペランドに書き込む
+func (m *Machine) write(op instruction.Operand, size int, v uint32) error {
+
+	switch op := op.(type) {
+
+	case instruction.Register:
+		m.setReg(op, v)
+		return nil
+
+	case instruction.Memory:
+		m.store(m.address(op), size, v)
+		return nil
+	}
+
+	return fmt.Errorf("internal: can't write to %s", op)
+}
+
+// メモリオペランドのオフセット
+func (m *Machine) offset(mem instruction.Memory) uint32 {
+
+	var off uint32
+	if mem.Disp != nil {
+		v, _ := mem.Disp.Constant()
+		off = uint32(v)
+	}
+	addr32 := false
+	for _, r := range []instruction.Register{mem.Base, mem.Index} {
+		if r != instruction.NoRegister {
+			off += m.reg(r)
+			addr32 = r.Size() == 4
+		}
+	}
+	if !addr32 {
+		off &= 0xFFFF
+	}
+	return off
+}
+
+// メモリオペランドのリニアアドレス
+func (m *Machine) address(mem instruction.Memory) uint32 {
+
+	seg := mem.Segment
+	if seg == instruction.NoRegister {
+		seg = instruction.DS
+		switch mem.Base {
+		case instruction.BP, instruction.EBP, instruction.ESP:
+			seg = instruction.SS
+		}
+	}
+	return m.linear(uint16(m.reg(seg)), m.offset(mem))
+}
+
+// サイズに対応するビットマスク
+func mask(size int) uint32 {
+	return uint32(uint64(1)<<uint(size*8) - 1)
+}
+
+// 符号ビット
+func signBit(size int) uint32 {
+	return 1 << uint(size*8-1)
+}
+
+// 符号拡張する
+func signExtend(v uint32, size int) uint32 {
+	shift := uint(32 - size*8)
+	return uint32(int32(v<<shift) >> shift)
+}
+
+// 指定したサイズとレジスタ番号の汎用レジスタ
+func generalRegister(size int, n int) instruction.Register {
+	switch size {
+	case 1:
+		return instruction.AL + instruction.Register(n)
+	case 2:
+		return instruction.AX + instruction.Register(n)
+	}
+	return instruction.EAX + instruction.Register(n)
+}
+
+// 演算結果に応じてSF, ZF, PFを設定する
+func (m *Machine) setResultFlags(v uint32, size int) {
+	m.setFlag(flagZF, v&mask(size) == 0)
+	m.setFlag(flagSF, v&signBit(size) != 0)
+	m.setFlag(flagPF, bits.OnesCount8(uint8(v))%2 == 0)
+}
+
+// 算術演算、論理演算を行いフラグを設定する
+func (m *Machine) arith(name string, a, b uint32, size int) uint32 {
+
+	var (
+		msk   = mask(size)
+		sign  = signBit(size)
+		carry uint64
+		v     uint32
+	)
+	switch name {
+
+	case "ADD", "ADC":
+		if name == "ADC" && m.flag(flagCF) {
+			carry = 1
+		}
+		r := uint64(a) + uint64(b) + carry
+		v = uint32(r) & msk
+		m.setFlag(flagCF, r > uint64(msk))
+		m.setFlag(flagOF, (a^v)&(b^v)&sign != 0)
+		m.setFlag(flagAF, (a^b^v)&0x10 != 0)
+
+	case "SUB", "SBB", "CMP":
+		if name == "SBB" && m.flag(flagCF) {
+			carry = 1
+		}
+		v = uint32(uint64(a)-uint64(b)-carry) & msk
+		m.setFlag(flagCF, uint64(a) < uint64(b)+carry)
+		m.setFlag(flagOF, (a^b)&(a^v)&sign != 0)
+		m.setFlag(flagAF, (a^b^v)&0x10 != 0)
+
+	default:
+		switch name {
+		case "AND", "TEST":
+			v = a & b
+		case "OR":
+			v = a | b
+		case "XOR":
+			v = a ^ b
+		}
+		m.setFlag(flagCF, false)
+		m.setFlag(flagOF, false)
+		m.setFlag(flagAF, false)
+	}
+
+	m.setResultFlags(v, size)
+	return v
+}
+
+// シフト、ローテートを行いフラグを設定する
+func (m *Machine) shift(name string, a, n uint32, size int) uint32 {
+
+	if n == 0 {
+		return a
+	}
+
+	var (
+		width = uint32(size * 8)
+		msk   = mask(size)
+		sign  = signBit(size)
+		v     uint32
+		cf    bool
+	)
+	switch name {
+
+	case "SHL":
+		v = uint32(uint64(a)<<n) & msk
+		cf = n <= width && a>>(width-n)&1 != 0
+		m.setFlag(flagOF, (v&sign != 0) != cf)
+
+	case "SHR":
+		v = uint32(uint64(a) >> n)
+		cf = n <= width && a>>(n-1)&1 != 0
+		m.setFlag(flagOF, a&sign != 0)
+
+	case "SAR":
+		s := int64(int32(signExtend(a, size)))
+		v = uint32(s>>n) & msk
+		cf = s>>(n-1)&1 != 0
+		m.setFlag(flagOF, false)
+
+	case "ROL", "ROR":
+		r := n % width
+		v = a
+		if r != 0 {
+			if name == "ROL" {
+				v = (a<<r | a>>(width-r)) & msk
+			} else {
+				v = (a>>r | a<<(width-r)) & msk
+			}
+		}
+		if name == "ROL" {
+			cf = v&1 != 0
+			m.setFlag(flagOF, (v&sign != 0) != cf)
+		} else {
+			cf = v&sign != 0
+			m.setFlag(flagOF, (v&sign != 0) != (v&(sign>>1) != 0))
+		}
+		m.setFlag(flagCF, cf)
+		return v
+
+	case "RCL", "RCR":
+		v, cf = a, m.flag(flagCF)
+		for i := n % (width + 1); i > 0; i-- {
+			if name == "RCL" {
+				out := v&sign != 0
+				v = (v<<1)&msk | b2u(cf)
+				cf = out
+			} else {
+				out := v&1 != 0
+				v = v>>1 | b2u(cf)<<(width-1)
+				cf = out
+			}
+		}
+		if name == "RCL" {
+			m.setFlag(flagOF, (v&sign != 0) != cf)
+		} else {
+			m.setFlag(flagOF, (v&sign != 0) != (v&(sign>>1) != 0))
+		}
+		m.setFlag(flagCF, cf)
+		return v
+	}
+
+	m.setFlag(flagCF, cf)
+	m.setResultFlags(v, size)
+	return v
+}
+
+func b2u(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// MUL / IMUL
+func (m *Machine) multiply(signed bool, v uint32, size int) {
+
+	var (
+		a        = m.reg(generalRegister(size, 0))
+		r        uint64
+		overflow bool
+	)
+	if signed {
+		sr := int64(int32(signExtend(a, size))) * int64(int32(signExtend(v, size)))
+		r = uint64(sr)
+		overflow = int64(int32(signExtend(uint32(r)&mask(size), size))) != sr
+	} else {
+		r = uint64(a) * uint64(v)
+		overflow = r>>uint(size*8) != 0
+	}
+
+	width := uint(size * 8)
+	lo, hi := uint32(r)&mask(size), uint32(r>>width)&mask(size)
+	if size == 1 {
+		m.setReg(instruction.AX, hi<<8|lo)
+	} else {
+		m.setReg(generalRegister(size, 0), lo)
+		m.setReg(generalRegister(size, 2), hi)
+	}
+
+	m.setFlag(flagCF, overflow)
+	m.setFlag(flagOF, overflow)
+}
+
+// DIV / IDIV
+func (m *Machine) divide(signed bool, v uint32, size int) error {
+
+	if v == 0 {
+		return ErrDivide
+	}
+
+	width := uint(size * 8)
+	var dividend uint64
+	if size == 1 {
+		dividend = uint64(m.reg(instruction.AX))
+	} else {
+		dividend = uint64(m.reg(generalRegister(size, 2)))<<width | uint64(m.reg(generalRegister(size, 0)))
+	}
+
+	var q, r uint64
+	if signed {
+		shift := 64 - 2*width
+		n := int64(dividend<<shift) >> shift
+		d := int64(int32(signExtend(v, size)))
+		sq, sr := n/d, n%d
+		min, max := -int64(1)<<(width-1), int64(1)<<(width-1)-1
+		if sq < min || sq > max {
+			return ErrDivide
+		}
+		q, r = uint64(sq), uint64(sr)
+	} else {
+		q, r = dividend/uint64(v), dividend%uint64(v)
+		if q > uint64(mask(size)) {
+			return ErrDivide
+		}
+	}
+
+	if size == 1 {
+		m.setReg(instruction.AL, uint32(q))
+		m.setReg(instruction.AH, uint32(r))
+	} else {
+		m.setReg(generalRegister(size, 0), uint32(q))
+		m.setReg(generalRegister(size, 2), uint32(r))
+	}
+	return nil
+}
+
+// 条件ジャンプの条件を判定する
+//
+// @param cc --- 条件 (JEならE)
+//
+// @return 条件を満たすかどうか、条件が正しいかどうか
+func (m *Machine) condition(cc string) (bool, bool) {
+
+	var (
+		cf = m.flag(flagCF)
+		zf = m.flag(flagZF)
+		sf = m.flag(flagSF)
+		of = m.flag(flagOF)
+		pf = m.flag(flagPF)
+	)
+	switch cc {
+	case "O":
+		return of, true
+	case "NO":
+		return !of, true
+	case "B", "C", "NAE":
+		return cf, true
+	case "AE", "NB", "NC":
+		return !cf, true
+	case "E", "Z":
+		return zf, true
+	case "NE", "NZ":
+		return !zf, true
+	case "BE", "NA":
+		return cf || zf, true
+	case "A", "NBE":
+		return !cf && !zf, true
+	case "S":
+		return sf, true
+	case "NS":
+		return !sf, true
+	case "P", "PE":
+		return pf, true
+	case "NP", "PO":
+		return !pf, true
+	case "L", "NGE":
+		return sf != of, true
+	case "GE", "NL":
+		return sf == of, true
+	case "LE", "NG":
+		return zf || sf != of, true
+	case "G", "NLE":
+		return !zf && sf == of, true
+	}
+	return false, false
+}
+
+// 文字列命令
+// DS:SIが転送元、ES:DIが転送先 REPプレフィックスがあればCXの回数だけ繰り返す
+func (m *Machine) stringOp(name string, rep byte) {
+
+	size := operandSize(&instruction.Form{Mnemonic: name})
+	step := uint32(size)
+	if m.flag(flagDF) {
+		step = -step
+	}
+	op := name[:4]
+
+	for {
+		if rep != 0 {
+			if m.reg(instruction.CX) == 0 {
+				return
+			}
+		}
+
+		var (
+			si  = m.reg(instruction.SI)
+			di  = m.reg(instruction.DI)
+			src = m.linear(m.sregs[3], si)
+			dst = m.linear(m.sregs[0], di)
+			acc = generalRegister(size, 0)
+		)
+		switch op {
+		case "MOVS":
+			m.store(dst, size, m.load(src, size))
+			m.setReg(instruction.SI, si+step)
+			m.setReg(instruction.DI, di+step)
+		case "STOS":
+			m.store(dst, size, m.reg(acc))
+			m.setReg(instruction.DI, di+step)
+		case "LODS":
+			m.setReg(acc, m.load(src, size))
+			m.setReg(instruction.SI, si+step)
+		case "CMPS":
+			m.arith("CMP", m.load(src, size), m.load(dst, size), size)
+			m.setReg(instruction.SI, si+step)
+			m.setReg(instruction.DI, di+step)
+		case "SCAS":
+			m.arith("CMP", m.reg(acc), m.load(dst, size), size)
+			m.setReg(instruction.DI, di+step)
+		}
+
+		if rep == 0 {
+			return
+		}
+		m.setReg(instruction.CX, m.reg(instruction.CX)-1)
+
+		// REPE / REPNE
+		if op == "CMPS" || op == "SCAS" {
+			if rep == 0xF3 && !m.flag(flagZF) || rep == 0xF2 && m.flag(flagZF) {
+				return
+			}
+		}
+	}
+}
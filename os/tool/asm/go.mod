@@ -6,4 +6,5 @@ require (
 	github.com/shopspring/decimal v0.0.0-20180709203117-cd690d0c9e24
 	go.nanasi880.dev/rpn v1.0.4
 	go.nanasi880.dev/xtesting v0.1.0
+	golang.org/x/text v0.13.0
 )
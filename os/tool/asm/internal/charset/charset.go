@@ -0,0 +1,37 @@
+// Package charset : ソースコード及び文字列リテラルの文字コードの取り扱い
+package charset
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// 文字コード名とエンコーディングの対応表
+// 名前は小文字で正規化した上で比較される
+var encodings = map[string]encoding.Encoding{
+	"utf-8":     encoding.Nop,
+	"utf8":      encoding.Nop,
+	"shift_jis": japanese.ShiftJIS,
+	"shift-jis": japanese.ShiftJIS,
+	"sjis":      japanese.ShiftJIS,
+	"euc-jp":    japanese.EUCJP,
+	"eucjp":     japanese.EUCJP,
+}
+
+// 文字コード名からエンコーディングを取得する
+// UTF-8の場合はバイト列を変換しないencoding.Nopを返す
+//
+// @param name --- 文字コード名 utf-8, shift_jis, euc-jp のいずれか
+//
+// @return エンコーディング、エラー
+func Lookup(name string) (encoding.Encoding, error) {
+
+	e, ok := encodings[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported encoding: %s", name)
+	}
+	return e, nil
+}
@@ -0,0 +1,139 @@
+// Package outfile : 出力ファイルを一時ファイル経由で書き込み、成功した場合だけ置き換える
+package outfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// 出力ファイル
+//
+// 通常のファイルへの出力は同じディレクトリに作成した一時ファイルに書き込み、Commit()でリネームする
+// Commit()せずにAbort()した場合は一時ファイルを削除し、元のファイルには触れない
+// デバイスや名前付きパイプなど、通常のファイル以外への出力は直接書き込む
+type File struct {
+	f         *os.File
+	name      string // 出力先のファイル名
+	temporary bool   // 一時ファイルに書き込んでいるかどうか
+	done      bool   // Commit()またはAbort()済みかどうか
+}
+
+// 出力ファイルを作成する
+// 既存のファイルを置き換える場合はそのパーミッションを引き継ぐ 新規の場合は0644とする
+// シンボリックリンクの場合はリンク先のファイルを置き換える
+//
+// @param name --- 出力先のファイル名
+//
+// @return 出力ファイル、エラー
+func Create(name string) (*File, error) {
+
+	if resolved, err := filepath.EvalSymlinks(name); err == nil {
+		name = resolved
+	}
+
+	mode := os.FileMode(0644)
+	info, err := os.Stat(name)
+	switch {
+	case err == nil && !info.Mode().IsRegular():
+		f, err := os.OpenFile(name, os.O_WRONLY, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &File{f: f, name: name}, nil
+
+	case err == nil:
+		mode = info.Mode().Perm()
+
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Chmod(mode); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, err
+	}
+
+	return &File{f: f, name: name, temporary: true}, nil
+}
+
+// 出力先のファイル名
+func (f *File) Name() string {
+	return f.name
+}
+
+// 新しく作成した一時ファイルに書き込んでいるかどうか
+// デバイスなど通常のファイル以外へ直接書き込んでいる場合はfalse
+func (f *File) Temporary() bool {
+	return f.temporary
+}
+
+// io.Writerの実装
+func (f *File) Write(p []byte) (int, error) {
+	return f.f.Write(p)
+}
+
+// io.Seekerの実装
+// パイプなどシークできない出力先ではエラーを返す
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	return f.f.Seek(offset, whence)
+}
+
+// ファイルのサイズを変更する
+func (f *File) Truncate(size int64) error {
+	return f.f.Truncate(size)
+}
+
+// 書き込みを確定し、一時ファイルを出力先にリネームする
+// 失敗した場合は一時ファイルを削除する
+//
+// @return エラー
+func (f *File) Commit() error {
+
+	if f.done {
+		return os.ErrClosed
+	}
+	f.done = true
+
+	if !f.temporary {
+		return f.f.Close()
+	}
+
+	temp := f.f.Name()
+	err := f.f.Sync()
+	if closeErr := f.f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp, f.name)
+	}
+	if err != nil {
+		_ = os.Remove(temp)
+	}
+	return err
+}
+
+// 書き込みを破棄し、一時ファイルを削除する
+// Commit()またはAbort()済みの場合は何もしない
+//
+// @return エラー
+func (f *File) Abort() error {
+
+	if f.done {
+		return nil
+	}
+	f.done = true
+
+	err := f.f.Close()
+	if f.temporary {
+		if removeErr := os.Remove(f.f.Name()); err == nil {
+			err = removeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,172 @@
+package outfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// ディレクトリに一時ファイルが残っていないこと
+func assertNoTemporary(t *testing.T, dir string) {
+
+	t.Helper()
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) > 1 {
+		t.Fatal(files)
+	}
+}
+
+// 既存のファイルより短い内容で置き換えても古い内容が残らず、パーミッションが引き継がれること
+func TestFile_Commit(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "outfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "boot.img")
+	if err := ioutil.WriteFile(name, []byte("stale content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(name); string(b) != "stale content" {
+		t.Fatalf("replaced before Commit: %q", b)
+	}
+	if err := f.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Abort(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "new" {
+		t.Fatalf("%q", b)
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Fatal(info.Mode())
+	}
+	assertNoTemporary(t, dir)
+}
+
+// 新規に作成する場合は0644になり、Commit()まで出力先が作られないこと
+func TestFile_CommitNew(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "outfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "boot.img")
+
+	f, err := Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	if err := f.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0644 {
+		t.Fatal(info.Mode())
+	}
+	if info.Size() != 0 {
+		t.Fatal(info.Size())
+	}
+	assertNoTemporary(t, dir)
+}
+
+// Abort()した場合は元のファイルがそのまま残り、一時ファイルが削除されること
+func TestFile_Abort(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "outfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "boot.img")
+	if err := ioutil.WriteFile(name, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("half-written")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Commit(); err == nil {
+		t.Fatal("Commit after Abort")
+	}
+
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "original" {
+		t.Fatalf("%q", b)
+	}
+	assertNoTemporary(t, dir)
+}
+
+// 通常のファイル以外には直接書き込むこと
+func TestFile_Device(t *testing.T) {
+
+	info, err := os.Stat(os.DevNull)
+	if err != nil || info.Mode().IsRegular() {
+		t.Skip(os.DevNull, "is not available")
+	}
+
+	f, err := Create(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("discarded")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err = os.Stat(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().IsRegular() {
+		t.Fatal(info.Mode())
+	}
+}
@@ -0,0 +1,107 @@
+// Package patch : 既存のイメージの一部をその場で書き換える
+package patch
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+// 書き換える対象のイメージ (*os.Fileなど)
+type Image interface {
+	io.ReaderAt
+	io.WriterAt
+	Stat() (os.FileInfo, error)
+}
+
+// 書き換えの結果
+type Result struct {
+	Offset int64  // 書き換えた位置 イメージの先頭からのオフセット
+	Before []byte // 書き換える前の内容 イメージの末尾を超える部分は含まない
+	After  []byte // 書き換えた後の内容
+	Size   int64  // 書き換える前のイメージのバイト数
+}
+
+// イメージを伸ばしたかどうか
+func (r *Result) Grown() bool {
+	return r.Offset+int64(len(r.After)) > r.Size
+}
+
+// イメージの指定した位置をdataで書き換える
+// 範囲外のバイトには触れない
+//
+// @param image --- イメージ
+// @param at    --- 書き換える位置
+// @param data  --- 書き込む内容
+// @param grow  --- trueならイメージの末尾を超える書き込みを許し、イメージを伸ばす 間は0で埋められる
+//
+// @return 書き換えの結果、エラー
+func Apply(image Image, at int64, data []byte, grow bool) (*Result, error) {
+
+	if at < 0 {
+		return nil, fmt.Errorf("negative patch offset: %d", at)
+	}
+	info, err := image.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	if end := at + int64(len(data)); end > size && !grow {
+		return nil, fmt.Errorf("patch 0x%X-0x%X exceeds the image size 0x%X (use -grow to extend the image)", at, end, size)
+	}
+
+	// 書き換える前の内容
+	n := int64(len(data))
+	if rest := size - at; rest < n {
+		n = rest
+	}
+	if n < 0 {
+		n = 0
+	}
+	before := make([]byte, n)
+	if _, err := image.ReadAt(before, at); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if _, err := image.WriteAt(data, at); err != nil {
+		return nil, err
+	}
+
+	return &Result{Offset: at, Before: before, After: append([]byte(nil), data...), Size: size}, nil
+}
+
+// 書き換える前後の内容を16バイト毎に並べて出力する
+// 内容が変わらなかった行も含めて出力し、イメージの末尾を超えていた部分は--とする
+//
+//	-0x000060  B8 00 00 8E D0 BC 00 7C
+//	+0x000060  B8 00 10 8E D0 BC 00 7C
+//
+// @param w --- 出力先
+//
+// @return エラー
+func (r *Result) Dump(w io.Writer) error {
+
+	bw := bufio.NewWriter(w)
+	for i := 0; i < len(r.After); i += 16 {
+		end := i + 16
+		if end > len(r.After) {
+			end = len(r.After)
+		}
+		for _, side := range []struct {
+			sign byte
+			b    []byte
+		}{{'-', r.Before}, {'+', r.After}} {
+			fmt.Fprintf(bw, "%c0x%06X ", side.sign, r.Offset+int64(i))
+			for j := i; j < end; j++ {
+				if j < len(side.b) {
+					fmt.Fprintf(bw, " %02X", side.b[j])
+				} else {
+					bw.WriteString(" --")
+				}
+			}
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
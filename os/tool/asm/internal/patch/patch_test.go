@@ -0,0 +1,98 @@
+package patch
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"go.nanasi880.dev/xtesting"
+)
+
+func TestApply(t *testing.T) {
+
+	f, err := ioutil.TempFile("", "patch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer xtesting.MustClose(t, f)
+	if _, err := f.Write([]byte{0, 1, 2, 3, 4, 5, 6, 7}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Apply(f, 2, []byte{0xAA, 0xBB}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ioutil.ReadFile(f.Name()); !bytes.Equal(got, []byte{0, 1, 0xAA, 0xBB, 4, 5, 6, 7}) {
+		t.Fatalf("% x", got)
+	}
+	if result.Offset != 2 || !bytes.Equal(result.Before, []byte{2, 3}) || !bytes.Equal(result.After, []byte{0xAA, 0xBB}) || result.Grown() {
+		t.Fatal(result)
+	}
+
+	b := new(bytes.Buffer)
+	if err := result.Dump(b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "-0x000002  02 03\n+0x000002  AA BB\n" {
+		t.Fatal(b.String())
+	}
+}
+
+// 末尾を超える書き込みは-growが無ければ何も書き換えずに失敗する
+func TestApply_Grow(t *testing.T) {
+
+	f, err := ioutil.TempFile("", "patch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer xtesting.MustClose(t, f)
+	if _, err := f.Write([]byte{0, 1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Apply(f, 3, []byte{0xAA, 0xBB}, false); err == nil {
+		t.Fatal("grown without -grow")
+	}
+	if _, err := Apply(f, -1, []byte{0xAA}, true); err == nil {
+		t.Fatal("negative offset")
+	}
+	if got, _ := ioutil.ReadFile(f.Name()); !bytes.Equal(got, []byte{0, 1, 2, 3}) {
+		t.Fatalf("% x", got)
+	}
+
+	g, err := ioutil.TempFile("", "patch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(g.Name())
+	defer xtesting.MustClose(t, g)
+	if _, err := g.Write([]byte{0, 1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Apply(g, 6, bytes.Repeat([]byte{0xAA}, 17), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte{0, 1, 2, 3, 0, 0}, bytes.Repeat([]byte{0xAA}, 17)...)
+	if got, _ := ioutil.ReadFile(g.Name()); !bytes.Equal(got, want) {
+		t.Fatalf("% x", got)
+	}
+	if !result.Grown() || len(result.Before) != 0 || result.Size != 4 {
+		t.Fatal(result)
+	}
+
+	b := new(bytes.Buffer)
+	if err := result.Dump(b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "-0x000006  -- -- -- -- -- -- -- -- -- -- -- -- -- -- -- --\n"+
+		"+0x000006  AA AA AA AA AA AA AA AA AA AA AA AA AA AA AA AA\n"+
+		"-0x000016  --\n+0x000016  AA\n" {
+		t.Fatal(b.String())
+	}
+}
@@ -0,0 +1,298 @@
+// Package repl : 1行ずつアセンブルして結果を表示する対話的な環境
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler"
+	"github.com/nanasi880/til/os/tool/asm/assembler/expr"
+)
+
+// 入力された行を蓄積したセッション
+// 行を追加する度に蓄積した全体をアセンブルし直すので、ラベルやEQUの定数、BITSは以降の行に引き継がれる
+type Session struct {
+	origin    int64                // ORGの初期値 .orgで変更する
+	bits      int                  // BITSの初期値
+	includeFS assembler.FileSystem // INCLUDE命令でファイルを読み込むファイルシステム
+	lines     []string             // アセンブルに成功した行
+	pending   []string             // 入力中のマクロ定義 %macroから%endmacroまでをまとめて追加する
+	image     []byte               // 直前のアセンブル結果
+	result    *assembler.Result    // 直前のアセンブル結果
+}
+
+// 新しいセッションを作成する
+//
+// @param origin    --- ORGの初期値
+// @param bits      --- BITSの初期値 16 or 32
+// @param includeFS --- INCLUDE命令でファイルを読み込むファイルシステム nilならINCLUDE命令は使用できない
+//
+// @return セッション
+func New(origin int64, bits int, includeFS assembler.FileSystem) *Session {
+	s := &Session{origin: origin, bits: bits, includeFS: includeFS}
+	s.result = &assembler.Result{SourceMap: &assembler.SourceMap{Origin: origin}}
+	return s
+}
+
+// 蓄積したバイト列
+func (s *Session) Bytes() []byte {
+	return s.image
+}
+
+// 次の行が配置されるアドレス
+func (s *Session) Address() int64 {
+	return s.result.SourceMap.Origin + int64(len(s.image))
+}
+
+// 1行の入力の結果
+type Output struct {
+	Address int64    // 行が出力したバイト列の先頭アドレス
+	Bytes   []byte   // 行が出力したバイト列
+	Symbols []string // 行で新しく定義されたラベルと定数の名前
+	Changed bool     // 以前の行が出力したバイト列が変わったかどうか
+}
+
+// 1行を追加してアセンブルする
+// 失敗した場合は行を追加せず、以前の状態のままとする
+//
+// @param line --- ソースコードの1行
+//
+// @return 結果、エラー
+func (s *Session) Assemble(line string) (*Output, error) {
+	return s.assemble(append(s.lines[:len(s.lines):len(s.lines)], line), s.origin)
+}
+
+// 行を指定してアセンブルし直し、成功すれば状態を置き換える
+func (s *Session) assemble(lines []string, origin int64) (*Output, error) {
+
+	b := new(bytes.Buffer)
+	a := assembler.New(
+		assembler.WithOrigin(origin),
+		assembler.WithBits(s.bits),
+		assembler.WithIncludeFS(s.includeFS),
+	)
+	result, err := a.Exec(strings.NewReader(strings.Join(lines, "\n")), b)
+	if err != nil {
+		// 入力したばかりの行のエラーであれば、蓄積した行の中の行番号は表示しない
+		var e *assembler.Error
+		if errors.As(err, &e) && e.File == "" && e.Line > len(s.lines) {
+			return nil, e.Err
+		}
+		return nil, err
+	}
+
+	// 行を追加しても以前の行の出力は通常変わらないので、末尾に増えた分をその行の出力とする
+	old := s.image
+	output := &Output{Address: result.SourceMap.Origin + int64(len(old))}
+	if b.Len() >= len(old) && bytes.Equal(b.Bytes()[:len(old)], old) {
+		output.Bytes = b.Bytes()[len(old):]
+	} else {
+		output.Address = result.SourceMap.Origin
+		output.Bytes = b.Bytes()
+		output.Changed = true
+	}
+	for name := range result.Symbols {
+		if _, ok := s.result.Symbols[name]; !ok {
+			output.Symbols = append(output.Symbols, name)
+		}
+	}
+	sort.Strings(output.Symbols)
+
+	s.lines, s.origin, s.image, s.result = lines, origin, b.Bytes(), result
+	return output, nil
+}
+
+// 蓄積した行とバイト列を破棄する ORGとBITSの初期値は維持する
+func (s *Session) Reset() {
+	s.lines, s.pending, s.image = nil, nil, nil
+	s.result = &assembler.Result{SourceMap: &assembler.SourceMap{Origin: s.origin}}
+}
+
+// ORGの初期値を変更し、蓄積した行を新しいアドレスでアセンブルし直す
+//
+// @param origin --- アドレス
+//
+// @return エラー
+func (s *Session) SetOrigin(origin int64) error {
+	if len(s.lines) == 0 {
+		s.origin = origin
+		s.result.SourceMap.Origin = origin
+		return nil
+	}
+	_, err := s.assemble(s.lines, origin)
+	return err
+}
+
+// .で始まるコマンドの説明
+const help = `.reset         discard every line and byte entered so far
+.org ADDRESS   set the origin and reassemble the buffer at the new address
+.bits 16|32    switch the mode for the following lines (same as BITS)
+.symbols       list the labels and EQU constants
+.list          list the lines entered so far
+.dump [FILE]   write the buffer to FILE, or print it as hex
+.quit          leave the REPL
+`
+
+// 表示するバイト数の上限 RESBのように大きな出力はこれを超えた分を省略する
+const maxPrintBytes = 64
+
+// 入力を1行ずつ読み、アセンブル結果を表示する
+// .で始まる行はコマンドとして扱う アセンブルやコマンドのエラーは表示して続行する
+//
+// @param in     --- 入力
+// @param out    --- 出力
+// @param prompt --- 次のアドレスを示すプロンプトを表示するかどうか
+//
+// @return 入力のエラー
+func (s *Session) Run(in io.Reader, out io.Writer, prompt bool) error {
+
+	scanner := bufio.NewScanner(in)
+	for {
+		if prompt {
+			if len(s.pending) > 0 {
+				fmt.Fprint(out, "...> ")
+			} else {
+				fmt.Fprintf(out, "%04X> ", s.Address())
+			}
+		}
+		if !scanner.Scan() {
+			return scanner.Err()
+		}
+		line := scanner.Text()
+
+		quit, err := s.input(line, out)
+		if err != nil {
+			fmt.Fprintln(out, "error:", err)
+		}
+		if quit {
+			return nil
+		}
+	}
+}
+
+// 1行の入力を処理する
+//
+// @return .quitが入力されたかどうか、エラー
+func (s *Session) input(line string, out io.Writer) (bool, error) {
+
+	text := strings.TrimSpace(line)
+
+	// マクロ定義は%endmacroまでまとめてアセンブルする
+	if len(s.pending) > 0 || strings.HasPrefix(text, "%macro") {
+		s.pending = append(s.pending, line)
+		if !strings.HasPrefix(text, "%endmacro") {
+			return false, nil
+		}
+		lines := s.pending
+		s.pending = nil
+		_, err := s.assemble(append(s.lines[:len(s.lines):len(s.lines)], lines...), s.origin)
+		return false, err
+	}
+
+	if !strings.HasPrefix(text, ".") {
+		output, err := s.Assemble(line)
+		if err != nil {
+			return false, err
+		}
+		s.print(out, output)
+		return false, nil
+	}
+
+	fields := strings.Fields(text)
+	command, args := fields[0], fields[1:]
+	switch {
+	case command == ".quit" || command == ".exit":
+		return true, nil
+	case command == ".help":
+		fmt.Fprint(out, help)
+	case command == ".reset" && len(args) == 0:
+		s.Reset()
+	case command == ".org" && len(args) == 1:
+		origin, err := expr.ParseNumber(args[0])
+		if err != nil {
+			return false, err
+		}
+		return false, s.SetOrigin(origin)
+	case command == ".bits" && len(args) == 1:
+		if _, err := strconv.Atoi(args[0]); err != nil {
+			return false, fmt.Errorf(".bits needs 16 or 32: %s", args[0])
+		}
+		_, err := s.Assemble("BITS " + args[0])
+		return false, err
+	case command == ".symbols" && len(args) == 0:
+		s.printSymbols(out)
+	case command == ".list" && len(args) == 0:
+		for i, l := range s.lines {
+			fmt.Fprintf(out, "%4d  %s\n", i+1, l)
+		}
+	case command == ".dump" && len(args) == 0:
+		dump(out, s.result.SourceMap.Origin, s.image, 0)
+	case command == ".dump" && len(args) == 1:
+		if err := ioutil.WriteFile(args[0], s.image, 0644); err != nil {
+			return false, err
+		}
+		fmt.Fprintf(out, "%d bytes written to %s\n", len(s.image), args[0])
+	default:
+		return false, fmt.Errorf("unknown command: %s (.help for the list)", text)
+	}
+	return false, nil
+}
+
+// 1行の結果を表示する
+func (s *Session) print(out io.Writer, output *Output) {
+	if output.Changed {
+		fmt.Fprintln(out, "(the bytes of earlier lines changed; showing the whole buffer)")
+	}
+	dump(out, output.Address, output.Bytes, maxPrintBytes)
+	for _, name := range output.Symbols {
+		fmt.Fprintf(out, "%s = 0x%X\n", name, s.result.Symbols[name])
+	}
+}
+
+// ラベルと定数をアドレス順に表示する
+func (s *Session) printSymbols(out io.Writer) {
+
+	names := make([]string, 0, len(s.result.Symbols))
+	for name := range s.result.Symbols {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if s.result.Symbols[names[i]] != s.result.Symbols[names[j]] {
+			return s.result.Symbols[names[i]] < s.result.Symbols[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		fmt.Fprintf(out, "%s = 0x%X\n", name, s.result.Symbols[name])
+	}
+}
+
+// バイト列をアドレスと共に1行16バイトで表示する
+//
+// @param address --- 先頭のアドレス
+// @param b       --- バイト列
+// @param max     --- 表示するバイト数の上限 0なら全て
+func dump(out io.Writer, address int64, b []byte, max int) {
+
+	n := len(b)
+	if max > 0 && n > max {
+		n = max
+	}
+	for i := 0; i < n; i += 16 {
+		end := i + 16
+		if end > n {
+			end = n
+		}
+		fmt.Fprintf(out, "%04X  % X\n", address+int64(i), b[i:end])
+	}
+	if n < len(b) {
+		fmt.Fprintf(out, "...   (%d bytes)\n", len(b))
+	}
+}
@@ -0,0 +1,113 @@
+package repl
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSession_Run(t *testing.T) {
+
+	input := `SIZE EQU 3
+MOV AX, SIZE
+loop:
+JMP loop
+%macro TWICE 1
+DB %1, %1
+%endmacro
+TWICE 7
+.bits 32
+MOV EAX, 1
+DB 1 +
+.symbols
+.foo
+`
+	want := `SIZE = 0x3
+7C00  B8 03 00
+loop = 0x7C03
+7C03  EB FE
+7C05  07 07
+7C07  B8 01 00 00 00
+error: 1+: unexpected end of expression
+SIZE = 0x3
+loop = 0x7C03
+error: unknown command: .foo (.help for the list)
+`
+
+	s := New(0x7c00, 16, nil)
+	out := new(bytes.Buffer)
+	if err := s.Run(strings.NewReader(input), out, false); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != want {
+		t.Fatal(out.String())
+	}
+	if !bytes.Equal(s.Bytes(), []byte{0xB8, 3, 0, 0xEB, 0xFE, 7, 7, 0xB8, 1, 0, 0, 0}) || s.Address() != 0x7c0c {
+		t.Fatalf("% X 0x%X", s.Bytes(), s.Address())
+	}
+
+	// .orgは蓄積した行を新しいアドレスでアセンブルし直す
+	out.Reset()
+	if err := s.Run(strings.NewReader(".org 0x100\n.dump\nRESB 100\n.quit\nDB 1\n"), out, false); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out.String(), "0100  B8 03 00 EB FE 07 07 B8 01 00 00 00\n010C  00 00 ") || !strings.HasSuffix(out.String(), "...   (100 bytes)\n") {
+		t.Fatal(out.String())
+	}
+	if s.Address() != 0x170 {
+		t.Fatalf("0x%X", s.Address())
+	}
+
+	// .resetはORGの初期値を維持して全て破棄する
+	out.Reset()
+	if err := s.Run(strings.NewReader(".reset\nJMP loop\nDB 1\n.list\n"), out, false); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "error: undeclared variable: loop\n0100  01\n   1  DB 1\n" {
+		t.Fatal(out.String())
+	}
+}
+
+// ラベルに続けて書いた命令もアセンブルされる
+func TestSession_Label(t *testing.T) {
+
+	input := "start: MOV AX, 1\nmsg: DB \"hi\", 0\n"
+	want := "7C00  B8 01 00\nstart = 0x7C00\n7C03  68 69 00\nmsg = 0x7C03\n"
+
+	s := New(0x7c00, 16, nil)
+	out := new(bytes.Buffer)
+	if err := s.Run(strings.NewReader(input), out, false); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != want {
+		t.Fatal(out.String())
+	}
+}
+
+func TestSession_Dump(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "repl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	name := filepath.Join(dir, "out.bin")
+
+	s := New(0, 16, nil)
+	out := new(bytes.Buffer)
+	if err := s.Run(strings.NewReader("DB \"hi\", 0\n.dump "+name+"\n"), out, true); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "0000> 0000  68 69 00\n0003> 3 bytes written to "+name+"\n0003> " {
+		t.Fatal(out.String())
+	}
+	b, err := ioutil.ReadFile(name)
+	if err != nil || string(b) != "hi\x00" {
+		t.Fatal(b, err)
+	}
+}
@@ -0,0 +1,87 @@
+// Package watch : ファイルの変更を監視する
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// エディタの保存は書き込みやリネームなど複数のイベントになるので、最初のイベントからこの時間だけ待ってから戻る
+const settleTime = 100 * time.Millisecond
+
+// ファイルの状態
+type state struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// ファイルの状態を取得する
+func stat(name string) state {
+	info, err := os.Stat(name)
+	if err != nil {
+		return state{}
+	}
+	return state{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
+
+// 監視するファイルと、それを読み込んだ時点の状態
+// ゼロ値で使用できる
+type Snapshot struct {
+	names  []string
+	states map[string]state
+}
+
+// 監視するファイルを追加する
+// ファイルを読み込む直前に呼ぶことで、読み込んでからWait()までの間の変更も検出できる
+// 存在しないファイルも追加でき、作成されると変更とみなす
+//
+// @param name --- ファイル名
+func (s *Snapshot) Add(name string) {
+	name = filepath.Clean(name)
+	if _, ok := s.states[name]; ok {
+		return
+	}
+	if s.states == nil {
+		s.states = make(map[string]state)
+	}
+	s.names = append(s.names, name)
+	s.states[name] = stat(name)
+}
+
+// 監視するファイルの一覧 追加した順
+func (s *Snapshot) Files() []string {
+	return s.names
+}
+
+// 追加した時点から変更されたファイルがあるかどうか
+func (s *Snapshot) Changed() bool {
+	for _, name := range s.names {
+		if stat(name) != s.states[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// 監視するファイルのいずれかが変更されるまで待つ
+// Linuxではinotifyでファイルのあるディレクトリを監視するので、リネームによる置き換えも検出できる
+//
+// @param ctx --- キャンセルされるとctx.Err()を返す
+// @param s   --- 監視するファイル
+//
+// @return エラー
+func Wait(ctx context.Context, s *Snapshot) error {
+
+	if err := wait(ctx, s); err != nil {
+		return err
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(settleTime):
+		return nil
+	}
+}
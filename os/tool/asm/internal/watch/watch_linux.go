@@ -0,0 +1,94 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// 監視するイベント
+// 書き込みの完了に加え、エディタがリネームで保存する場合や、ファイルの作成・削除も検出する
+const mask = syscall.IN_CLOSE_WRITE | syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM | syscall.IN_CREATE | syscall.IN_DELETE
+
+// inotifyでファイルのあるディレクトリを監視し、監視するファイルのイベントを待つ
+func wait(ctx context.Context, s *Snapshot) error {
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return os.NewSyscallError("inotify_init1", err)
+	}
+	// ノンブロッキングのファイルディスクリプタはランタイムのポーラーで待つので、Close()で読み込みを中断できる
+	f := os.NewFile(uintptr(fd), "inotify")
+	defer func() { _ = f.Close() }()
+
+	files := make(map[string]bool)
+	dirs := make(map[int32]string)
+	watched := make(map[string]bool)
+	for _, name := range s.Files() {
+		files[name] = true
+		dir := filepath.Dir(name)
+		if watched[dir] {
+			continue
+		}
+		watched[dir] = true
+		wd, err := syscall.InotifyAddWatch(fd, dir, mask)
+		if err == syscall.ENOENT {
+			// 存在しないディレクトリのファイルは、ディレクトリが作成されても検出できない
+			continue
+		}
+		if err != nil {
+			return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+		}
+		dirs[int32(wd)] = dir
+	}
+	if len(dirs) == 0 {
+		return errors.New("no directory to watch")
+	}
+
+	// 監視を始める前の変更
+	if s.Changed() {
+		return nil
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = f.Close()
+		case <-done:
+		}
+	}()
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := f.Read(buf)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			e := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			name := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(e.Len)]
+			offset += syscall.SizeofInotifyEvent + int(e.Len)
+
+			// イベントを取りこぼした場合や、ディレクトリが削除された場合は変更とみなす
+			if e.Mask&(syscall.IN_Q_OVERFLOW|syscall.IN_IGNORED) != 0 {
+				return nil
+			}
+			dir, ok := dirs[e.Wd]
+			if !ok {
+				continue
+			}
+			if files[filepath.Join(dir, string(bytes.TrimRight(name, "\x00")))] {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !linux
+// +build !linux
+
+package watch
+
+import (
+	"context"
+	"time"
+)
+
+// ファイルの状態を確認する間隔
+const pollInterval = 500 * time.Millisecond
+
+// inotifyが無い環境では、ファイルの状態を定期的に確認する
+func wait(ctx context.Context, s *Snapshot) error {
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for !s.Changed() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package watch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 変更されるまで待ち、戻らなければ失敗する
+func mustWait(t *testing.T, s *Snapshot, change func()) {
+
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		change()
+	}()
+	if err := Wait(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWait(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "boot.asm")
+	if err := ioutil.WriteFile(name, []byte("HLT\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := new(Snapshot)
+	s.Add(name)
+	mustWait(t, s, func() {
+		_ = ioutil.WriteFile(name, []byte("NOP\nHLT\n"), 0644)
+	})
+
+	// 一時ファイルに書き込んでリネームするエディタ
+	s = new(Snapshot)
+	s.Add(name)
+	mustWait(t, s, func() {
+		temp := filepath.Join(dir, ".boot.asm.swp")
+		_ = ioutil.WriteFile(temp, []byte("CLI\nHLT\n"), 0644)
+		_ = os.Rename(temp, name)
+	})
+
+	// まだ存在しないファイルの作成
+	s = new(Snapshot)
+	s.Add(filepath.Join(dir, "sub.inc"))
+	mustWait(t, s, func() {
+		_ = ioutil.WriteFile(filepath.Join(dir, "sub.inc"), nil, 0644)
+	})
+}
+
+// 追加してからWait()までの間の変更は直ちに検出する
+func TestWait_Changed(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "boot.asm")
+	if err := ioutil.WriteFile(name, []byte("HLT\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := new(Snapshot)
+	s.Add(name)
+	s.Add(name)
+	if len(s.Files()) != 1 || s.Changed() {
+		t.Fatal(s.Files())
+	}
+	if err := ioutil.WriteFile(name, []byte("NOP\nHLT\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Changed() {
+		t.Fatal("not changed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := Wait(ctx, s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 監視とは関係の無いファイルの変更では戻らず、キャンセルで戻る
+func TestWait_Cancel(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "boot.asm")
+	if err := ioutil.WriteFile(name, []byte("HLT\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := new(Snapshot)
+	s.Add(name)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = ioutil.WriteFile(filepath.Join(dir, "other.asm"), nil, 0644)
+	}()
+	if err := Wait(ctx, s); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,352 @@
+// x86gen : 命令形式の定義ファイル(instruction/x86.txt)からGoのコードを生成する
+//
+//	x86gen -table x86_table.go x86.txt            instructionパッケージの命令形式表とニーモニックの振り分け表
+//	x86gen -encoder mnemonic_gen.go ../x86.txt   encoderパッケージのニーモニック毎のメソッド
+//
+// go generateから実行される
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// オペランドの種類の表記とinstructionパッケージの定数名
+var operandTypes = map[string]string{
+	"reg8":  "Reg8",
+	"reg16": "Reg16",
+	"reg32": "Reg32",
+	"rm8":   "RM8",
+	"rm16":  "RM16",
+	"rm32":  "RM32",
+	"mem":   "Mem",
+	"sreg":  "SReg",
+	"imm8":  "Imm8",
+	"imm16": "Imm16",
+	"imm32": "Imm32",
+	"simm8": "SImm8",
+	"1":     "One",
+	"rel8":  "Rel8",
+	"rel16": "Rel16",
+	"rel32": "Rel32",
+	"al":    "RegAL",
+	"ax":    "RegAX",
+	"eax":   "RegEAX",
+	"cl":    "RegCL",
+	"dx":    "RegDX",
+	"es":    "RegES",
+	"cs":    "RegCS",
+	"ss":    "RegSS",
+	"ds":    "RegDS",
+	"fs":    "RegFS",
+	"gs":    "RegGS",
+}
+
+// 定義ファイルの1行
+type row struct {
+	line     int
+	mnemonic string
+	operands []string // instructionパッケージの定数名
+	slots    string
+	opcode   []byte
+	digit    int
+	opSize   int
+	cpu      string
+}
+
+func main() {
+
+	var (
+		tableFileName   string
+		encoderFileName string
+	)
+	flag.StringVar(&tableFileName, "table", "", "output file name of the instruction form table")
+	flag.StringVar(&encoderFileName, "encoder", "", "output file name of the encoder methods")
+	flag.Parse()
+
+	if flag.NArg() != 1 || (tableFileName == "") == (encoderFileName == "") {
+		fmt.Fprintln(os.Stderr, "usage: x86gen (-table file | -encoder file) x86.txt")
+		os.Exit(2)
+	}
+	source := flag.Arg(0)
+
+	rows, err := parse(source)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	var (
+		out      *bytes.Buffer
+		fileName string
+	)
+	if tableFileName != "" {
+		out, fileName = generateTable(rows, source), tableFileName
+	} else {
+		out, fileName = generateEncoder(rows, source), encoderFileName
+	}
+
+	code, err := format.Source(out.Bytes())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(fileName, code, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// 定義ファイルを読み込む
+//
+// @param fileName --- 定義ファイル
+//
+// @return 定義の一覧、エラー
+func parse(fileName string) ([]row, error) {
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var (
+		rows    []row
+		scanner = bufio.NewScanner(f)
+		line    = 0
+	)
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if i := strings.IndexByte(text, ';'); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		r, err := parseRow(fields)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", fileName, line, err.Error())
+		}
+		r.line = line
+		rows = append(rows, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func parseRow(fields []string) (row, error) {
+
+	if len(fields) != 6 {
+		return row{}, fmt.Errorf("6 fields expected, got %d", len(fields))
+	}
+
+	r := row{
+		mnemonic: fields[0],
+		cpu:      fields[5],
+		digit:    -1,
+	}
+	if strings.ToUpper(r.mnemonic) != r.mnemonic {
+		return row{}, fmt.Errorf("mnemonic must be upper case: %s", r.mnemonic)
+	}
+
+	if fields[1] != "-" {
+		for _, op := range strings.Split(fields[1], ",") {
+			i := strings.IndexByte(op, ':')
+			if i < 0 || len(op)-i != 2 || !strings.Contains("rmoij-", op[i+1:]) {
+				return row{}, fmt.Errorf("invalid operand: %s", op)
+			}
+			t, ok := operandTypes[op[:i]]
+			if !ok {
+				return row{}, fmt.Errorf("unknown operand type: %s", op[:i])
+			}
+			r.operands = append(r.operands, t)
+			r.slots += op[i+1:]
+		}
+	}
+
+	if len(fields[2])%2 != 0 {
+		return row{}, fmt.Errorf("invalid opcode: %s", fields[2])
+	}
+	for i := 0; i < len(fields[2]); i += 2 {
+		c, err := strconv.ParseUint(fields[2][i:i+2], 16, 8)
+		if err != nil {
+			return row{}, fmt.Errorf("invalid opcode: %s", fields[2])
+		}
+		r.opcode = append(r.opcode, byte(c))
+	}
+
+	hasRM := strings.Contains(r.slots, "m")
+	switch modRM := fields[3]; {
+	case modRM == "-":
+		if hasRM {
+			return row{}, fmt.Errorf("ModR/M must be specified for an r/m operand")
+		}
+	case modRM == "/r":
+		if !hasRM || !strings.Contains(r.slots, "r") {
+			return row{}, fmt.Errorf("/r requires both reg and r/m operands")
+		}
+	case len(modRM) == 2 && modRM[0] == '/' && '0' <= modRM[1] && modRM[1] <= '7':
+		if !hasRM || strings.Contains(r.slots, "r") {
+			return row{}, fmt.Errorf("%s requires an r/m operand and no reg operand", modRM)
+		}
+		r.digit = int(modRM[1] - '0')
+	default:
+		return row{}, fmt.Errorf("invalid ModR/M: %s", modRM)
+	}
+
+	switch fields[4] {
+	case "-":
+	case "o16":
+		r.opSize = 16
+	case "o32":
+		r.opSize = 32
+	default:
+		return row{}, fmt.Errorf("invalid operand size: %s", fields[4])
+	}
+
+	switch r.cpu {
+	case "8086", "186", "386":
+	default:
+		return row{}, fmt.Errorf("unknown CPU: %s", r.cpu)
+	}
+
+	return r, nil
+}
+
+func header(b *bytes.Buffer, source string, pkg string) {
+	fmt.Fprintf(b, "// Code generated by x86gen from %s; DO NOT EDIT.\n\n", filepath.Base(source))
+	fmt.Fprintf(b, "package %s\n\n", pkg)
+}
+
+// 命令形式の表とニーモニックの振り分け表を生成する
+func generateTable(rows []row, source string) *bytes.Buffer {
+
+	b := new(bytes.Buffer)
+	header(b, source, "instruction")
+
+	b.WriteString("// 命令形式の一覧 並び順の意味はx86.txtを参照\n")
+	b.WriteString("var forms = []Form{\n")
+	for _, r := range rows {
+		opcode := make([]string, len(r.opcode))
+		for i, c := range r.opcode {
+			opcode[i] = fmt.Sprintf("0x%02X", c)
+		}
+		fmt.Fprintf(b, "\t{%q, []OperandType{%s}, %q, []byte{%s}, %d, %d, CPU%s}, // x86.txt:%d\n",
+			r.mnemonic, strings.Join(r.operands, ", "), r.slots, strings.Join(opcode, ", "), r.digit, r.opSize, r.cpu, r.line)
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString("// ニーモニックをキーとした命令形式の一覧\n")
+	b.WriteString("var formsByMnemonic = map[string][]*Form{\n")
+	for _, m := range mnemonics(rows) {
+		var refs []string
+		for i, r := range rows {
+			if r.mnemonic == m {
+				refs = append(refs, fmt.Sprintf("&forms[%d]", i))
+			}
+		}
+		fmt.Fprintf(b, "\t%q: {%s},\n", m, strings.Join(refs, ", "))
+	}
+	b.WriteString("}\n")
+
+	return b
+}
+
+// encoderパッケージのニーモニック毎のメソッドを生成する
+//
+// オペランドの種類によってメソッドの引数を決める
+//
+//	オペランド無し             func (b *Builder) Hlt() *Builder
+//	相対アドレスを取る         func (b *Builder) Jmp(label string) *Builder
+//	即値1つだけを取る          func (b *Builder) Int(n int64) *Builder
+//	オペランドの数が一定       func (b *Builder) Mov(dst, src instruction.Operand) *Builder
+//	オペランドの数が一定でない func (b *Builder) Ret(operands ...instruction.Operand) *Builder
+func generateEncoder(rows []row, source string) *bytes.Buffer {
+
+	b := new(bytes.Buffer)
+	header(b, source, "encoder")
+	b.WriteString("import \"github.com/nanasi880/til/os/tool/asm/assembler/instruction\"\n\n")
+
+	for _, m := range mnemonics(rows) {
+
+		var (
+			arity     = -1
+			relative  = false
+			immediate = true
+		)
+		for _, r := range rows {
+			if r.mnemonic != m {
+				continue
+			}
+			if arity == -1 {
+				arity = len(r.operands)
+			} else if arity != len(r.operands) {
+				arity = -2
+			}
+			for _, op := range r.operands {
+				switch op {
+				case "Rel8", "Rel16", "Rel32":
+					relative = true
+				case "Imm8", "Imm16", "Imm32":
+				default:
+					immediate = false
+				}
+			}
+		}
+
+		name := m[:1] + strings.ToLower(m[1:])
+		fmt.Fprintf(b, "// %s命令を追加する\n", m)
+		switch {
+		case arity == 0:
+			fmt.Fprintf(b, "func (b *Builder) %s() *Builder { return b.Emit(%q) }\n\n", name, m)
+		case relative:
+			fmt.Fprintf(b, "func (b *Builder) %s(label string) *Builder { return b.jump(%q, label) }\n\n", name, m)
+		case arity == 1 && immediate:
+			fmt.Fprintf(b, "func (b *Builder) %s(n int64) *Builder { return b.Emit(%q, Imm(n)) }\n\n", name, m)
+		case arity > 0:
+			params := []string{"op"}
+			if arity == 2 {
+				params = []string{"dst", "src"}
+			} else if arity > 2 {
+				params = params[:0]
+				for i := 0; i < arity; i++ {
+					params = append(params, fmt.Sprintf("op%d", i+1))
+				}
+			}
+			fmt.Fprintf(b, "func (b *Builder) %s(%s instruction.Operand) *Builder { return b.Emit(%q, %s) }\n\n",
+				name, strings.Join(params, ", "), m, strings.Join(params, ", "))
+		default:
+			fmt.Fprintf(b, "func (b *Builder) %s(operands ...instruction.Operand) *Builder { return b.Emit(%q, operands...) }\n\n", name, m)
+		}
+	}
+
+	return b
+}
+
+// 定義ファイルに現れる順のニーモニックの一覧
+func mnemonics(rows []row) []string {
+
+	var (
+		result []string
+		seen   = make(map[string]bool)
+	)
+	for _, r := range rows {
+		if !seen[r.mnemonic] {
+			seen[r.mnemonic] = true
+			result = append(result, r.mnemonic)
+		}
+	}
+	return result
+}
@@ -1,67 +1,379 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
 
 	"github.com/nanasi880/til/os/tool/asm/assembler"
+	"github.com/nanasi880/til/os/tool/asm/internal/charset"
+	"github.com/nanasi880/til/os/tool/asm/internal/outfile"
+	"github.com/nanasi880/til/os/tool/asm/internal/watch"
 )
 
 var (
-	sourceFileName string
-	outputFileName string
+	sourceFileName    string
+	outputFileName    string
+	encodings         encodingFlags
+	maxOutputSize     int64
+	maxLines          int
+	maxMacroDepth     int
+	outputSize        int64
+	padOutput         bool
+	outputFormatName  string
+	goPackageName     string
+	goVariableName    string
+	checkNames        string
+	sourceMapFileName string
+	sourceMapFormat   string
+	debugELFFileName  string
+	watchMode         bool
 )
 
 func init() {
 	flag.StringVar(&sourceFileName, "f", "", "source file name or path (stdin by default)")
 	flag.StringVar(&outputFileName, "o", "", "output file name or path (stdout by default)")
+	encodings.register(flag.CommandLine)
+	flag.Int64Var(&maxOutputSize, "max-size", 64<<20, "maximum output size in bytes (0 for no limit)")
+	flag.IntVar(&maxLines, "max-lines", 0, "maximum number of lines including includes and macro expansions (0 for no limit)")
+	flag.IntVar(&maxMacroDepth, "max-macro-depth", 0, "maximum macro expansion depth (0 for the default 64)")
+	flag.Int64Var(&outputSize, "size", 0, "expected output size in bytes; fail if the output size differs (0 for no check)")
+	flag.BoolVar(&padOutput, "pad", false, "pad the output with zeros up to -size instead of failing when it is smaller (bin only)")
+	flag.StringVar(&outputFormatName, "format", "bin", "output format (bin, ihex, srec, go)")
+	flag.StringVar(&goPackageName, "go-package", "main", "package name of the Go source written by -format go")
+	flag.StringVar(&goVariableName, "go-var", "image", "[]byte variable name of the Go source written by -format go")
+	flag.StringVar(&sourceMapFileName, "source-map", "", "write the address to source line map to this file")
+	flag.StringVar(&sourceMapFormat, "source-map-format", "json", "source map format (json, text)")
+	flag.StringVar(&debugELFFileName, "debug-elf", "", "write an ELF file with the symbols and DWARF line info at the load addresses, for gdb")
+	flag.BoolVar(&watchMode, "watch", false, "watch the source and included files and reassemble on every change (needs -f and -o)")
+	flag.StringVar(&checkNames, "checks", "", "comma-separated boot image checks reported as warnings (signature, bpb, sector, jump, all)")
+}
+
+// サブコマンドの一覧
+// 先頭の引数がサブコマンド名でなければアセンブルを行う
+var subcommands = map[string]func(args []string) int{
+	"diff":   diffMain,
+	"disasm": disasmMain,
+	"patch":  patchMain,
+	"repl":   replMain,
+	"run":    runMain,
+	"test":   asmTestMain,
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			os.Exit(cmd(os.Args[2:]))
+		}
+	}
 	os.Exit(_main())
 }
 
 func _main() int {
 	flag.Parse()
 
+	options, err := encodings.options()
+	if err != nil {
+		errorln(err)
+		return 1
+	}
+	outputFormat, err := assembler.ParseFormat(outputFormatName)
+	if err != nil {
+		errorln(err)
+		return 1
+	}
+	checks, err := assembler.ParseChecks(checkNames)
+	if err != nil {
+		errorln(err)
+		return 1
+	}
+	mapFormat, err := assembler.ParseSourceMapFormat(sourceMapFormat)
+	if err != nil {
+		errorln(err)
+		return 1
+	}
+	if padOutput && outputFormat != assembler.FormatBinary {
+		errorln("-pad is only available with -format bin")
+		return 1
+	}
+
+	options = append(options,
+		assembler.WithOutputFormat(outputFormat),
+		assembler.WithGoSource(goPackageName, goVariableName),
+		assembler.WithChecks(checks),
+		assembler.WithLimits(assembler.Limits{
+			MaxOutputSize: maxOutputSize,
+			MaxLines:      maxLines,
+			MaxMacroDepth: maxMacroDepth,
+		}),
+	)
+	if watchMode {
+		return watchMain(options, mapFormat)
+	}
+
+	if _, err := build(interruptContext(), options, mapFormat, nil); err != nil {
+		errorln(err)
+		return 1
+	}
+
+	return 0
+}
+
+// -input-encoding, -string-encoding
+// サブコマンドでも同じ指定ができるよう、フラグセット毎に登録する
+type encodingFlags struct {
+	inputName  string // ソースコードの文字コード名
+	stringName string // 文字列リテラルを出力する際の文字コード名
+}
+
+// フラグを登録する
+//
+// @param fs --- フラグセット
+func (e *encodingFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&e.inputName, "input-encoding", "utf-8", "source file encoding (utf-8, shift_jis, euc-jp)")
+	fs.StringVar(&e.stringName, "string-encoding", "utf-8", "encoding of string literals written to the output (utf-8, shift_jis, euc-jp)")
+}
+
+// 指定された文字コードのアセンブラのオプション
+//
+// @return オプション、エラー
+func (e *encodingFlags) options() ([]assembler.Option, error) {
+
+	inputEncoding, err := charset.Lookup(e.inputName)
+	if err != nil {
+		return nil, err
+	}
+	stringEncoding, err := charset.Lookup(e.stringName)
+	if err != nil {
+		return nil, err
+	}
+	return []assembler.Option{
+		assembler.WithInputEncoding(inputEncoding),
+		assembler.WithStringEncoding(stringEncoding),
+	}, nil
+}
+
+// 1回分のアセンブルを行い、成功した場合だけ出力ファイルを置き換える
+// エラー以外の診断メッセージは標準エラー出力に表示する
+//
+// @param ctx       --- コンテキスト
+// @param options   --- INCLUDE命令のファイルシステム以外のアセンブラのオプション
+// @param mapFormat --- ソースマップの出力形式
+// @param snapshot  --- nilでなければ読み込んだソースコードのファイルを追加する
+//
+// @return アセンブル結果、エラー
+func build(ctx context.Context, options []assembler.Option, mapFormat assembler.SourceMapFormat, snapshot *watch.Snapshot) (*assembler.Result, error) {
+
 	var (
-		sourceFile = os.Stdin
-		outputFile = os.Stdout
+		sourceFile           = os.Stdin
+		outputFile io.Writer = os.Stdout
 	)
 	if sourceFileName != "" {
+		if snapshot != nil {
+			snapshot.Add(sourceFileName)
+		}
 		f, err := os.Open(sourceFileName)
 		if err != nil {
-			errorln(err)
-			return 1
+			return nil, err
 		}
 		sourceFile = f
 		defer fclose(f)
 	}
+	// 出力ファイルは一時ファイルに書き込み、成功した場合だけ置き換える
+	var output *outfile.File
 	if outputFileName != "" {
-		f, err := os.OpenFile(outputFileName, os.O_RDWR|os.O_CREATE, 0644)
+		f, err := outfile.Create(outputFileName)
 		if err != nil {
-			errorln(err)
-			return 1
+			return nil, err
 		}
-		outputFile = f
-		defer fclose(f)
+		outputFile, output = f, f
+		defer abort(f)
 	}
 
-	if err := assembler.New().Exec(sourceFile, outputFile); err != nil {
-		errorln(err)
-		return 1
+	// INCLUDE命令のファイル名はソースコードのあるディレクトリからの相対パスとする
+	includeDir := "."
+	if sourceFileName != "" {
+		includeDir = filepath.Dir(sourceFileName)
+	}
+	var includeFS assembler.FileSystem = assembler.DirFS(includeDir)
+	if snapshot != nil {
+		includeFS = &watchFS{fs: includeFS, dir: includeDir, snapshot: snapshot}
 	}
 
-	return 0
+	options = append(options, assembler.WithIncludeFS(includeFS))
+	if output != nil && output.Temporary() {
+		// 新しく作成した一時ファイルであれば、大きなRESBの領域は書き込まずに飛ばせる
+		options = append(options, assembler.WithSparseOutput())
+	}
+	a := assembler.New(options...)
+	result, err := a.ExecContext(ctx, sourceFile, outputFile)
+	for _, d := range result.Diagnostics {
+		if d.Severity != assembler.SeverityError {
+			errorln(d)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := fixSize(outputFile, result.Size); err != nil {
+		return nil, err
+	}
+	if output != nil {
+		if err := output.Commit(); err != nil {
+			return nil, err
+		}
+	}
+	if sourceMapFileName != "" || debugELFFileName != "" {
+		resolveSourcePaths(result.SourceMap, includeDir)
+	}
+	if sourceMapFileName != "" {
+		if err := writeSourceMap(result.SourceMap, mapFormat); err != nil {
+			return nil, err
+		}
+	}
+	if debugELFFileName != "" {
+		if err := writeDebugELF(result); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// ソースマップのファイル名を、カレントディレクトリからのパスに直す
+//
+// @param m          --- ソースマップ
+// @param includeDir --- INCLUDE命令のファイル名の基準となるディレクトリ
+func resolveSourcePaths(m *assembler.SourceMap, includeDir string) {
+
+	path := func(name string) string {
+		if name == "" {
+			return mainSourceName()
+		}
+		return filepath.Join(includeDir, name)
+	}
+	for i := range m.Entries {
+		e := &m.Entries[i]
+		e.File = path(e.File)
+		for j := range e.Expansion {
+			e.Expansion[j].File = path(e.Expansion[j].File)
+		}
+	}
+}
+
+// ソースマップやデバッグ情報に記録するメインのソースコードのファイル名
+func mainSourceName() string {
+	if sourceFileName == "" {
+		return "<stdin>"
+	}
+	return sourceFileName
+}
+
+// ソースマップを-source-mapのファイルに出力する
+//
+// @param m      --- ソースマップ
+// @param format --- 出力形式
+//
+// @return エラー
+func writeSourceMap(m *assembler.SourceMap, format assembler.SourceMapFormat) error {
+
+	f, err := outfile.Create(sourceMapFileName)
+	if err != nil {
+		return err
+	}
+	defer abort(f)
+	if err := m.Write(f, format); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// デバッグ用のELFファイルを-debug-elfのファイルに出力する
+// 相対パスのファイル名はカレントディレクトリを基準とする
+//
+// @param result --- アセンブル結果
+//
+// @return エラー
+func writeDebugELF(result *assembler.Result) error {
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	f, err := outfile.Create(debugELFFileName)
+	if err != nil {
+		return err
+	}
+	defer abort(f)
+	if err := result.WriteDebugELF(f, mainSourceName(), dir); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+// 出力のサイズを-sizeと比較し、-padが指定されていれば不足分を0で埋める
+//
+// @param w    --- 出力先 アセンブル結果の末尾に追記する
+// @param size --- アセンブル結果のサイズ
+//
+// @return エラー
+func fixSize(w io.Writer, size int64) error {
+
+	switch {
+	case outputSize <= 0 || size == outputSize:
+		return nil
+	case size > outputSize:
+		return fmt.Errorf("output size %d exceeds -size %d", size, outputSize)
+	case !padOutput:
+		return fmt.Errorf("output size %d is smaller than -size %d (use -pad to fill with zeros)", size, outputSize)
+	}
+
+	zeros := make([]byte, 4096)
+	for n := outputSize - size; n > 0; {
+		chunk := zeros
+		if n < int64(len(chunk)) {
+			chunk = chunk[:n]
+		}
+		if _, err := w.Write(chunk); err != nil {
+			return err
+		}
+		n -= int64(len(chunk))
+	}
+	return nil
+}
+
+// Ctrl+Cでキャンセルされるコンテキストを作成する
+func interruptContext() context.Context {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	go func() {
+		<-c
+		cancel()
+	}()
+	return ctx
 }
 
 func errorln(args ...interface{}) {
 	_, _ = fmt.Fprintln(os.Stderr, args...)
 }
 
+// 出力ファイルへの書き込みを破棄し、もしエラーが発生した場合はそれをログする
+// Commit()済みであれば何もしない
+//
+// @param f --- 出力ファイル
+func abort(f *outfile.File) {
+	if err := f.Abort(); err != nil {
+		log.Println(err)
+	}
+}
+
 // io.Closerをクローズし、もしエラーが発生した場合はそれをログする
 //
 // @param closer --- クローズ対象
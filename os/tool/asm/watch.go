@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/nanasi880/til/os/tool/asm/assembler"
+	"github.com/nanasi880/til/os/tool/asm/internal/watch"
+)
+
+// asm -watch -f source -o output [options]
+// ソースコードとINCLUDEしたファイルを監視し、変更される度にアセンブルし直す
+// 成功すれば出力ファイルを置き換えてサイズとCRC32を1行で表示し、失敗すれば診断メッセージを表示して出力ファイルには触れない
+// Ctrl+Cで終了する
+//
+// @param options   --- INCLUDE命令のファイルシステム以外のアセンブラのオプション
+// @param mapFormat --- ソースマップの出力形式
+func watchMain(options []assembler.Option, mapFormat assembler.SourceMapFormat) int {
+
+	if sourceFileName == "" || outputFileName == "" {
+		errorln("-watch needs -f and -o")
+		return 1
+	}
+
+	ctx := interruptContext()
+	for {
+		snapshot := new(watch.Snapshot)
+		_, err := build(ctx, options, mapFormat, snapshot)
+		if ctx.Err() != nil {
+			return 0
+		}
+		stamp := time.Now().Format("15:04:05")
+		if err != nil {
+			errorln(stamp, "FAIL", err)
+		} else if err := printSummary(stamp); err != nil {
+			errorln(stamp, "FAIL", err)
+		}
+
+		if err := watch.Wait(ctx, snapshot); err != nil {
+			if ctx.Err() != nil {
+				return 0
+			}
+			errorln(err)
+			return 1
+		}
+	}
+}
+
+// 置き換えた出力ファイルのサイズとCRC32を1行で表示する
+//
+//	12:34:56 ok   boot.bin  512 bytes  crc32 1C291CA3
+//
+// @param stamp --- 時刻
+//
+// @return エラー
+func printSummary(stamp string) error {
+
+	b, err := ioutil.ReadFile(outputFileName)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s ok   %s  %d bytes  crc32 %08X\n", stamp, outputFileName, len(b), crc32.ChecksumIEEE(b))
+	return nil
+}
+
+// INCLUDE命令で読み込むファイルを監視するファイルに追加するファイルシステム
+type watchFS struct {
+	fs       assembler.FileSystem
+	dir      string // fsの基準となるディレクトリ
+	snapshot *watch.Snapshot
+}
+
+// assembler.FileSystemの実装
+// 存在しないファイルも追加し、作成された時にアセンブルし直せるようにする
+func (w *watchFS) Open(name string) (io.ReadCloser, error) {
+	w.snapshot.Add(filepath.Join(w.dir, filepath.FromSlash(name)))
+	return w.fs.Open(name)
+}